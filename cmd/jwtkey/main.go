@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/heroticket/internal/cmd"
+)
+
+func main() {
+	os.Exit(cmd.JwtKey(os.Args[1:]))
+}
@@ -21,7 +21,7 @@ func main() {
 		panic(err)
 	}
 
-	repo, err := nmongo.New(context.Background(), client, cfg.Ticket.DbName)
+	repo, err := nmongo.New(context.Background(), client, cfg.DefaultNetwork().DbName)
 	if err != nil {
 		panic(err)
 	}
@@ -31,7 +31,7 @@ func main() {
 		panic(err)
 	}
 
-	for _, r := range res {
+	for _, r := range res.Items {
 		fmt.Println(r)
 	}
 }
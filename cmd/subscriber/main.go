@@ -0,0 +1,7 @@
+package main
+
+import "github.com/heroticket/internal/cmd"
+
+func main() {
+	cmd.Subscribe()
+}
@@ -30,20 +30,29 @@ func DefaultConfig() *Config {
 
 type App struct {
 	*http.Server
+	router *router
 }
 
 func New(cfg *Config, ctrls ...Controller) *App {
+	r := newRouter(cfg.Version, ctrls...)
+
 	return &App{
 		Server: &http.Server{
 			Addr:         cfg.Addr,
-			Handler:      newRouter(cfg.Version, ctrls...),
+			Handler:      r,
 			ReadTimeout:  cfg.ReadTimeout,
 			WriteTimeout: cfg.WriteTimeout,
 			IdleTimeout:  cfg.IdleTimeout,
 		},
+		router: r,
 	}
 }
 
+// MountRoot mounts ctrl outside the versioned api, for paths fixed by a standard such as /.well-known.
+func (s *App) MountRoot(ctrl Controller) {
+	s.router.Mount(ctrl.Pattern(), ctrl.Handler())
+}
+
 func (s *App) Run() error {
 	return s.ListenAndServe()
 }
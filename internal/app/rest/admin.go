@@ -0,0 +1,549 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/heroticket/internal/logger"
+	"github.com/heroticket/internal/pagination"
+	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/ticket"
+	"github.com/heroticket/internal/service/user"
+)
+
+type AdminCtrl struct {
+	jwt      jwt.Service
+	networks *ticket.Networks
+	user     user.Service
+}
+
+func NewAdminCtrl(jwt jwt.Service, networks *ticket.Networks, user user.Service) *AdminCtrl {
+	return &AdminCtrl{
+		jwt:      jwt,
+		networks: networks,
+		user:     user,
+	}
+}
+
+func (c *AdminCtrl) Pattern() string {
+	return "/admin"
+}
+
+func (c *AdminCtrl) Handler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(TokenRequired(c.jwt))
+
+	r.Group(func(r chi.Router) {
+		r.Use(RequirePermission(c.jwt, user.PermissionManageRoles))
+		r.Get("/users/{id}/grants", c.grants)
+		r.Put("/users/{id}/roles/{role}", c.grantRole)
+		r.Delete("/users/{id}/roles/{role}", c.revokeRole)
+		r.Put("/users/{id}/permissions/{permission}", c.grantPermission)
+		r.Delete("/users/{id}/permissions/{permission}", c.revokePermission)
+		r.Get("/grant-events", c.grantEvents)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(RequirePermission(c.jwt, user.PermissionManageUsers))
+		r.Get("/users", c.users)
+		r.Get("/users/{id}", c.userDetails)
+		r.Put("/users/{id}/status", c.setStatus)
+		r.Get("/moderation-events", c.moderationEvents)
+	})
+
+	return r
+}
+
+// UserGrants are the roles and permissions of a user, as granted and in effect.
+type UserGrants struct {
+	UserID               string            `json:"userId"`
+	Roles                []user.Role       `json:"roles"`
+	Permissions          []user.Permission `json:"permissions"`
+	EffectiveRoles       []user.Role       `json:"effectiveRoles"`
+	EffectivePermissions []user.Permission `json:"effectivePermissions"`
+}
+
+func newUserGrants(u *user.User) UserGrants {
+	return UserGrants{
+		UserID:               u.ID,
+		Roles:                u.Roles,
+		Permissions:          u.Permissions,
+		EffectiveRoles:       u.EffectiveRoles(),
+		EffectivePermissions: u.EffectivePermissions(),
+	}
+}
+
+// Grants godoc
+//
+// @Tags			admin
+// @Summary		returns the roles and permissions of a user
+// @Description	returns the roles and permissions granted to a user and the ones in effect, requires the roles:manage permission
+// @Produce		json
+// @Param			id	path	string	true	"user id"
+// @Success		200			{object}	CommonResponse{data=UserGrants}
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users/{id}/grants [get]
+func (c *AdminCtrl) grants(w http.ResponseWriter, r *http.Request) {
+	u, err := c.user.FindUserByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "user not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully fetched user grants",
+		Data:    newUserGrants(u),
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// GrantRole godoc
+//
+// @Tags			admin
+// @Summary		grants a role
+// @Description	grants one of the admin, organizer or scanner roles to a user, requires the roles:manage permission. The change is audit-logged and in effect from the next token refresh.
+// @Produce		json
+// @Param			id		path	string	true	"user id"
+// @Param			role	path	string	true	"role"
+// @Success		200			{object}	CommonResponse{data=UserGrants}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users/{id}/roles/{role} [put]
+func (c *AdminCtrl) grantRole(w http.ResponseWriter, r *http.Request) {
+	c.updateGrant(w, r, user.GrantKindRole, chi.URLParam(r, "role"), true)
+}
+
+// RevokeRole godoc
+//
+// @Tags			admin
+// @Summary		revokes a role
+// @Description	revokes a role of a user and logs out its sessions, requires the roles:manage permission. The change is audit-logged.
+// @Produce		json
+// @Param			id		path	string	true	"user id"
+// @Param			role	path	string	true	"role"
+// @Success		200			{object}	CommonResponse{data=UserGrants}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users/{id}/roles/{role} [delete]
+func (c *AdminCtrl) revokeRole(w http.ResponseWriter, r *http.Request) {
+	c.updateGrant(w, r, user.GrantKindRole, chi.URLParam(r, "role"), false)
+}
+
+// GrantPermission godoc
+//
+// @Tags			admin
+// @Summary		grants a permission
+// @Description	grants a single permission to a user besides the ones of its roles, requires the roles:manage permission. The change is audit-logged and in effect from the next token refresh.
+// @Produce		json
+// @Param			id			path	string	true	"user id"
+// @Param			permission	path	string	true	"permission"
+// @Success		200			{object}	CommonResponse{data=UserGrants}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users/{id}/permissions/{permission} [put]
+func (c *AdminCtrl) grantPermission(w http.ResponseWriter, r *http.Request) {
+	c.updateGrant(w, r, user.GrantKindPermission, chi.URLParam(r, "permission"), true)
+}
+
+// RevokePermission godoc
+//
+// @Tags			admin
+// @Summary		revokes a permission
+// @Description	revokes a permission granted to a user and logs out its sessions, requires the roles:manage permission. Permissions of its roles are kept. The change is audit-logged.
+// @Produce		json
+// @Param			id			path	string	true	"user id"
+// @Param			permission	path	string	true	"permission"
+// @Success		200			{object}	CommonResponse{data=UserGrants}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users/{id}/permissions/{permission} [delete]
+func (c *AdminCtrl) revokePermission(w http.ResponseWriter, r *http.Request) {
+	c.updateGrant(w, r, user.GrantKindPermission, chi.URLParam(r, "permission"), false)
+}
+
+func (c *AdminCtrl) updateGrant(w http.ResponseWriter, r *http.Request, kind user.GrantKind, value string, grant bool) {
+	// 1. get admin from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	params := user.GrantParams{
+		UserID: chi.URLParam(r, "id"),
+		Kind:   kind,
+		Value:  value,
+		By:     jwtUser.ID,
+	}
+
+	// 2. update grants
+	var u *user.User
+
+	if grant {
+		u, err = c.user.Grant(r.Context(), params)
+	} else {
+		u, err = c.user.Revoke(r.Context(), params)
+	}
+	if err != nil {
+		switch err {
+		case user.ErrInvalidRole, user.ErrInvalidPermission, user.ErrIssuerAdmin, user.ErrSelfRevoke:
+			ErrorJSON(w, err.Error())
+		case user.ErrUserNotFound:
+			ErrorJSON(w, "user not found", http.StatusNotFound)
+		default:
+			logger.Error("failed to update grants", "error", err)
+			ErrorJSON(w, "failed to update grants", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 3. tokens carry the permissions they were issued with, log out the user so revoked ones are not used until expiry
+	if !grant {
+		if err := c.jwt.RevokeAllSessions(r.Context(), u.ID); err != nil {
+			logger.Error("failed to revoke sessions", "error", err)
+			ErrorJSON(w, "failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully updated user grants",
+		Data:    newUserGrants(u),
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// GrantEvents godoc
+//
+// @Tags			admin
+// @Summary		returns the grant audit log
+// @Description	returns the roles and permissions granted and revoked, newest first, requires the roles:manage permission
+// @Produce		json
+// @Param			userId	query	string	false	"only the events of the user"
+// @Param			page	query	int		false	"page number"
+// @Param			limit	query	int		false	"page size"
+// @Success		200			{object}	CommonResponse{data=user.GrantEvents}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/grant-events [get]
+func (c *AdminCtrl) grantEvents(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	events, err := c.user.FindGrantEvents(r.Context(), user.GrantEventFilter{
+		UserID: r.URL.Query().Get("userId"),
+		Page:   page,
+		Limit:  limit,
+	})
+	if err != nil {
+		logger.Error("failed to find grant events", "error", err)
+		ErrorJSON(w, "failed to find grant events", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully fetched grant events",
+		Data:    events,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// Users godoc
+//
+// @Tags			admin
+// @Summary		searches users
+// @Description	returns the users matching a DID, a full account or tba address, or the start of a name, handle or address, newest first, requires the users:manage permission
+// @Produce		json
+// @Param			q		query	string	false	"search query"
+// @Param			status	query	string	false	"only the users with the status, one of active, suspended or banned"
+// @Param			page	query	int		false	"page number"
+// @Param			limit	query	int		false	"page size"
+// @Success		200			{object}	CommonResponse{data=user.Users}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users [get]
+func (c *AdminCtrl) users(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	users, err := c.user.SearchUsers(r.Context(), user.UserFilter{
+		Query:  r.URL.Query().Get("q"),
+		Status: user.Status(r.URL.Query().Get("status")),
+		Page:   page,
+		Limit:  limit,
+	})
+	if err != nil {
+		if err == user.ErrInvalidStatus {
+			ErrorJSON(w, err.Error())
+			return
+		}
+		logger.Error("failed to search users", "error", err)
+		ErrorJSON(w, "failed to search users", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully fetched users",
+		Data:    users,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// UserDetails is a user as seen by admins, with its activity and moderation history.
+type UserDetails struct {
+	User              *user.User                 `json:"user"`
+	Status            user.Status                `json:"status"`
+	Grants            UserGrants                 `json:"grants"`
+	IssuedCollections []*ticket.TicketCollection `json:"issuedCollections"`
+	OwnedTickets      []ticket.NFT               `json:"ownedTickets"`
+	ModerationEvents  []*user.ModerationEvent    `json:"moderationEvents"`
+}
+
+// UserDetails godoc
+//
+// @Tags			admin
+// @Summary		returns the details of a user
+// @Description	returns a user with its grants, the collections its linked wallets issued on every network, the tickets they own on the network and its latest moderation events, requires the users:manage permission
+// @Produce		json
+// @Param			id		path	string	true	"user id"
+// @Param			chainId	query	int		false	"chain id of the owned tickets, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=UserDetails}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users/{id} [get]
+func (c *AdminCtrl) userDetails(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get user
+	u, err := c.user.FindUserByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "user not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
+		return
+	}
+
+	// 2. get collections issued by every linked wallet on every network
+	issued, err := issuedCollections(r.Context(), c.networks, u)
+	if err != nil {
+		logger.Error("failed to get ticket collections", "error", err)
+		ErrorJSON(w, "failed to get ticket collections", http.StatusInternalServerError)
+		return
+	}
+
+	details := UserDetails{
+		User:              u,
+		Status:            u.CurrentStatus(time.Now().Unix()),
+		Grants:            newUserGrants(u),
+		IssuedCollections: issued,
+	}
+
+	// 3. get tickets owned on the network
+	details.OwnedTickets, err = ownedNFTs(r.Context(), tickets, u)
+	if err != nil {
+		logger.Error("failed to get owned nft", "error", err)
+		ErrorJSON(w, "failed to get owned nft", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. get latest moderation events
+	events, err := c.user.FindModerationEvents(r.Context(), user.ModerationEventFilter{
+		UserID: u.ID,
+		Page:   pagination.DefaultPage,
+		Limit:  pagination.DefaultLimit,
+	})
+	if err != nil {
+		logger.Error("failed to find moderation events", "error", err)
+		ErrorJSON(w, "failed to find moderation events", http.StatusInternalServerError)
+		return
+	}
+
+	details.ModerationEvents = events.Items
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully fetched user details",
+		Data:    details,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+type SetStatusRequest struct {
+	Status user.Status `json:"status"`
+	Reason string      `json:"reason"`
+	// Until is the unix time a suspension ends at
+	Until int64 `json:"until"`
+}
+
+// SetStatus godoc
+//
+// @Tags			admin
+// @Summary		suspends, bans or reinstates a user
+// @Description	suspends a user until a time, bans it or makes it active again, requires the users:manage permission. Suspended and banned users are logged out and can't log in. The change is audit-logged.
+// @Accept			json
+// @Produce		json
+// @Param			id		path	string				true	"user id"
+// @Param			request	body	SetStatusRequest	true	"status, a reason unless active, and the end of a suspension"
+// @Success		200			{object}	CommonResponse{data=user.User}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/users/{id}/status [put]
+func (c *AdminCtrl) setStatus(w http.ResponseWriter, r *http.Request) {
+	// 1. get admin from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. read request
+	var req SetStatusRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "failed to read request")
+		return
+	}
+
+	// 3. update status
+	u, err := c.user.SetStatus(r.Context(), user.SetStatusParams{
+		UserID: chi.URLParam(r, "id"),
+		Status: req.Status,
+		Reason: req.Reason,
+		Until:  req.Until,
+		By:     jwtUser.ID,
+	})
+	if err != nil {
+		switch err {
+		case user.ErrInvalidStatus, user.ErrInvalidSuspension, user.ErrReasonRequired:
+			ErrorJSON(w, err.Error())
+		case user.ErrSelfModeration, user.ErrModerateAdmin:
+			ErrorJSON(w, err.Error(), http.StatusForbidden)
+		case user.ErrUserNotFound:
+			ErrorJSON(w, "user not found", http.StatusNotFound)
+		default:
+			logger.Error("failed to set user status", "error", err)
+			ErrorJSON(w, "failed to set user status", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 4. reject the tokens the user holds right away, new ones are refused while the status lasts
+	switch u.Status {
+	case user.StatusSuspended:
+		err = c.jwt.SuspendUser(r.Context(), u.ID, time.Unix(u.SuspendedUntil, 0))
+	case user.StatusBanned:
+		err = c.jwt.SuspendUser(r.Context(), u.ID, time.Time{})
+	default:
+		err = c.jwt.ReinstateUser(r.Context(), u.ID)
+	}
+	if err != nil {
+		logger.Error("failed to update sessions", "error", err)
+		ErrorJSON(w, "failed to update sessions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully updated user status",
+		Data:    u,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// ModerationEvents godoc
+//
+// @Tags			admin
+// @Summary		returns the moderation audit log
+// @Description	returns the users suspended, banned and reinstated, newest first, requires the users:manage permission
+// @Produce		json
+// @Param			userId	query	string	false	"only the events of the user"
+// @Param			page	query	int		false	"page number"
+// @Param			limit	query	int		false	"page size"
+// @Success		200			{object}	CommonResponse{data=user.ModerationEvents}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/admin/moderation-events [get]
+func (c *AdminCtrl) moderationEvents(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	events, err := c.user.FindModerationEvents(r.Context(), user.ModerationEventFilter{
+		UserID: r.URL.Query().Get("userId"),
+		Page:   page,
+		Limit:  limit,
+	})
+	if err != nil {
+		logger.Error("failed to find moderation events", "error", err)
+		ErrorJSON(w, "failed to find moderation events", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully fetched moderation events",
+		Data:    events,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,323 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/heroticket/internal/logger"
+	"github.com/heroticket/internal/service/checkin"
+	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/ticket"
+	"github.com/heroticket/internal/service/user"
+)
+
+type CheckinCtrl struct {
+	checkins checkin.Service
+	jwt      jwt.Service
+	networks *ticket.Networks
+	user     user.Service
+}
+
+func NewCheckinCtrl(checkins checkin.Service, jwt jwt.Service, networks *ticket.Networks, user user.Service) *CheckinCtrl {
+	return &CheckinCtrl{
+		checkins: checkins,
+		jwt:      jwt,
+		networks: networks,
+		user:     user,
+	}
+}
+
+func (c *CheckinCtrl) Pattern() string {
+	return "/checkins"
+}
+
+func (c *CheckinCtrl) Handler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(TokenRequired(c.jwt))
+	r.Get("/{contractAddress}", c.listCheckins)
+	r.Post("/{contractAddress}/{id}/undo", c.undoCheckin)
+	r.Get("/{contractAddress}/policy", c.policy)
+	r.Put("/{contractAddress}/policy", c.savePolicy)
+
+	return r
+}
+
+// Checkins godoc
+//
+// @Tags			checkins
+// @Summary		returns check-ins of a ticket collection
+// @Description	returns check-ins of a ticket collection, issuer only
+// @Accept			json
+// @Produce		json
+// @Param			contractAddress	path	string	true	"contract address"
+// @Param			page			query	int		false	"page number"
+// @Param			limit			query	int		false	"page size"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=checkin.Checkins}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/checkins/{contractAddress} [get]
+func (c *CheckinCtrl) listCheckins(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. check that the user issued the collection
+	collection, ok := c.issuedCollection(w, r, tickets)
+	if !ok {
+		return
+	}
+
+	// 2. get pagination from query
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 3. find check-ins
+	checkins, err := c.checkins.FindCheckins(r.Context(), checkin.CheckinFilter{
+		ChainID:         tickets.ChainID(),
+		ContractAddress: collection.ContractAddress,
+		Page:            page,
+		Limit:           limit,
+	})
+	if err != nil {
+		logger.Error("failed to find check-ins", "error", err)
+		ErrorJSON(w, "failed to find check-ins", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully retrieved check-ins",
+		Data:    checkins,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// UndoCheckin godoc
+//
+// @Tags			checkins
+// @Summary		undoes the last entry of a check-in
+// @Description	undoes the last entry of a check-in, issuer only
+// @Accept			json
+// @Produce		json
+// @Param			contractAddress	path	string	true	"contract address"
+// @Param			id				path	string	true	"check-in id"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=checkin.Checkin}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		409			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/checkins/{contractAddress}/{id}/undo [post]
+func (c *CheckinCtrl) undoCheckin(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. check that the user issued the collection
+	collection, ok := c.issuedCollection(w, r, tickets)
+	if !ok {
+		return
+	}
+
+	// 2. get check-in id from path, it must belong to the collection
+	id := strings.ToLower(chi.URLParam(r, "id"))
+
+	if !strings.HasPrefix(id, checkin.Key(tickets.ChainID(), collection.ContractAddress)+"-") {
+		ErrorJSON(w, "check-in not found", http.StatusNotFound)
+		return
+	}
+
+	// 3. undo the last entry
+	entry, err := c.checkins.Undo(r.Context(), id)
+	if err != nil {
+		switch err {
+		case checkin.ErrCheckinNotFound:
+			ErrorJSON(w, "check-in not found", http.StatusNotFound)
+		case checkin.ErrNothingToUndo, checkin.ErrCheckinChanged:
+			ErrorJSON(w, err.Error(), http.StatusConflict)
+		default:
+			logger.Error("failed to undo check-in", "error", err)
+			ErrorJSON(w, "failed to undo check-in", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully undid check-in",
+		Data:    entry,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// Policy godoc
+//
+// @Tags			checkins
+// @Summary		returns the re-entry policy of a ticket collection
+// @Description	returns the re-entry policy of a ticket collection, single entry unless the issuer set one
+// @Accept			json
+// @Produce		json
+// @Param			contractAddress	path	string	true	"contract address"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=checkin.Policy}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/checkins/{contractAddress}/policy [get]
+func (c *CheckinCtrl) policy(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. check that the user issued the collection
+	collection, ok := c.issuedCollection(w, r, tickets)
+	if !ok {
+		return
+	}
+
+	// 2. find policy
+	policy, err := c.checkins.FindPolicy(r.Context(), tickets.ChainID(), collection.ContractAddress)
+	if err != nil {
+		logger.Error("failed to find re-entry policy", "error", err)
+		ErrorJSON(w, "failed to find re-entry policy", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully retrieved re-entry policy",
+		Data:    policy,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+type SavePolicyRequest struct {
+	// Type is "single", "multi" or "daily"
+	Type       string `json:"type"`
+	MaxEntries int    `json:"maxEntries"`
+	// Timezone is the IANA zone days are counted in for "daily", UTC when empty
+	Timezone string `json:"timezone"`
+}
+
+// SavePolicy godoc
+//
+// @Tags			checkins
+// @Summary		sets the re-entry policy of a ticket collection
+// @Description	sets the re-entry policy of a ticket collection, issuer only
+// @Accept			json
+// @Produce		json
+// @Param			contractAddress	path	string				true	"contract address"
+// @Param			request			body	SavePolicyRequest	true	"re-entry policy"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=checkin.Policy}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/checkins/{contractAddress}/policy [put]
+func (c *CheckinCtrl) savePolicy(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. check that the user issued the collection
+	collection, ok := c.issuedCollection(w, r, tickets)
+	if !ok {
+		return
+	}
+
+	// 2. read policy from body
+	var req SavePolicyRequest
+
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "invalid request body")
+		return
+	}
+
+	// 3. save policy
+	policy := &checkin.Policy{
+		ChainID:         tickets.ChainID(),
+		ContractAddress: collection.ContractAddress,
+		Type:            req.Type,
+		MaxEntries:      req.MaxEntries,
+		Timezone:        req.Timezone,
+	}
+
+	if err := c.checkins.SavePolicy(r.Context(), policy); err != nil {
+		if errors.Is(err, checkin.ErrInvalidPolicy) {
+			ErrorJSON(w, err.Error())
+			return
+		}
+		logger.Error("failed to save re-entry policy", "error", err)
+		ErrorJSON(w, "failed to save re-entry policy", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully saved re-entry policy",
+		Data:    policy,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// issuedCollection returns the collection named by the contractAddress path param when the
+// requesting user issued it, writing the error response otherwise.
+func (c *CheckinCtrl) issuedCollection(w http.ResponseWriter, r *http.Request, tickets ticket.Service) (*ticket.TicketCollection, bool) {
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		ErrorJSON(w, "user not found")
+		return nil, false
+	}
+
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
+	if err != nil {
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	rawContractAddress := strings.ToLower(chi.URLParam(r, "contractAddress"))
+
+	collection, err := tickets.FindTicketCollectionByContractAddress(r.Context(), rawContractAddress)
+	if err != nil {
+		if err == ticket.ErrTicketCollectionNotFound {
+			ErrorJSON(w, "ticket collection not found", http.StatusBadRequest)
+			return nil, false
+		}
+		logger.Error("failed to find ticket collection by contract address", "error", err)
+		ErrorJSON(w, "failed to find ticket collection by contract address", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !u.HasWallet(collection.IssuerAddress) {
+		ErrorJSON(w, "only the issuer can manage check-ins", http.StatusForbidden)
+		return nil, false
+	}
+
+	return collection, true
+}
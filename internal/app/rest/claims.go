@@ -14,18 +14,18 @@ import (
 )
 
 type ClaimCtrl struct {
-	did    did.Service
-	jwt    jwt.Service
-	ticket ticket.Service
-	user   user.Service
+	did      did.Service
+	jwt      jwt.Service
+	networks *ticket.Networks
+	user     user.Service
 }
 
-func NewClaimCtrl(did did.Service, jwt jwt.Service, ticket ticket.Service, user user.Service) *ClaimCtrl {
+func NewClaimCtrl(did did.Service, jwt jwt.Service, networks *ticket.Networks, user user.Service) *ClaimCtrl {
 	return &ClaimCtrl{
-		did:    did,
-		jwt:    jwt,
-		ticket: ticket,
-		user:   user,
+		did:      did,
+		jwt:      jwt,
+		networks: networks,
+		user:     user,
 	}
 }
 
@@ -50,6 +50,7 @@ func (c *ClaimCtrl) Handler() http.Handler {
 // @Accept			json
 // @Produce			json
 // @Param			contractAddress	path	string	true	"contract address"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
 // @Success			201	{object}	CommonResponse{data=did.CreateClaimResponse}
 // @Success			202	{object}	CommonResponse
 // @Failure			400	{object}	CommonResponse
@@ -59,6 +60,12 @@ func (c *ClaimCtrl) Handler() http.Handler {
 // @Security 		BearerAuth
 // @Router			/v1/claims/{contractAddress}	[post]
 func (c *ClaimCtrl) requestClaim(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get jwt user from context
 	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
@@ -95,11 +102,10 @@ func (c *ClaimCtrl) requestClaim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 5. check if user's tba has ticket with contract address
+	// 5. check if the tba of any linked wallet has ticket with contract address
 	contractAddress := web3.HexToAddress(rawContractAddress)
-	tbaAddress := web3.HexToAddress(u.TbaAddress)
 
-	ok, err := c.ticket.HasTicket(r.Context(), contractAddress, tbaAddress)
+	_, ok, err := heldTicket(r.Context(), tickets, contractAddress, u)
 	if err != nil {
 		logger.Error("failed to check if user has ticket", "error", err)
 		ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
@@ -1,14 +1,25 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/heroticket/internal/pagination"
+	"github.com/heroticket/internal/service/ticket"
+	"github.com/heroticket/internal/service/user"
+	"github.com/heroticket/internal/web3"
 )
 
 var (
 	ErrNotSingleJSONValue = errors.New("request body must contain a single JSON value")
+	ErrInvalidPage        = errors.New("invalid page")
+	ErrInvalidLimit       = errors.New("invalid limit")
+	ErrInvalidChainID     = errors.New("invalid chain id")
 )
 
 const MaxBodyBytes = 1024 * 1024 // 1MB
@@ -81,3 +92,124 @@ func ErrorJSON(w http.ResponseWriter, errMsg string, status ...int) {
 
 	_ = WriteJSON(w, statusCode, resp, "error")
 }
+
+// ReadPagination reads page and limit query params, falling back to the defaults when absent
+func ReadPagination(r *http.Request) (page, limit int64, err error) {
+	page, limit = pagination.DefaultPage, pagination.DefaultLimit
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		page, err = strconv.ParseInt(pageStr, 10, 64)
+		if err != nil {
+			return 0, 0, ErrInvalidPage
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit < 1 {
+			return 0, 0, ErrInvalidLimit
+		}
+	}
+
+	return page, limit, nil
+}
+
+// ReadNetwork returns the ticket service of the network named by the chainId query param,
+// the default network when the param is absent.
+func ReadNetwork(r *http.Request, networks *ticket.Networks) (ticket.Service, error) {
+	var chainID int64
+
+	if raw := r.URL.Query().Get("chainId"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, ErrInvalidChainID
+		}
+
+		chainID = id
+	}
+
+	return networks.Get(chainID)
+}
+
+// heldTicket returns the tba of the linked wallet of u holding a ticket of the collection.
+func heldTicket(ctx context.Context, tickets ticket.Service, contractAddress common.Address, u *user.User) (string, bool, error) {
+	return firstTba(ctx, u, func(tba common.Address) (bool, error) {
+		return tickets.HasTicket(ctx, contractAddress, tba)
+	})
+}
+
+// whitelistedTba returns the tba of the linked wallet of u on the whitelist of the collection.
+func whitelistedTba(ctx context.Context, tickets ticket.Service, contractAddress common.Address, u *user.User) (string, bool, error) {
+	return firstTba(ctx, u, func(tba common.Address) (bool, error) {
+		return tickets.IsWhitelisted(ctx, contractAddress, tba)
+	})
+}
+
+// firstTba returns the first tba of the linked wallets of u that matches, checking the primary one first.
+func firstTba(ctx context.Context, u *user.User, match func(tba common.Address) (bool, error)) (string, bool, error) {
+	for _, tba := range u.TbaAddresses() {
+		if err := ctx.Err(); err != nil {
+			return "", false, err
+		}
+
+		ok, err := match(web3.HexToAddress(tba))
+		if err != nil {
+			return "", false, err
+		}
+
+		if ok {
+			return tba, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// ownedNFTs returns the NFTs held by the tbas of every linked wallet of u.
+func ownedNFTs(ctx context.Context, tickets ticket.Service, u *user.User) ([]ticket.NFT, error) {
+	var nfts []ticket.NFT
+
+	for _, w := range u.LinkedWallets() {
+		owned, err := tickets.GetOwnedNFT(ctx, web3.HexToAddress(w.AccountAddress))
+		if err != nil {
+			return nil, err
+		}
+
+		nfts = append(nfts, owned.NFTs...)
+	}
+
+	return nfts, nil
+}
+
+// issuedCollections returns the ticket collections issued by every linked wallet of u on every network.
+func issuedCollections(ctx context.Context, networks *ticket.Networks, u *user.User) ([]*ticket.TicketCollection, error) {
+	issued := []*ticket.TicketCollection{}
+
+	for _, chainID := range networks.ChainIDs() {
+		tickets, err := networks.Get(chainID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, w := range u.LinkedWallets() {
+			for page := pagination.DefaultPage; ; page++ {
+				collections, err := tickets.FindTicketCollections(ctx, ticket.TicketCollectionFilter{
+					IssuerAddress: w.AccountAddress,
+					Page:          page,
+					Limit:         pagination.MaxLimit,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				issued = append(issued, collections.Items...)
+
+				if !collections.Pagination.HasNext {
+					break
+				}
+			}
+		}
+	}
+
+	return issued, nil
+}
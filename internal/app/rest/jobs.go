@@ -0,0 +1,520 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/heroticket/internal/app/ws"
+	"github.com/heroticket/internal/logger"
+	"github.com/heroticket/internal/service/job"
+	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/ticket"
+	"github.com/heroticket/internal/service/user"
+	"github.com/heroticket/internal/web3"
+)
+
+// job types double as the ws event names their completion is pushed with
+const (
+	jobIssueTicket      = "create-ticket"
+	jobUpdateWhitelist  = "whitelist-callback"
+	jobBuyTicketByToken = "token-purchase-callback"
+	jobEthPurchase      = "eth-purchase"
+	jobRegister         = "register"
+	jobLinkWallet       = "link-wallet"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type JobCtrl struct {
+	jobs job.Service
+	jwt  jwt.Service
+}
+
+func NewJobCtrl(jobs job.Service, jwt jwt.Service) *JobCtrl {
+	return &JobCtrl{
+		jobs: jobs,
+		jwt:  jwt,
+	}
+}
+
+func (c *JobCtrl) Pattern() string {
+	return "/jobs"
+}
+
+func (c *JobCtrl) Handler() http.Handler {
+	r := chi.NewRouter()
+
+	r.With(TokenRequired(c.jwt)).Get("/{id}", c.job)
+
+	return r
+}
+
+// Job godoc
+//
+// @Tags			jobs
+// @Summary		returns job status
+// @Description	returns the status and result of an asynchronous job
+// @Accept			json
+// @Produce		json
+// @Param			id	path		string	true	"job id"
+// @Success		200	{object}	CommonResponse{data=job.Job}
+// @Failure		404	{object}	CommonResponse
+// @Failure		500	{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/jobs/{id} [get]
+func (c *JobCtrl) job(w http.ResponseWriter, r *http.Request) {
+	// 1. get jwt user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get jwt user from context", "error", err)
+		ErrorJSON(w, "failed to get jwt user from context", http.StatusInternalServerError)
+		return
+	}
+
+	// 2. get job from db
+	j, err := c.jobs.FindJobByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if err == job.ErrJobNotFound {
+			ErrorJSON(w, "job not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to find job", "error", err)
+		ErrorJSON(w, "failed to find job", http.StatusInternalServerError)
+		return
+	}
+
+	// 3. hide other users' jobs
+	if j.UserID != jwtUser.ID {
+		ErrorJSON(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	// 4. return job
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully retrieved job",
+		Data:    j,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// NotifyJob pushes the final status of a job to the ws session that requested it.
+func NotifyJob(j *job.Job) {
+	id := ws.ID(j.SessionID)
+	if !id.Valid() {
+		return
+	}
+
+	status := ws.Done
+	if j.Status == job.StatusFailed {
+		status = ws.Error
+	}
+
+	ws.Send(ws.Message{
+		ID:   id,
+		Type: ws.EventMessage,
+		Event: ws.Event{
+			Name:   j.Type,
+			Status: status,
+			Data:   j,
+		},
+	})
+}
+
+// writeJobAccepted responds with 202 and the job the request was queued as
+func writeJobAccepted(w http.ResponseWriter, j *job.Job) {
+	resp := CommonResponse{
+		Status:  http.StatusAccepted,
+		Message: fmt.Sprintf("Request accepted as job %s", j.ID),
+		Data:    j,
+	}
+
+	_ = WriteJSON(w, http.StatusAccepted, resp)
+}
+
+// jobKey returns the idempotency key of a request: the Idempotency-Key header when the client sent one,
+// otherwise a hash of the parts identifying the request. A derived key only dedupes the request while
+// its job is unfinished, so renew reports whether the key was derived.
+func jobKey(r *http.Request, jobType, userID string, parts ...string) (key string, renew bool) {
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+		return fmt.Sprintf("%s:%s:%s", jobType, userID, key), false
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+
+	return fmt.Sprintf("%s:%s:%s", jobType, userID, hex.EncodeToString(sum[:])), true
+}
+
+// chainWriteError prefixes errors of server transactions with the message clients are shown for them.
+func chainWriteError(err error) error {
+	if errors.Is(err, web3.ErrMaxFeeExceeded) {
+		return fmt.Errorf("%s: %w", maxFeeExceededMessage, err)
+	}
+
+	return err
+}
+
+type issueTicketPayload struct {
+	ChainID       int64  `json:"chainId"`
+	IssuerAddress string `json:"issuerAddress"`
+	Name          string `json:"name"`
+	Symbol        string `json:"symbol"`
+	Description   string `json:"description"`
+	Organizer     string `json:"organizer"`
+	Location      string `json:"location"`
+	Date          string `json:"date"`
+	BannerUrl     string `json:"bannerUrl"`
+	TicketUri     string `json:"ticketUri"`
+	EthPrice      string `json:"ethPrice"`
+	TokenPrice    string `json:"tokenPrice"`
+	TotalSupply   string `json:"totalSupply"`
+	SaleDuration  uint64 `json:"saleDuration"`
+	// ProofPolicy is saved with the collection, nil when it asks for no extra proofs
+	ProofPolicy *ticket.ProofPolicy `json:"proofPolicy,omitempty"`
+}
+
+type issueTicketState struct {
+	TicketAddress string `json:"ticketAddress"`
+}
+
+type whitelistPayload struct {
+	ChainID         int64  `json:"chainId"`
+	ContractAddress string `json:"contractAddress"`
+	AccountAddress  string `json:"accountAddress"`
+}
+
+type tokenPurchasePayload struct {
+	ChainID         int64  `json:"chainId"`
+	ContractAddress string `json:"contractAddress"`
+	AccountAddress  string `json:"accountAddress"`
+	TbaAddress      string `json:"tbaAddress"`
+}
+
+type ethPurchasePayload struct {
+	ChainID         int64  `json:"chainId"`
+	ContractAddress string `json:"contractAddress"`
+	AccountAddress  string `json:"accountAddress"`
+	TxHash          string `json:"txHash"`
+}
+
+type registerPayload struct {
+	UserID         string `json:"userId"`
+	AccountAddress string `json:"accountAddress"`
+	Avatar         string `json:"avatar"`
+}
+
+type linkWalletPayload struct {
+	UserID         string `json:"userId"`
+	AccountAddress string `json:"accountAddress"`
+	Avatar         string `json:"avatar"`
+}
+
+// RegisterJobHandlers registers the chain writes of ticket requests with the job service.
+func (c *TicketCtrl) RegisterJobHandlers() {
+	c.jobs.Handle(jobIssueTicket, c.issueTicketJob)
+	c.jobs.Handle(jobUpdateWhitelist, c.updateWhitelistJob)
+	c.jobs.Handle(jobBuyTicketByToken, c.buyTicketByTokenJob)
+	c.jobs.Handle(jobEthPurchase, c.ethPurchaseJob)
+}
+
+// issueTicketJob issues a ticket collection and saves it. The issued address is saved as job state,
+// so a retry only redoes the db write instead of issuing a second collection.
+func (c *TicketCtrl) issueTicketJob(ctx context.Context, j *job.Job) (any, error) {
+	var p issueTicketPayload
+
+	if err := j.DecodePayload(&p); err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	tickets, err := c.network(p.ChainID)
+	if err != nil {
+		return nil, err
+	}
+
+	ethPrice, _ := big.NewInt(0).SetString(p.EthPrice, 10)
+	tokenPrice, _ := big.NewInt(0).SetString(p.TokenPrice, 10)
+	totalSupply, _ := big.NewInt(0).SetString(p.TotalSupply, 10)
+
+	if ethPrice == nil || tokenPrice == nil || totalSupply == nil {
+		return nil, job.Permanent(errors.New("invalid ticket prices or supply"))
+	}
+
+	var state issueTicketState
+
+	if err := j.DecodeState(&state); err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	// 1. call contract to create new ticket collection
+	if state.TicketAddress == "" {
+		ticketIssued, err := tickets.IssueTicket(ctx, ticket.IssueTicketParams{
+			TicketName:       p.Name,
+			TicketSymbol:     p.Symbol,
+			TicketUri:        p.TicketUri,
+			Issuer:           web3.HexToAddress(p.IssuerAddress),
+			TicketAmount:     totalSupply,
+			TicketEthPrice:   ethPrice,
+			TicketTokenPrice: tokenPrice,
+			SaleDuration:     big.NewInt(0).Mul(big.NewInt(int64(p.SaleDuration)), big.NewInt(86400)),
+		})
+		if err != nil {
+			if errors.Is(err, ticket.ErrTxNotConfirmed) {
+				// the collection may still be issued, the subscriber indexes it when it is
+				return nil, job.Permanent(err)
+			}
+			return nil, chainWriteError(err)
+		}
+
+		state.TicketAddress = strings.ToLower(ticketIssued.TicketAddress.Hex())
+
+		if err := c.jobs.SaveState(ctx, j, state); err != nil {
+			logger.Error("failed to save issue ticket job state", "id", j.ID, "error", err)
+		}
+	}
+
+	// 2. get onchain ticket collection data
+	onchainTicket, err := tickets.OnChainTicketInfo(ctx, web3.HexToAddress(state.TicketAddress))
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. save ticket collection to db
+	return tickets.CreateTicketCollection(ctx, ticket.CreateTicketCollectionParams{
+		ContractAddress: state.TicketAddress,
+		IssuerAddress:   p.IssuerAddress,
+		Name:            p.Name,
+		Symbol:          p.Symbol,
+		Description:     p.Description,
+		Organizer:       p.Organizer,
+		Location:        p.Location,
+		Date:            p.Date,
+		BannerUrl:       p.BannerUrl,
+		TicketUrl:       p.TicketUri,
+		EthPrice:        p.EthPrice,
+		TokenPrice:      p.TokenPrice,
+		TotalSupply:     p.TotalSupply,
+		Remaining:       onchainTicket.Remaining.String(),
+		SaleStartAt:     onchainTicket.SaleStartAt.Int64(),
+		SaleEndAt:       onchainTicket.SaleEndAt.Int64(),
+		ProofPolicy:     p.ProofPolicy,
+	})
+}
+
+func (c *TicketCtrl) updateWhitelistJob(ctx context.Context, j *job.Job) (any, error) {
+	var p whitelistPayload
+
+	if err := j.DecodePayload(&p); err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	tickets, err := c.network(p.ChainID)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddress := web3.HexToAddress(p.ContractAddress)
+	accountAddress := web3.HexToAddress(p.AccountAddress)
+
+	// 1. skip if a previous attempt already whitelisted the account
+	ok, err := tickets.IsWhitelisted(ctx, contractAddress, accountAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. call contract to set user address on whitelist
+	if !ok {
+		if err := tickets.UpdateWhitelist(ctx, contractAddress, accountAddress); err != nil {
+			return nil, chainWriteError(err)
+		}
+	}
+
+	return "Successfully updated whitelist", nil
+}
+
+func (c *TicketCtrl) buyTicketByTokenJob(ctx context.Context, j *job.Job) (any, error) {
+	var p tokenPurchasePayload
+
+	if err := j.DecodePayload(&p); err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	tickets, err := c.network(p.ChainID)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddress := web3.HexToAddress(p.ContractAddress)
+
+	// 1. skip if a previous attempt already bought the ticket
+	ok, err := tickets.HasTicket(ctx, contractAddress, web3.HexToAddress(p.TbaAddress))
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. call contract to mint token
+	if !ok {
+		if _, err := tickets.BuyTicketByToken(ctx, contractAddress, web3.HexToAddress(p.AccountAddress)); err != nil {
+			return nil, chainWriteError(err)
+		}
+	}
+
+	// 3. sync remaining tickets of collection
+	if _, err := tickets.SyncTicketCollection(ctx, contractAddress); err != nil {
+		logger.Error("failed to sync ticket collection", "error", err)
+	}
+
+	return "Successfully purchased ticket", nil
+}
+
+// ethPurchaseJob waits for a purchase the buyer sent from their own wallet to be mined.
+// It only reads the chain, so a retry simply waits again.
+func (c *TicketCtrl) ethPurchaseJob(ctx context.Context, j *job.Job) (any, error) {
+	var p ethPurchasePayload
+
+	if err := j.DecodePayload(&p); err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	tickets, err := c.network(p.ChainID)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddress := web3.HexToAddress(p.ContractAddress)
+
+	// 1. wait for transaction and get sold ticket
+	sold, err := tickets.ConfirmBuyTicketByEther(ctx, web3.HexToHash(p.TxHash), contractAddress, web3.HexToAddress(p.AccountAddress))
+	if err != nil {
+		switch err {
+		case ticket.ErrInvalidPurchaseTx, ticket.ErrTxFailed, ticket.ErrTicketSoldNotFound:
+			return nil, job.Permanent(err)
+		}
+		return nil, err
+	}
+
+	// 2. sync remaining tickets of collection
+	if _, err := tickets.SyncTicketCollection(ctx, contractAddress); err != nil {
+		logger.Error("failed to sync ticket collection", "error", err)
+	}
+
+	return fmt.Sprintf("Successfully purchased ticket #%s", sold.TicketId), nil
+}
+
+// network returns the ticket service of the network a job was enqueued for.
+func (c *TicketCtrl) network(chainID int64) (ticket.Service, error) {
+	tickets, err := c.networks.Get(chainID)
+	if err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	return tickets, nil
+}
+
+// RegisterJobHandlers registers the chain writes of user requests with the job service.
+func (c *UserCtrl) RegisterJobHandlers() {
+	c.jobs.Handle(jobRegister, c.registerJob)
+	c.jobs.Handle(jobLinkWallet, c.linkWalletJob)
+}
+
+// registerJob creates the tba of the account when it has none and then the user.
+// Both steps are skipped when a previous attempt already did them.
+func (c *UserCtrl) registerJob(ctx context.Context, j *job.Job) (any, error) {
+	var p registerPayload
+
+	if err := j.DecodePayload(&p); err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	// 1. return the user if a previous attempt already created it
+	u, err := c.user.FindUserByID(ctx, p.UserID)
+	if err == nil {
+		return u, nil
+	}
+
+	if err != user.ErrUserNotFound {
+		return nil, err
+	}
+
+	// 2. find or create the tba of the account
+	tba, err := c.walletTba(ctx, p.AccountAddress, p.Avatar)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenBalance, err := c.ticket.TokenBalanceOf(ctx, *tba)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. create user
+	return c.user.CreateUser(ctx, user.CreateUserParams{
+		ID:              p.UserID,
+		AccountAddress:  p.AccountAddress,
+		TbaAddress:      strings.ToLower(tba.Hex()),
+		Name:            p.AccountAddress,
+		Avatar:          p.Avatar,
+		TbaTokenBalance: tokenBalance.String(),
+		IsAdmin:         false,
+	})
+}
+
+// linkWalletJob creates the tba of the account when it has none and then links the wallet.
+// Linking a wallet the user already has is a no-op, so retries are safe.
+func (c *UserCtrl) linkWalletJob(ctx context.Context, j *job.Job) (any, error) {
+	var p linkWalletPayload
+
+	if err := j.DecodePayload(&p); err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	// 1. find or create the tba of the account
+	tba, err := c.walletTba(ctx, p.AccountAddress, p.Avatar)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. link the wallet
+	u, err := c.user.LinkWallet(ctx, p.UserID, user.Wallet{
+		AccountAddress: p.AccountAddress,
+		TbaAddress:     strings.ToLower(tba.Hex()),
+	})
+	if err != nil {
+		switch err {
+		case user.ErrUserNotFound, user.ErrWalletAlreadyLinked:
+			return nil, job.Permanent(err)
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// walletTba returns the tba of the account, creating it when the account has none.
+func (c *UserCtrl) walletTba(ctx context.Context, accountAddress, avatar string) (*common.Address, error) {
+	account := web3.HexToAddress(accountAddress)
+
+	tba, err := c.ticket.TbaByAddress(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	if tba == nil || tba.Big().Cmp(big.NewInt(0)) == 0 {
+		tbaCreated, err := c.ticket.CreateTBA(ctx, account, avatar)
+		if err != nil {
+			return nil, chainWriteError(err)
+		}
+
+		tba = &tbaCreated.Account
+	}
+
+	return tba, nil
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/user"
 	"go.uber.org/zap"
 )
 
@@ -33,8 +34,12 @@ func TokenRequired(jwtSvc jwt.Service) func(next http.Handler) http.Handler {
 
 			token := headerParts[1]
 
-			jwtUser, err := jwtSvc.VerifyToken(token, jwt.TokenRoleAccess)
+			jwtUser, err := jwtSvc.VerifyToken(r.Context(), token, jwt.TokenRoleAccess)
 			if err != nil {
+				if err == jwt.ErrUserSuspended {
+					ErrorJSON(w, "account suspended", http.StatusForbidden)
+					return
+				}
 				zap.L().Error("failed to validate access token", zap.Error(err))
 				ErrorJSON(w, "unauthorized", http.StatusUnauthorized)
 				return
@@ -70,7 +75,7 @@ func TokenCheck(jwtSvc jwt.Service) func(next http.Handler) http.Handler {
 
 				token := headerParts[1]
 
-				jwtUser, err := jwtSvc.VerifyToken(token, jwt.TokenRoleAccess)
+				jwtUser, err := jwtSvc.VerifyToken(r.Context(), token, jwt.TokenRoleAccess)
 				if err != nil {
 					zap.L().Error("failed to validate access token", zap.Error(err))
 					return
@@ -85,3 +90,46 @@ func TokenCheck(jwtSvc jwt.Service) func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+// RequireRole lets requests whose access token carries one of roles through. It must follow TokenRequired.
+func RequireRole(jwtSvc jwt.Service, roles ...user.Role) func(next http.Handler) http.Handler {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+
+	return require(jwtSvc, func(u *jwt.JWTUser) bool {
+		return u.HasRole(names...)
+	})
+}
+
+// RequirePermission lets requests whose access token carries one of perms through. It must follow TokenRequired.
+func RequirePermission(jwtSvc jwt.Service, perms ...user.Permission) func(next http.Handler) http.Handler {
+	names := make([]string, len(perms))
+	for i, p := range perms {
+		names[i] = string(p)
+	}
+
+	return require(jwtSvc, func(u *jwt.JWTUser) bool {
+		return u.HasPermission(names...)
+	})
+}
+
+func require(jwtSvc jwt.Service, allowed func(u *jwt.JWTUser) bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			jwtUser, err := jwtSvc.FromContext(r.Context())
+			if err != nil {
+				ErrorJSON(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !allowed(jwtUser) {
+				ErrorJSON(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
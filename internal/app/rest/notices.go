@@ -6,17 +6,20 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/heroticket/internal/logger"
+	"github.com/heroticket/internal/service/jwt"
 	"github.com/heroticket/internal/service/notice"
 	"github.com/heroticket/internal/service/user"
 )
 
 type NoticeCtrl struct {
+	jwt    jwt.Service
 	notice notice.Service
 	user   user.Service
 }
 
-func NewNoticeCtrl(notice notice.Service, user user.Service) *NoticeCtrl {
+func NewNoticeCtrl(jwt jwt.Service, notice notice.Service, user user.Service) *NoticeCtrl {
 	return &NoticeCtrl{
+		jwt:    jwt,
 		notice: notice,
 		user:   user,
 	}
@@ -32,16 +35,26 @@ func (c *NoticeCtrl) Handler() http.Handler {
 	r.Get("/", c.Notices)
 	r.Get("/{id}", c.Notice)
 
+	r.Group(func(r chi.Router) {
+		r.Use(TokenRequired(c.jwt))
+		r.Use(RequirePermission(c.jwt, user.PermissionManageNotices))
+		r.Get("/all", c.allNotices)
+		r.Post("/", c.createNotice)
+		r.Put("/{id}", c.updateNotice)
+		r.Delete("/{id}", c.deleteNotice)
+	})
+
 	return r
 }
 
 // Notices godoc
 //
 // @Summary Get notices
-// @Description returns notices paginated
+// @Description returns published notices paginated, pinned ones first
 // @Tags notices
 // @Accept json
 // @Produce json
+// @Param category query string false "notice category"
 // @Param page query int false "page number"
 // @Param limit query int false "page size"
 // @Success 200 {object} CommonResponse{data=notice.Notices}
@@ -49,38 +62,13 @@ func (c *NoticeCtrl) Handler() http.Handler {
 // @Failure 500 {object} CommonResponse
 // @Router /v1/notices [get]
 func (c *NoticeCtrl) Notices(w http.ResponseWriter, r *http.Request) {
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
-
-	var page, limit int64
-
-	if pageStr == "" {
-		page = 1
-	} else {
-		pageInt, err := strconv.ParseInt(pageStr, 10, 64)
-		if err != nil {
-			logger.Error("invalid page", "error", err)
-			ErrorJSON(w, "invalid page")
-			return
-		}
-
-		page = pageInt
-	}
-
-	if limitStr == "" {
-		limit = 10
-	} else {
-		limitInt, err := strconv.ParseInt(limitStr, 10, 64)
-		if err != nil {
-			logger.Error("invalid limit", "error", err)
-			ErrorJSON(w, "invalid limit")
-			return
-		}
-
-		limit = limitInt
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
 	}
 
-	notices, err := c.notice.GetNotices(r.Context(), page, limit)
+	notices, err := c.notice.GetPublishedNotices(r.Context(), r.URL.Query().Get("category"), page, limit)
 	if err != nil {
 		logger.Error("failed to get notices", "error", err)
 		ErrorJSON(w, "failed to get notices", http.StatusInternalServerError)
@@ -99,24 +87,29 @@ func (c *NoticeCtrl) Notices(w http.ResponseWriter, r *http.Request) {
 // Notice godoc
 //
 // @Summary Get notice
-// @Description returns notice by id
+// @Description returns a published notice by id
 // @Tags notices
 // @Accept json
 // @Produce json
 // @Param id path int true "notice id"
 // @Success 200 {object} CommonResponse{data=notice.Notice}
 // @Failure 400 {object} CommonResponse
+// @Failure 404 {object} CommonResponse
 // @Failure 500 {object} CommonResponse
 // @Router /v1/notices/{id} [get]
 func (c *NoticeCtrl) Notice(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		ErrorJSON(w, "invalid notice id")
+		return
+	}
 
-	n, err := c.notice.GetNotice(r.Context(), id)
+	n, err := c.notice.GetPublishedNotice(r.Context(), id)
 	if err != nil {
-		logger.Error("failed to get notice", "error", err)
 		if err == notice.ErrNotFound {
 			ErrorJSON(w, "notice not found", http.StatusNotFound)
 		} else {
+			logger.Error("failed to get notice", "error", err)
 			ErrorJSON(w, "failed to get notice", http.StatusInternalServerError)
 		}
 		return
@@ -130,3 +123,245 @@ func (c *NoticeCtrl) Notice(w http.ResponseWriter, r *http.Request) {
 
 	_ = WriteJSON(w, http.StatusOK, resp)
 }
+
+// AllNotices godoc
+//
+// @Summary Get all notices
+// @Description returns notices paginated including drafts and scheduled ones, requires the notices:manage permission
+// @Tags notices
+// @Accept json
+// @Produce json
+// @Param status query string false "draft or published"
+// @Param category query string false "notice category"
+// @Param page query int false "page number"
+// @Param limit query int false "page size"
+// @Success 200 {object} CommonResponse{data=notice.Notices}
+// @Failure 400 {object} CommonResponse
+// @Failure 403 {object} CommonResponse
+// @Failure 500 {object} CommonResponse
+// @Security BearerAuth
+// @Router /v1/notices/all [get]
+func (c *NoticeCtrl) allNotices(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	status := notice.Status(r.URL.Query().Get("status"))
+	if status != "" && !status.Valid() {
+		ErrorJSON(w, notice.ErrInvalidStatus.Error())
+		return
+	}
+
+	notices, err := c.notice.GetNotices(r.Context(), notice.NoticeFilter{
+		Category: r.URL.Query().Get("category"),
+		Status:   status,
+		Page:     page,
+		Limit:    limit,
+	})
+	if err != nil {
+		logger.Error("failed to get notices", "error", err)
+		ErrorJSON(w, "failed to get notices", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "notices retrieved",
+		Data:    notices,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+type CreateNoticeRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// Status is draft or published, draft when empty
+	Status notice.Status `json:"status"`
+	// PublishAt schedules a published notice, in unix seconds
+	PublishAt int64  `json:"publishAt"`
+	Pinned    bool   `json:"pinned"`
+	Category  string `json:"category"`
+}
+
+// CreateNotice godoc
+//
+// @Summary Create notice
+// @Description creates a notice as a draft or published, optionally scheduled with publishAt, requires the notices:manage permission
+// @Tags notices
+// @Accept json
+// @Produce json
+// @Param request body CreateNoticeRequest true "notice"
+// @Success 201 {object} CommonResponse{data=notice.Notice}
+// @Failure 400 {object} CommonResponse
+// @Failure 403 {object} CommonResponse
+// @Failure 500 {object} CommonResponse
+// @Security BearerAuth
+// @Router /v1/notices [post]
+func (c *NoticeCtrl) createNotice(w http.ResponseWriter, r *http.Request) {
+	// 1. get author from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. read request
+	var req CreateNoticeRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "invalid request")
+		return
+	}
+
+	// 3. create notice
+	n, err := c.notice.CreateNotice(r.Context(), notice.CreateNoticeParams{
+		Title:     req.Title,
+		Content:   req.Content,
+		Status:    req.Status,
+		PublishAt: req.PublishAt,
+		Pinned:    req.Pinned,
+		Category:  req.Category,
+		AuthorID:  jwtUser.ID,
+	})
+	if err != nil {
+		if isNoticeValidationError(err) {
+			ErrorJSON(w, err.Error())
+			return
+		}
+		logger.Error("failed to create notice", "error", err)
+		ErrorJSON(w, "failed to create notice", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("notice created", "id", n.ID, "status", n.Status, "by", jwtUser.ID)
+
+	resp := CommonResponse{
+		Status:  http.StatusCreated,
+		Message: "notice created",
+		Data:    n,
+	}
+
+	_ = WriteJSON(w, http.StatusCreated, resp)
+}
+
+// UpdateNoticeRequest changes the fields present in the body. An empty category removes it.
+type UpdateNoticeRequest struct {
+	Title     *string        `json:"title"`
+	Content   *string        `json:"content"`
+	Status    *notice.Status `json:"status"`
+	PublishAt *int64         `json:"publishAt"`
+	Pinned    *bool          `json:"pinned"`
+	Category  *string        `json:"category"`
+}
+
+// UpdateNotice godoc
+//
+// @Summary Update notice
+// @Description updates the fields present in the body, publishing a draft without publishAt makes it public now, requires the notices:manage permission
+// @Tags notices
+// @Accept json
+// @Produce json
+// @Param id path int true "notice id"
+// @Param request body UpdateNoticeRequest true "changes"
+// @Success 200 {object} CommonResponse{data=notice.Notice}
+// @Failure 400 {object} CommonResponse
+// @Failure 403 {object} CommonResponse
+// @Failure 404 {object} CommonResponse
+// @Failure 500 {object} CommonResponse
+// @Security BearerAuth
+// @Router /v1/notices/{id} [put]
+func (c *NoticeCtrl) updateNotice(w http.ResponseWriter, r *http.Request) {
+	// 1. read request
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		ErrorJSON(w, "invalid notice id")
+		return
+	}
+
+	var req UpdateNoticeRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "invalid request")
+		return
+	}
+
+	// 2. update notice
+	n, err := c.notice.UpdateNotice(r.Context(), &notice.NoticeUpdateParams{
+		ID:        id,
+		Title:     req.Title,
+		Content:   req.Content,
+		Status:    req.Status,
+		PublishAt: req.PublishAt,
+		Pinned:    req.Pinned,
+		Category:  req.Category,
+	})
+	if err != nil {
+		switch {
+		case err == notice.ErrNotFound:
+			ErrorJSON(w, "notice not found", http.StatusNotFound)
+		case err == notice.ErrNothingToUpdate, isNoticeValidationError(err):
+			ErrorJSON(w, err.Error())
+		default:
+			logger.Error("failed to update notice", "error", err)
+			ErrorJSON(w, "failed to update notice", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "notice updated",
+		Data:    n,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// DeleteNotice godoc
+//
+// @Summary Delete notice
+// @Description deletes a notice, requires the notices:manage permission
+// @Tags notices
+// @Produce json
+// @Param id path int true "notice id"
+// @Success 200 {object} CommonResponse
+// @Failure 400 {object} CommonResponse
+// @Failure 403 {object} CommonResponse
+// @Failure 404 {object} CommonResponse
+// @Failure 500 {object} CommonResponse
+// @Security BearerAuth
+// @Router /v1/notices/{id} [delete]
+func (c *NoticeCtrl) deleteNotice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		ErrorJSON(w, "invalid notice id")
+		return
+	}
+
+	if err := c.notice.DeleteNotice(r.Context(), id); err != nil {
+		if err == notice.ErrNotFound {
+			ErrorJSON(w, "notice not found", http.StatusNotFound)
+		} else {
+			logger.Error("failed to delete notice", "error", err)
+			ErrorJSON(w, "failed to delete notice", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "notice deleted",
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+func isNoticeValidationError(err error) bool {
+	switch err {
+	case notice.ErrInvalidTitle, notice.ErrInvalidContent, notice.ErrInvalidStatus, notice.ErrInvalidCategory, notice.ErrInvalidPublishAt:
+		return true
+	}
+	return false
+}
@@ -8,12 +8,11 @@ import (
 	"github.com/heroticket/internal/logger"
 	"github.com/heroticket/internal/service/ticket"
 	"github.com/heroticket/internal/service/user"
-	"github.com/heroticket/internal/web3"
 )
 
 type ProfileCtrl struct {
-	ticket ticket.Service
-	user   user.Service
+	networks *ticket.Networks
+	user     user.Service
 }
 
 type ProfileResponse struct {
@@ -22,10 +21,10 @@ type ProfileResponse struct {
 	IssuedTickets []*ticket.TicketCollection `json:"issuedTickets"`
 }
 
-func NewProfileCtrl(ticket ticket.Service, user user.Service) *ProfileCtrl {
+func NewProfileCtrl(networks *ticket.Networks, user user.Service) *ProfileCtrl {
 	return &ProfileCtrl{
-		ticket: ticket,
-		user:   user,
+		networks: networks,
+		user:     user,
 	}
 }
 
@@ -37,6 +36,7 @@ func (c *ProfileCtrl) Handler() http.Handler {
 	r := chi.NewRouter()
 
 	r.Get("/{accountAddress}", c.profile)
+	r.Get("/@{handle}", c.profileByHandle)
 
 	return r
 }
@@ -49,6 +49,7 @@ func (c *ProfileCtrl) Handler() http.Handler {
 //	@Accept			json
 //	@Produce		json
 //	@Param 			accountAddress	path	string	true	"account address"
+//	@Param			chainId	query	int	false	"chain id, the default network when omitted"
 //	@Success		200			{object}	CommonResponse{data=ProfileResponse}
 //	@Failure		400			{object}	CommonResponse
 //	@Failure		500			{object}	CommonResponse
@@ -69,16 +70,56 @@ func (c *ProfileCtrl) profile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: get user profile
-	// 3. get purchased tickets and issued tickets
-	tickets, err := c.ticket.GetOwnedNFT(r.Context(), web3.HexToAddress(u.AccountAddress))
+	c.writeProfile(w, r, u)
+}
+
+// ProfileByHandle godoc
+//
+//	@Tags			profile
+//	@Summary		returns user profile by handle
+//	@Description	returns the profile of the user with the handle, whatever its case
+//	@Accept			json
+//	@Produce		json
+//	@Param 			handle	path	string	true	"handle, without the @"
+//	@Param			chainId	query	int	false	"chain id, the default network when omitted"
+//	@Success		200			{object}	CommonResponse{data=ProfileResponse}
+//	@Failure		400			{object}	CommonResponse
+//	@Failure		404			{object}	CommonResponse
+//	@Failure		500			{object}	CommonResponse
+//	@Router			/v1/profile/@{handle} [get]
+func (c *ProfileCtrl) profileByHandle(w http.ResponseWriter, r *http.Request) {
+	u, err := c.user.FindUserByHandle(r.Context(), chi.URLParam(r, "handle"))
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "user not found", http.StatusNotFound)
+		} else {
+			logger.Error("failed to find user", "error", err)
+			ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.writeProfile(w, r, u)
+}
+
+// writeProfile writes the profile of u with the tickets it owns and issued on the network of the request.
+func (c *ProfileCtrl) writeProfile(w http.ResponseWriter, r *http.Request, u *user.User) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get purchased tickets of every linked wallet
+	ownedTickets, err := ownedNFTs(r.Context(), tickets, u)
 	if err != nil {
 		logger.Error("failed to get owned nft", "error", err)
 		ErrorJSON(w, "failed to get owned nft", http.StatusInternalServerError)
 		return
 	}
-	//  4. get issued ticket by user
-	ticketCollections, err := c.ticket.FindTicketCollections(r.Context(), ticket.TicketCollectionFilter{
+
+	// 2. get issued ticket by user
+	ticketCollections, err := tickets.FindTicketCollections(r.Context(), ticket.TicketCollectionFilter{
 		IssuerAddress: u.AccountAddress,
 	})
 	if err != nil {
@@ -87,11 +128,11 @@ func (c *ProfileCtrl) profile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 5. return user profile
+	// 3. return user profile
 	resp := CommonResponse{
 		Status:  http.StatusOK,
 		Message: "Successfully get user profile",
-		Data:    ProfileResponse{UserInfo: *u, OwnedTickets: tickets.NFTs, IssuedTickets: ticketCollections},
+		Data:    ProfileResponse{UserInfo: *u, OwnedTickets: ownedTickets, IssuedTickets: ticketCollections.Items},
 	}
 
 	_ = WriteJSON(w, http.StatusOK, resp)
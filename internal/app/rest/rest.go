@@ -5,3 +5,6 @@ type CommonResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+// maxFeeExceededMessage is returned when a server transaction would pay more than the configured max fee per gas
+const maxFeeExceededMessage = "network fees are above the configured limit, try again later"
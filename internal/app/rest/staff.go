@@ -0,0 +1,339 @@
+package rest
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/heroticket/internal/logger"
+	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/staff"
+	"github.com/heroticket/internal/service/ticket"
+	"github.com/heroticket/internal/service/user"
+	"github.com/heroticket/internal/web3"
+)
+
+type StaffCtrl struct {
+	jwt      jwt.Service
+	networks *ticket.Networks
+	staff    staff.Service
+	user     user.Service
+}
+
+func NewStaffCtrl(jwt jwt.Service, networks *ticket.Networks, staff staff.Service, user user.Service) *StaffCtrl {
+	return &StaffCtrl{
+		jwt:      jwt,
+		networks: networks,
+		staff:    staff,
+		user:     user,
+	}
+}
+
+func (c *StaffCtrl) Pattern() string {
+	return "/staff"
+}
+
+func (c *StaffCtrl) Handler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(TokenRequired(c.jwt))
+	r.Get("/", c.delegations)
+	r.Post("/", c.invite)
+	r.Get("/assignments", c.assignments)
+	r.Delete("/{id}", c.revoke)
+
+	return r
+}
+
+type InviteStaffRequest struct {
+	// Staff is the DID or account address of a registered user
+	Staff string `json:"staff"`
+	// Role is "scanner", the default
+	Role        string   `json:"role"`
+	Collections []string `json:"collections"`
+	// ExpiresAt is the unix time the delegation lapses at, 0 for no expiry
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Invite godoc
+//
+// @Tags			staff
+// @Summary		delegates a staff role to a user
+// @Description	delegates the scanner role over some of the caller's ticket collections to a user, granting the user the scanner role when it can't scan tickets yet
+// @Accept			json
+// @Produce		json
+// @Param			request	body	InviteStaffRequest	true	"delegation"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		201			{object}	CommonResponse{data=staff.Delegation}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/staff [post]
+func (c *StaffCtrl) invite(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get issuer from context
+	issuer, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. read delegation from body
+	var req InviteStaffRequest
+
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "invalid request body")
+		return
+	}
+
+	// 3. find the staff user by account address or did
+	var member *user.User
+
+	if web3.IsAddressValid(req.Staff) {
+		member, err = c.user.FindUserByAccountAddress(r.Context(), strings.ToLower(req.Staff))
+	} else {
+		member, err = c.user.FindUserByID(r.Context(), req.Staff)
+	}
+
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "staff user not found")
+			return
+		}
+		logger.Error("failed to find staff user", "error", err)
+		ErrorJSON(w, "failed to find staff user", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. check that the caller issued every collection, from one of its wallets
+	var issuerAddress string
+
+	for _, contractAddress := range req.Collections {
+		collection, err := tickets.FindTicketCollectionByContractAddress(r.Context(), strings.ToLower(contractAddress))
+		if err != nil {
+			if err == ticket.ErrTicketCollectionNotFound {
+				ErrorJSON(w, "ticket collection not found: "+contractAddress)
+				return
+			}
+			logger.Error("failed to find ticket collection by contract address", "error", err)
+			ErrorJSON(w, "failed to find ticket collection by contract address", http.StatusInternalServerError)
+			return
+		}
+
+		if !issuer.HasWallet(collection.IssuerAddress) {
+			ErrorJSON(w, "only the issuer can delegate ticket collection "+contractAddress, http.StatusForbidden)
+			return
+		}
+
+		// delegations are looked up by the on-chain issuer, so one delegation covers one wallet
+		if issuerAddress == "" {
+			issuerAddress = collection.IssuerAddress
+		} else if !strings.EqualFold(issuerAddress, collection.IssuerAddress) {
+			ErrorJSON(w, "ticket collections of a delegation must be issued from the same wallet")
+			return
+		}
+	}
+
+	if member.ID == issuer.ID {
+		ErrorJSON(w, staff.ErrSelfDelegation.Error())
+		return
+	}
+
+	// 5. create delegation
+	d, err := c.staff.Invite(r.Context(), staff.InviteParams{
+		ChainID:       tickets.ChainID(),
+		IssuerAddress: issuerAddress,
+		StaffID:       member.ID,
+		StaffAddress:  member.AccountAddress,
+		Role:          req.Role,
+		Collections:   req.Collections,
+		ExpiresAt:     req.ExpiresAt,
+	})
+	if err != nil {
+		switch err {
+		case staff.ErrInvalidRole, staff.ErrInvalidExpiry, staff.ErrNoCollections, staff.ErrSelfDelegation:
+			ErrorJSON(w, err.Error())
+		default:
+			logger.Error("failed to create delegation", "error", err)
+			ErrorJSON(w, "failed to create delegation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 6. scanning needs the tickets:scan permission besides the delegation, grant the scanner role when missing
+	if d.Role == staff.RoleScanner && !slices.Contains(member.EffectivePermissions(), user.PermissionScanTicket) {
+		_, err := c.user.Grant(r.Context(), user.GrantParams{
+			UserID: member.ID,
+			Kind:   user.GrantKindRole,
+			Value:  string(user.RoleScanner),
+			By:     issuer.ID,
+		})
+		if err != nil {
+			logger.Error("failed to grant scanner role", "error", err)
+			ErrorJSON(w, "failed to grant scanner role", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusCreated,
+		Message: "Successfully delegated staff role",
+		Data:    d,
+	}
+
+	_ = WriteJSON(w, http.StatusCreated, resp)
+}
+
+// Delegations godoc
+//
+// @Tags			staff
+// @Summary		returns delegations issued by the caller
+// @Description	returns staff delegations issued by the caller, including revoked and expired ones
+// @Accept			json
+// @Produce		json
+// @Param			page	query	int	false	"page number"
+// @Param			limit	query	int	false	"page size"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=staff.Delegations}
+// @Failure		400			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/staff [get]
+func (c *StaffCtrl) delegations(w http.ResponseWriter, r *http.Request) {
+	c.findDelegations(w, r, false)
+}
+
+// Assignments godoc
+//
+// @Tags			staff
+// @Summary		returns delegations granted to the caller
+// @Description	returns the active staff delegations granted to the caller
+// @Accept			json
+// @Produce		json
+// @Param			page	query	int	false	"page number"
+// @Param			limit	query	int	false	"page size"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=staff.Delegations}
+// @Failure		400			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/staff/assignments [get]
+func (c *StaffCtrl) assignments(w http.ResponseWriter, r *http.Request) {
+	c.findDelegations(w, r, true)
+}
+
+func (c *StaffCtrl) findDelegations(w http.ResponseWriter, r *http.Request, assigned bool) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get user from context
+	u, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. get pagination from query
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 3. find delegations issued by or granted to the user
+	filter := staff.DelegationFilter{
+		ChainID: tickets.ChainID(),
+		Page:    page,
+		Limit:   limit,
+	}
+
+	if assigned {
+		filter.StaffID = u.ID
+		filter.ActiveAt = time.Now().Unix()
+	} else {
+		filter.IssuerAddresses = u.AccountAddresses()
+	}
+
+	delegations, err := c.staff.FindDelegations(r.Context(), filter)
+	if err != nil {
+		logger.Error("failed to find delegations", "error", err)
+		ErrorJSON(w, "failed to find delegations", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully retrieved delegations",
+		Data:    delegations,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// Revoke godoc
+//
+// @Tags			staff
+// @Summary		revokes a delegation
+// @Description	revokes a staff delegation issued by the caller
+// @Accept			json
+// @Produce		json
+// @Param			id	path	string	true	"delegation id"
+// @Success		200			{object}	CommonResponse
+// @Failure		400			{object}	CommonResponse
+// @Failure		404			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/staff/{id} [delete]
+func (c *StaffCtrl) revoke(w http.ResponseWriter, r *http.Request) {
+	// 1. get issuer from context
+	issuer, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. revoke the delegation, only the delegations of the issuer's wallets match
+	id := chi.URLParam(r, "id")
+
+	if err := c.staff.Revoke(r.Context(), id, issuer.AccountAddresses()); err != nil {
+		if err == staff.ErrDelegationNotFound {
+			ErrorJSON(w, "delegation not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to revoke delegation", "error", err)
+		ErrorJSON(w, "failed to revoke delegation", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully revoked delegation",
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *StaffCtrl) currentUser(w http.ResponseWriter, r *http.Request) (*user.User, bool) {
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		ErrorJSON(w, "user not found")
+		return nil, false
+	}
+
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
+	if err != nil {
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return u, true
+}
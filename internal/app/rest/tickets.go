@@ -1,6 +1,9 @@
 package rest
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -9,38 +12,49 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/heroticket/internal/app/ws"
 	"github.com/heroticket/internal/logger"
 	"github.com/heroticket/internal/service/auth"
+	"github.com/heroticket/internal/service/checkin"
 	"github.com/heroticket/internal/service/ipfs"
+	"github.com/heroticket/internal/service/job"
 	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/nullifier"
+	"github.com/heroticket/internal/service/staff"
 	"github.com/heroticket/internal/service/ticket"
 	"github.com/heroticket/internal/service/user"
 	"github.com/heroticket/internal/web3"
-	"github.com/iden3/go-circuits/v2"
-	"github.com/iden3/iden3comm/v2/protocol"
 )
 
 type TicketCtrl struct {
 	serverUrl string
 
-	auth   auth.Service
-	ipfs   ipfs.Service
-	jwt    jwt.Service
-	ticket ticket.Service
-	user   user.Service
+	auth       auth.Service
+	checkins   checkin.Service
+	ipfs       ipfs.Service
+	jobs       job.Service
+	jwt        jwt.Service
+	networks   *ticket.Networks
+	nullifiers nullifier.Service
+	staff      staff.Service
+	user       user.Service
 }
 
-func NewTicketCtrl(auth auth.Service, ipfs ipfs.Service, jwt jwt.Service, ticket ticket.Service, user user.Service, serverUrl string) *TicketCtrl {
+func NewTicketCtrl(auth auth.Service, checkins checkin.Service, ipfs ipfs.Service, jobs job.Service, jwt jwt.Service, networks *ticket.Networks, nullifiers nullifier.Service, staff staff.Service, user user.Service, serverUrl string) *TicketCtrl {
 	return &TicketCtrl{
-		auth:      auth,
-		ipfs:      ipfs,
-		jwt:       jwt,
-		ticket:    ticket,
-		user:      user,
-		serverUrl: serverUrl,
+		auth:       auth,
+		checkins:   checkins,
+		ipfs:       ipfs,
+		jobs:       jobs,
+		jwt:        jwt,
+		networks:   networks,
+		nullifiers: nullifiers,
+		staff:      staff,
+		user:       user,
+		serverUrl:  serverUrl,
 	}
 }
 
@@ -52,6 +66,7 @@ func (c *TicketCtrl) Handler() http.Handler {
 	r := chi.NewRouter()
 
 	r.Get("/", c.tickets)
+	r.Get("/search", c.searchTickets)
 	r.With(TokenCheck(c.jwt)).Get("/{contractAddress}", c.ticketByContractAddress)
 	r.Post("/{contractAddress}/whitelist-callback", c.whitelistCallback)
 	r.Post("/{contractAddress}/token-purchase-callback", c.tokenPurchaseCallback)
@@ -61,8 +76,11 @@ func (c *TicketCtrl) Handler() http.Handler {
 		r.Use(TokenRequired(c.jwt))
 		r.Get("/{contractAddress}/whitelist-qr", c.whitelistQR)
 		r.Get("/{contractAddress}/token-purchase-qr", c.tokenPurchaseQR)
+		r.Get("/{contractAddress}/eth-purchase-tx", c.ethPurchaseTx)
+		r.Post("/{contractAddress}/eth-purchase", c.ethPurchase)
+		r.Put("/{contractAddress}/proof-policy", c.updateProofPolicy)
 		r.Get("/{contractAddress}/verify-qr", c.verifyQR)
-		r.Post("/create", c.createTicket)
+		r.With(RequirePermission(c.jwt, user.PermissionCreateTicket)).Post("/create", c.createTicket)
 	})
 
 	return r
@@ -72,22 +90,44 @@ func (c *TicketCtrl) Handler() http.Handler {
 //
 // @Tags			tickets
 // @Summary		returns tickets
-// @Description	returns tickets
+// @Description	returns ticket collections paginated, filtered and sorted
 // @Accept			json
 // @Produce		json
-// @Param			page	query	int	false	"page number"
-// @Param			limit	query	int	false	"page size"
-// @Success		200			{object}	CommonResponse{data=[]ticket.TicketCollection}
+// @Param			page			query	int		false	"page number"
+// @Param			limit			query	int		false	"page size"
+// @Param			status			query	string	false	"sale status"	Enums(onSale, soldOut, upcoming)
+// @Param			minEthPrice		query	string	false	"min eth price in ether, e.g. 0.05"
+// @Param			maxEthPrice		query	string	false	"max eth price in ether, e.g. 0.05"
+// @Param			minTokenPrice	query	string	false	"min token price"
+// @Param			maxTokenPrice	query	string	false	"max token price"
+// @Param			issuer			query	string	false	"issuer account address"
+// @Param			organizer		query	string	false	"organizer (partial match)"
+// @Param			location		query	string	false	"location (partial match)"
+// @Param			saleFrom		query	int		false	"sale period overlaps from (unix seconds)"
+// @Param			saleTo			query	int		false	"sale period overlaps to (unix seconds)"
+// @Param			sort			query	string	false	"sort field"	Enums(saleEndAt, createdAt, ethPrice, tokenPrice)
+// @Param			order			query	string	false	"sort order"	Enums(asc, desc)
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=ticket.TicketCollections}
 // @Failure		400			{object}	CommonResponse
 // @Failure		500			{object}	CommonResponse
 // @Router			/v1/tickets [get]
 func (c *TicketCtrl) tickets(w http.ResponseWriter, r *http.Request) {
-	// 1. get page and limit from query
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
 
-	// TODO: add pagination
+	// 1. get filter from query
+	filter, err := readTicketCollectionFilter(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
 
 	// 2. get ticket collections from db
-	collections, err := c.ticket.FindTicketCollections(r.Context(), ticket.TicketCollectionFilter{})
+	collections, err := tickets.FindTicketCollections(r.Context(), filter)
 	if err != nil {
 		logger.Error("failed to find ticket collections", "error", err)
 		ErrorJSON(w, "failed to find ticket collections", http.StatusInternalServerError)
@@ -97,19 +137,166 @@ func (c *TicketCtrl) tickets(w http.ResponseWriter, r *http.Request) {
 	// 3. return tickets
 	resp := CommonResponse{
 		Status: http.StatusOK,
+		Data:   collections,
 	}
 
-	if len(collections) > 0 {
+	if len(collections.Items) > 0 {
 		resp.Message = "Successfully retrieved ticket collections"
-		resp.Data = collections
 	} else {
 		resp.Message = "No ticket collections found"
-		resp.Data = []ticket.TicketCollection{}
 	}
 
 	_ = WriteJSON(w, http.StatusOK, resp)
 }
 
+// SearchTickets godoc
+//
+// @Tags			tickets
+// @Summary		searches tickets
+// @Description	full-text searches ticket collections by name, symbol, description, organizer and location, ranked by relevance
+// @Accept			json
+// @Produce		json
+// @Param			q		query		string	true	"search query"
+// @Param			page	query		int		false	"page number"
+// @Param			limit	query		int		false	"page size"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200		{object}	CommonResponse{data=ticket.TicketCollectionSearchResults}
+// @Failure		400		{object}	CommonResponse
+// @Failure		500		{object}	CommonResponse
+// @Router			/v1/tickets/search [get]
+func (c *TicketCtrl) searchTickets(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get search query
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		ErrorJSON(w, "query is required")
+		return
+	}
+
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 2. search ticket collections
+	results, err := tickets.SearchTicketCollections(r.Context(), ticket.TicketCollectionSearch{
+		Query: q,
+		Page:  page,
+		Limit: limit,
+	})
+	if err != nil {
+		logger.Error("failed to search ticket collections", "error", err)
+		ErrorJSON(w, "failed to search ticket collections", http.StatusInternalServerError)
+		return
+	}
+
+	// 3. return results
+	resp := CommonResponse{
+		Status: http.StatusOK,
+		Data:   results,
+	}
+
+	if len(results.Items) > 0 {
+		resp.Message = "Successfully searched ticket collections"
+	} else {
+		resp.Message = "No ticket collections found"
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// readTicketCollectionFilter reads and validates ticket collection filter from query params
+func readTicketCollectionFilter(r *http.Request) (ticket.TicketCollectionFilter, error) {
+	query := r.URL.Query()
+
+	page, limit, err := ReadPagination(r)
+	if err != nil {
+		return ticket.TicketCollectionFilter{}, err
+	}
+
+	filter := ticket.TicketCollectionFilter{
+		IssuerAddress: strings.ToLower(query.Get("issuer")),
+		Organizer:     query.Get("organizer"),
+		Location:      query.Get("location"),
+		Status:        ticket.SaleStatus(query.Get("status")),
+		MinTokenPrice: query.Get("minTokenPrice"),
+		MaxTokenPrice: query.Get("maxTokenPrice"),
+		SortBy:        ticket.SortField(query.Get("sort")),
+		SortOrder:     ticket.SortOrder(query.Get("order")),
+		Page:          page,
+		Limit:         limit,
+	}
+
+	if filter.IssuerAddress != "" && !web3.IsAddressValid(filter.IssuerAddress) {
+		return filter, fmt.Errorf("invalid issuer")
+	}
+
+	if filter.Status != "" && !filter.Status.Valid() {
+		return filter, fmt.Errorf("invalid status")
+	}
+
+	if filter.SortBy != "" && !filter.SortBy.Valid() {
+		return filter, fmt.Errorf("invalid sort")
+	}
+
+	if filter.SortOrder != "" && !filter.SortOrder.Valid() {
+		return filter, fmt.Errorf("invalid order")
+	}
+
+	// eth prices are given in ether and stored in wei
+	ethPrices := map[string]*string{
+		"minEthPrice": &filter.MinEthPrice,
+		"maxEthPrice": &filter.MaxEthPrice,
+	}
+
+	for name, price := range ethPrices {
+		if v := query.Get(name); v != "" {
+			wei, err := web3.ParseEther(v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s", name)
+			}
+			*price = wei.String()
+		}
+	}
+
+	prices := map[string]string{
+		"minTokenPrice": filter.MinTokenPrice,
+		"maxTokenPrice": filter.MaxTokenPrice,
+	}
+
+	for name, price := range prices {
+		if price == "" {
+			continue
+		}
+
+		if v, ok := big.NewInt(0).SetString(price, 10); !ok || v.Sign() < 0 {
+			return filter, fmt.Errorf("invalid %s", name)
+		}
+	}
+
+	if saleFrom := query.Get("saleFrom"); saleFrom != "" {
+		filter.SaleFrom, err = strconv.ParseInt(saleFrom, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid saleFrom")
+		}
+	}
+
+	if saleTo := query.Get("saleTo"); saleTo != "" {
+		filter.SaleTo, err = strconv.ParseInt(saleTo, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid saleTo")
+		}
+	}
+
+	return filter, nil
+}
+
 // Ticket godoc
 //
 // @Tags			tickets
@@ -118,11 +305,18 @@ func (c *TicketCtrl) tickets(w http.ResponseWriter, r *http.Request) {
 // @Accept		json
 // @Produce		json
 // @Param			contractAddress	path	string	true	"contract address"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
 // @Success		200			{object}	CommonResponse{data=ticket.TicketCollectionDetail}
 // @Failure		400			{object}	CommonResponse
 // @Failure		500			{object}	CommonResponse
 // @Router			/v1/tickets/{contractAddress} [get]
 func (c *TicketCtrl) ticketByContractAddress(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get contract address from path
 	rawContractAddress := strings.ToLower(chi.URLParam(r, "contractAddress"))
 
@@ -141,10 +335,8 @@ func (c *TicketCtrl) ticketByContractAddress(w http.ResponseWriter, r *http.Requ
 
 		contractAddress := web3.HexToAddress(rawContractAddress)
 
-		tbaAddress := web3.HexToAddress(u.TbaAddress)
-
-		// user is not logged in
-		ok, err := c.ticket.HasTicket(r.Context(), contractAddress, tbaAddress)
+		// a ticket held by any linked wallet counts
+		_, ok, err := heldTicket(r.Context(), tickets, contractAddress, u)
 		if err != nil {
 			logger.Error("failed to check if user has ticket", "error", err)
 			ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
@@ -155,7 +347,7 @@ func (c *TicketCtrl) ticketByContractAddress(w http.ResponseWriter, r *http.Requ
 	}
 
 	// 3. find ticket collection by contract address
-	collection, err := c.ticket.FindTicketCollectionByContractAddress(r.Context(), rawContractAddress)
+	collection, err := tickets.FindTicketCollectionByContractAddress(r.Context(), rawContractAddress)
 	if err != nil {
 		if err == ticket.ErrTicketCollectionNotFound {
 			ErrorJSON(w, "ticket collection not found", http.StatusBadRequest)
@@ -191,6 +383,7 @@ func (c *TicketCtrl) ticketByContractAddress(w http.ResponseWriter, r *http.Requ
 // @Produce			json
 // @Param			contractAddress	path	string	true	"contract address"
 // @Param			sessionId		query	string	true	"session id"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
 // @Success		200			{object}	CommonResponse{data=protocol.AuthorizationRequestMessage}
 // @Success		202			{object}	CommonResponse
 // @Failure		400			{object}	CommonResponse
@@ -198,6 +391,12 @@ func (c *TicketCtrl) ticketByContractAddress(w http.ResponseWriter, r *http.Requ
 // @Security 		BearerAuth
 // @Router			/v1/tickets/{contractAddress}/whitelist-qr [get]
 func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get jwt user from context
 	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
@@ -231,7 +430,7 @@ func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
 	// 4. check if ticket collection exists
 	contractAddress := web3.HexToAddress(rawContractAddress)
 
-	ok, err := c.ticket.IsIssuedTicket(r.Context(), contractAddress)
+	ok, err := tickets.IsIssuedTicket(r.Context(), contractAddress)
 	if err != nil {
 		logger.Error("failed to check if ticket collection exists", "error", err)
 		ErrorJSON(w, "failed to check if ticket collection exists", http.StatusInternalServerError)
@@ -246,7 +445,7 @@ func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 5. get onchain ticket collection info
-	onchainTicket, err := c.ticket.OnChainTicketInfo(r.Context(), contractAddress)
+	onchainTicket, err := tickets.OnChainTicketInfo(r.Context(), contractAddress)
 	if err != nil {
 		logger.Error("failed to get onchain ticket collection", "error", err)
 		ErrorJSON(w, "failed to get onchain ticket collection", http.StatusInternalServerError)
@@ -270,10 +469,8 @@ func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 8. check if user has ticket
-	tbaAddress := web3.HexToAddress(u.TbaAddress)
-
-	ok, err = c.ticket.HasTicket(r.Context(), contractAddress, tbaAddress)
+	// 8. check if user has ticket in any linked wallet
+	_, ok, err = heldTicket(r.Context(), tickets, contractAddress, u)
 	if err != nil {
 		logger.Error("failed to check if user has ticket", "error", err)
 		ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
@@ -287,8 +484,8 @@ func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 9. check if tba address is already on whitelist
-	ok, err = c.ticket.IsWhitelisted(r.Context(), contractAddress, tbaAddress)
+	// 9. check if any linked wallet is already on whitelist
+	_, ok, err = whitelistedTba(r.Context(), tickets, contractAddress, u)
 	if err != nil {
 		logger.Error("failed to check if user is already on whitelist", "error", err)
 		ErrorJSON(w, "failed to check if user is already on whitelist", http.StatusInternalServerError)
@@ -325,15 +522,25 @@ func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	callbackUrl := fmt.Sprintf("%s/v1/tickets/%s/whitelist-callback?sessionId=%s&accountAddress=%s", c.serverUrl, rawContractAddress, sessionId, u.AccountAddress)
+	// 11. compile the proofs the collection asks for before a purchase
+	collection, err := proofCollection(r.Context(), tickets, rawContractAddress)
+	if err != nil {
+		logger.Error("failed to find ticket collection by contract address", "error", err)
+		ErrorJSON(w, "failed to find ticket collection by contract address", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "whitelist-qr", "failed to find ticket collection by contract address")
+		return
+	}
 
-	// 11. create qr code
+	callbackUrl := fmt.Sprintf("%s/v1/tickets/%s/whitelist-callback?sessionId=%s&accountAddress=%s&chainId=%d", c.serverUrl, rawContractAddress, sessionId, u.AccountAddress, tickets.ChainID())
+
+	// 12. create qr code
 	qrCode, err := c.auth.AuthorizationRequest(r.Context(), auth.AuthorizationRequestParams{
 		ID:          sessionId,
 		Reason:      "Update whitelist for purchase authentication",
 		Message:     "Scan the QR code to update whitelist for purchase authentication",
 		Sender:      admin.ID,
 		CallbackUrl: callbackUrl,
+		Scope:       collection.WhitelistScope(id.UUID().ID(), admin.ID),
 	})
 	if err != nil {
 		logger.Error("failed to create authorization request", "error", err)
@@ -351,7 +558,7 @@ func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	// 12. return qr code
+	// 13. return qr code
 	resp := CommonResponse{
 		Status:  http.StatusOK,
 		Message: "Successfully created authorization request",
@@ -370,12 +577,19 @@ func (c *TicketCtrl) whitelistQR(w http.ResponseWriter, r *http.Request) {
 // @Produce			json
 // @Param			contractAddress	path	string	true	"contract address"
 // @Param			sessionId		query	string	true	"session id"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
 // @Success		200			{object}	CommonResponse{data=protocol.AuthorizationRequestMessage}
 // @Failure		400			{object}	CommonResponse
 // @Failure		500			{object}	CommonResponse
 // @Security 		BearerAuth
 // @Router			/v1/tickets/{contractAddress}/token-purchase-qr [get]
 func (c *TicketCtrl) tokenPurchaseQR(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get jwt user from context
 	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
@@ -409,7 +623,7 @@ func (c *TicketCtrl) tokenPurchaseQR(w http.ResponseWriter, r *http.Request) {
 	// 4. check if ticket collection exists
 	contractAddress := web3.HexToAddress(rawContractAddress)
 
-	ok, err := c.ticket.IsIssuedTicket(r.Context(), contractAddress)
+	ok, err := tickets.IsIssuedTicket(r.Context(), contractAddress)
 	if err != nil {
 		logger.Error("failed to check if ticket collection exists", "error", err)
 		ErrorJSON(w, "failed to check if ticket collection exists", http.StatusInternalServerError)
@@ -424,7 +638,7 @@ func (c *TicketCtrl) tokenPurchaseQR(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 5. get onchain ticket collection info
-	onchainTicket, err := c.ticket.OnChainTicketInfo(r.Context(), contractAddress)
+	onchainTicket, err := tickets.OnChainTicketInfo(r.Context(), contractAddress)
 	if err != nil {
 		logger.Error("failed to get onchain ticket collection", "error", err)
 		ErrorJSON(w, "failed to get onchain ticket collection", http.StatusInternalServerError)
@@ -448,10 +662,8 @@ func (c *TicketCtrl) tokenPurchaseQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 8. check if user has ticket
-	tbaAddress := web3.HexToAddress(u.TbaAddress)
-
-	ok, err = c.ticket.HasTicket(r.Context(), contractAddress, tbaAddress)
+	// 8. check if user has ticket in any linked wallet
+	_, ok, err = heldTicket(r.Context(), tickets, contractAddress, u)
 	if err != nil {
 		logger.Error("failed to check if user has ticket", "error", err)
 		ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
@@ -473,7 +685,7 @@ func (c *TicketCtrl) tokenPurchaseQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	callbackUrl := fmt.Sprintf("%s/v1/tickets/%s/token-purchase-callback?sessionId=%s&accountAddress=%s", c.serverUrl, rawContractAddress, sessionId, u.AccountAddress)
+	callbackUrl := fmt.Sprintf("%s/v1/tickets/%s/token-purchase-callback?sessionId=%s&accountAddress=%s&chainId=%d", c.serverUrl, rawContractAddress, sessionId, u.AccountAddress, tickets.ChainID())
 
 	// 9. create qr code
 	qrCode, err := c.auth.AuthorizationRequest(r.Context(), auth.AuthorizationRequestParams{
@@ -511,11 +723,18 @@ func (c *TicketCtrl) tokenPurchaseQR(w http.ResponseWriter, r *http.Request) {
 // @Param			accountAddress	query	string	true	"account address"
 // @Param			sessionId		query	string	true	"session id"
 // @Param			token			body	string	true	"token"
-// @Success		200			{object}	CommonResponse
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		202			{object}	CommonResponse{data=job.Job}
 // @Failure		400			{object}	CommonResponse
 // @Failure		500			{object}	CommonResponse
 // @Router			/v1/tickets/{contractAddress}/whitelist-callback [post]
 func (c *TicketCtrl) whitelistCallback(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get contract address from path
 	rawContractAddress := strings.ToLower(chi.URLParam(r, "contractAddress"))
 
@@ -561,7 +780,7 @@ func (c *TicketCtrl) whitelistCallback(w http.ResponseWriter, r *http.Request) {
 	// 6. check if ticket collection exists
 	contractAddress := web3.HexToAddress(rawContractAddress)
 
-	ok, err := c.ticket.IsIssuedTicket(r.Context(), contractAddress)
+	ok, err := tickets.IsIssuedTicket(r.Context(), contractAddress)
 	if err != nil {
 		logger.Error("failed to check if ticket collection exists", "error", err)
 		ErrorJSON(w, "failed to check if ticket collection exists", http.StatusInternalServerError)
@@ -575,10 +794,8 @@ func (c *TicketCtrl) whitelistCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 7. check if user has ticket
-	tbaAddress := web3.HexToAddress(user.TbaAddress)
-
-	ok, err = c.ticket.HasTicket(r.Context(), contractAddress, tbaAddress)
+	// 7. check if user has ticket in any linked wallet
+	_, ok, err = heldTicket(r.Context(), tickets, contractAddress, user)
 	if err != nil {
 		logger.Error("failed to check if user has ticket", "error", err)
 		ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
@@ -592,7 +809,16 @@ func (c *TicketCtrl) whitelistCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 8. verify token
+	// 8. get the request before it is consumed, it names the proofs asked for
+	request, err := c.auth.FindAuthorizationRequest(r.Context(), sessionId)
+	if err != nil {
+		logger.Error("failed to find authorization request", "error", err)
+		ErrorJSON(w, "failed to find authorization request", http.StatusBadRequest)
+		go ws.ErrorEvent(id, "whitelist-callback", "failed to find authorization request")
+		return
+	}
+
+	// 9. verify token
 	resp, err := c.auth.AuthorizationCallback(r.Context(), sessionId, string(tokenBytes), true)
 	if err != nil {
 		logger.Error("failed to handle whitelist callback", "error", err)
@@ -601,44 +827,69 @@ func (c *TicketCtrl) whitelistCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 9. get user id from verification response
+	// 10. get user id from verification response
 	userID := resp.From
 
-	// 10. check if user id matches user id from db
+	// 11. check if user id matches user id from db
 	if userID != user.ID {
 		ErrorJSON(w, "user id does not match", http.StatusBadRequest)
 		go ws.ErrorEvent(id, "whitelist-callback", "user id does not match")
 		return
 	}
 
-	// 11. call contract to set user address on whitelist
-	accountAddress := web3.HexToAddress(rawAccountAddress)
-
-	err = c.ticket.UpdateWhitelist(r.Context(), contractAddress, accountAddress)
+	// 12. when the collection asks for personhood, allow one authorization per person
+	personhood, ok, err := ticket.DisclosedPersonhood(request, resp)
 	if err != nil {
-		logger.Error("failed to update whitelist", "error", err)
-		ErrorJSON(w, "failed to update whitelist", http.StatusInternalServerError)
-		go ws.ErrorEvent(id, "whitelist-callback", "failed to update whitelist")
+		ErrorJSON(w, err.Error(), http.StatusBadRequest)
+		go ws.ErrorEvent(id, "whitelist-callback", err.Error())
 		return
 	}
 
-	go ws.Send(ws.Message{
-		ID:   id,
-		Type: ws.EventMessage,
-		Event: ws.Event{
-			Name:   "whitelist-callback",
-			Status: ws.Done,
-			Data:   "Successfully updated whitelist",
+	if ok {
+		_, err := c.nullifiers.Use(r.Context(), nullifier.UseParams{
+			ChainID:         tickets.ChainID(),
+			ContractAddress: rawContractAddress,
+			Value:           personhood,
+			UserID:          userID,
+			AccountAddress:  rawAccountAddress,
+		})
+		if err != nil {
+			if err == nullifier.ErrNullifierUsed {
+				ErrorJSON(w, "purchase already authorized for this person", http.StatusConflict)
+				go ws.ErrorEvent(id, "whitelist-callback", "purchase already authorized for this person")
+				return
+			}
+			logger.Error("failed to use nullifier", "error", err)
+			ErrorJSON(w, "failed to use nullifier", http.StatusInternalServerError)
+			go ws.ErrorEvent(id, "whitelist-callback", "failed to use nullifier")
+			return
+		}
+	}
+
+	// 13. enqueue whitelist update, the result is pushed to the ws session
+	key, renew := jobKey(r, jobUpdateWhitelist, userID, strconv.FormatInt(tickets.ChainID(), 10), rawContractAddress, rawAccountAddress)
+
+	j, err := c.jobs.Enqueue(r.Context(), job.EnqueueParams{
+		Type:      jobUpdateWhitelist,
+		Key:       key,
+		Renew:     renew,
+		UserID:    userID,
+		SessionID: sessionId,
+		Payload: whitelistPayload{
+			ChainID:         tickets.ChainID(),
+			ContractAddress: rawContractAddress,
+			AccountAddress:  rawAccountAddress,
 		},
 	})
-
-	// 12. return success response
-	response := CommonResponse{
-		Status:  http.StatusOK,
-		Message: fmt.Sprintf("Successfully updated whitelist for user with ID %s", userID),
+	if err != nil {
+		logger.Error("failed to enqueue whitelist update", "error", err)
+		ErrorJSON(w, "failed to update whitelist", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "whitelist-callback", "failed to update whitelist")
+		return
 	}
 
-	_ = WriteJSON(w, http.StatusOK, response)
+	// 14. return accepted job
+	writeJobAccepted(w, j)
 }
 
 // TokenPurchaseCallback godoc
@@ -652,11 +903,18 @@ func (c *TicketCtrl) whitelistCallback(w http.ResponseWriter, r *http.Request) {
 // @Param			accountAddress	query	string	true	"account address"
 // @Param			sessionId		query	string	true	"session id"
 // @Param			token			body	string	true	"token"
-// @Success			200			{object}	CommonResponse
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success			202			{object}	CommonResponse{data=job.Job}
 // @Failure			400			{object}	CommonResponse
 // @Failure			500			{object}	CommonResponse
 // @Router			/v1/tickets/{contractAddress}/token-purchase-callback [post]
 func (c *TicketCtrl) tokenPurchaseCallback(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get contract address from path
 	rawContractAddress := strings.ToLower(chi.URLParam(r, "contractAddress"))
 
@@ -702,7 +960,7 @@ func (c *TicketCtrl) tokenPurchaseCallback(w http.ResponseWriter, r *http.Reques
 	// 6. check if ticket collection exists
 	contractAddress := web3.HexToAddress(rawContractAddress)
 
-	ok, err := c.ticket.IsIssuedTicket(r.Context(), contractAddress)
+	ok, err := tickets.IsIssuedTicket(r.Context(), contractAddress)
 	if err != nil {
 		logger.Error("failed to check if ticket collection exists", "error", err)
 		ErrorJSON(w, "failed to check if ticket collection exists", http.StatusInternalServerError)
@@ -716,10 +974,8 @@ func (c *TicketCtrl) tokenPurchaseCallback(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// 7. check if user has ticket
-	tbaAddress := web3.HexToAddress(u.TbaAddress)
-
-	ok, err = c.ticket.HasTicket(r.Context(), contractAddress, tbaAddress)
+	// 7. check if user has ticket in any linked wallet
+	_, ok, err = heldTicket(r.Context(), tickets, contractAddress, u)
 	if err != nil {
 		logger.Error("failed to check if user has ticket", "error", err)
 		ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
@@ -752,51 +1008,260 @@ func (c *TicketCtrl) tokenPurchaseCallback(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// 11. call contract to mint token
-	accountAddress := web3.HexToAddress(rawAccountAddress)
+	// 11. enqueue ticket purchase, the result is pushed to the ws session
+	key, renew := jobKey(r, jobBuyTicketByToken, userID, strconv.FormatInt(tickets.ChainID(), 10), rawContractAddress, rawAccountAddress)
 
-	_, err = c.ticket.BuyTicketByToken(r.Context(), contractAddress, accountAddress)
+	j, err := c.jobs.Enqueue(r.Context(), job.EnqueueParams{
+		Type:      jobBuyTicketByToken,
+		Key:       key,
+		Renew:     renew,
+		UserID:    userID,
+		SessionID: sessionId,
+		Payload: tokenPurchasePayload{
+			ChainID:         tickets.ChainID(),
+			ContractAddress: rawContractAddress,
+			AccountAddress:  rawAccountAddress,
+			TbaAddress:      strings.ToLower(u.TbaAddress),
+		},
+	})
 	if err != nil {
-		logger.Error("failed to buy ticket by token", "error", err)
+		logger.Error("failed to enqueue ticket purchase", "error", err)
 		ErrorJSON(w, "failed to buy ticket by token", http.StatusInternalServerError)
 		go ws.ErrorEvent(id, "token-purchase-callback", "failed to buy ticket by token")
 		return
 	}
 
+	// 12. return accepted job
+	writeJobAccepted(w, j)
+}
+
+// EthPurchaseTx godoc
+//
+// @Tags			tickets
+// @Summary		returns unsigned eth purchase transaction
+// @Description	returns an unsigned buyTicketByEther transaction for the user's wallet to sign and send
+// @Accept			json
+// @Produce		json
+// @Param			contractAddress	path	string	true	"contract address"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=ticket.EthPurchaseTx}
+// @Failure		400			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/tickets/{contractAddress}/eth-purchase-tx [get]
+func (c *TicketCtrl) ethPurchaseTx(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get jwt user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get jwt user from context", "error", err)
+		ErrorJSON(w, "failed to get jwt user from context", http.StatusInternalServerError)
+		return
+	}
+
+	// 2. get contract address from path
+	rawContractAddress := strings.ToLower(chi.URLParam(r, "contractAddress"))
+
+	if !web3.IsAddressValid(rawContractAddress) {
+		ErrorJSON(w, "invalid contract address")
+		return
+	}
+
+	// 3. check if ticket collection exists
+	contractAddress := web3.HexToAddress(rawContractAddress)
+
+	ok, err := tickets.IsIssuedTicket(r.Context(), contractAddress)
+	if err != nil {
+		logger.Error("failed to check if ticket collection exists", "error", err)
+		ErrorJSON(w, "failed to check if ticket collection exists", http.StatusInternalServerError)
+		return
+	}
+
+	if !ok {
+		ErrorJSON(w, "ticket collection does not exist", http.StatusBadRequest)
+		return
+	}
+
+	// 4. get user from db
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
+	if err != nil {
+		logger.Error("failed to find user by id", "error", err)
+		ErrorJSON(w, "failed to find user by id", http.StatusInternalServerError)
+		return
+	}
+
+	// 5. check if user has ticket in any linked wallet
+	_, ok, err = heldTicket(r.Context(), tickets, contractAddress, u)
+	if err != nil {
+		logger.Error("failed to check if user has ticket", "error", err)
+		ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if ok {
+		ErrorJSON(w, "user already has ticket", http.StatusBadRequest)
+		return
+	}
+
+	// 6. prepare unsigned transaction
+	tx, err := tickets.PrepareBuyTicketByEther(r.Context(), contractAddress, web3.HexToAddress(u.AccountAddress))
+	if err != nil {
+		if err == ticket.ErrTicketNotOnSale {
+			ErrorJSON(w, "ticket is not on sale", http.StatusBadRequest)
+			return
+		}
+		logger.Error("failed to prepare eth purchase transaction", "error", err)
+		ErrorJSON(w, "failed to prepare eth purchase transaction", http.StatusInternalServerError)
+		return
+	}
+
+	// 7. return transaction
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully prepared eth purchase transaction",
+		Data:    tx,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+type EthPurchaseRequest struct {
+	TxHash    string `json:"txHash"`
+	SessionId string `json:"sessionId"`
+}
+
+// EthPurchase godoc
+//
+// @Tags			tickets
+// @Summary		confirms eth purchase
+// @Description	queues the confirmation of the signed eth purchase transaction, its result is pushed over websocket once mined
+// @Accept			json
+// @Produce		json
+// @Param			contractAddress	path	string				true	"contract address"
+// @Param			body			body	EthPurchaseRequest	true	"eth purchase request"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		202			{object}	CommonResponse{data=job.Job}
+// @Failure		400			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/tickets/{contractAddress}/eth-purchase [post]
+func (c *TicketCtrl) ethPurchase(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get jwt user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get jwt user from context", "error", err)
+		ErrorJSON(w, "failed to get jwt user from context", http.StatusInternalServerError)
+		return
+	}
+
+	// 2. get contract address from path
+	rawContractAddress := strings.ToLower(chi.URLParam(r, "contractAddress"))
+
+	if !web3.IsAddressValid(rawContractAddress) {
+		ErrorJSON(w, "invalid contract address")
+		return
+	}
+
+	// 3. read tx hash and session id from body
+	var req EthPurchaseRequest
+
+	if err := ReadJSON(w, r, &req); err != nil {
+		logger.Error("failed to read eth purchase request", "error", err)
+		ErrorJSON(w, "failed to read eth purchase request")
+		return
+	}
+	defer r.Body.Close()
+
+	if !web3.IsTxHashValid(req.TxHash) {
+		ErrorJSON(w, "invalid tx hash")
+		return
+	}
+
+	id := ws.ID(req.SessionId)
+	if !id.Valid() {
+		ErrorJSON(w, "invalid session id")
+		return
+	}
+
 	go ws.Send(ws.Message{
 		ID:   id,
 		Type: ws.EventMessage,
 		Event: ws.Event{
-			Name:   "token-purchase-callback",
-			Status: ws.Done,
-			Data:   "Successfully purchased ticket",
+			Name:   "eth-purchase",
+			Status: ws.InProgress,
 		},
 	})
 
-	// 12. return success response
-	response := CommonResponse{
-		Status:  http.StatusOK,
-		Message: fmt.Sprintf("Successfully purchased ticket for user with ID %s", userID),
+	// 4. get user from db
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
+	if err != nil {
+		logger.Error("failed to find user by id", "error", err)
+		ErrorJSON(w, "failed to find user by id", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "eth-purchase", "failed to find user by id")
+		return
 	}
 
-	_ = WriteJSON(w, http.StatusOK, response)
+	// 5. enqueue the confirmation, mining takes longer than a request may
+	key, renew := jobKey(r, jobEthPurchase, u.ID, strconv.FormatInt(tickets.ChainID(), 10), rawContractAddress, strings.ToLower(req.TxHash))
+
+	j, err := c.jobs.Enqueue(r.Context(), job.EnqueueParams{
+		Type:      jobEthPurchase,
+		Key:       key,
+		Renew:     renew,
+		UserID:    u.ID,
+		SessionID: req.SessionId,
+		Payload: ethPurchasePayload{
+			ChainID:         tickets.ChainID(),
+			ContractAddress: rawContractAddress,
+			AccountAddress:  strings.ToLower(u.AccountAddress),
+			TxHash:          strings.ToLower(req.TxHash),
+		},
+	})
+	if err != nil {
+		logger.Error("failed to enqueue eth purchase confirmation", "error", err)
+		ErrorJSON(w, "failed to confirm eth purchase", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "eth-purchase", "failed to confirm eth purchase")
+		return
+	}
+
+	// 6. return accepted job
+	writeJobAccepted(w, j)
 }
 
 // VerifyQR godoc
 //
 // @Tags			tickets
 // @Summary		returns verify authorization qr code
-// @Description	returns verify authorization qr code
+// @Description	returns verify authorization qr code, for the issuer of the collection and the scanners it delegated to
 // @Accept			json
 // @Produce		json
 // @Param			contractAddress	path	string	true	"contract address"
 // @Param			sessionId		query	string	true	"session id"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
 // @Success		200			{object}	CommonResponse{data=protocol.AuthorizationRequestMessage}
 // @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
 // @Failure		500			{object}	CommonResponse
 // @Security 		BearerAuth
 // @Router			/v1/tickets/{contractAddress}/verify-qr [get]
 func (c *TicketCtrl) verifyQR(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get jwt user from context
 	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
@@ -830,7 +1295,7 @@ func (c *TicketCtrl) verifyQR(w http.ResponseWriter, r *http.Request) {
 	// 4. check if ticket collection exists
 	contractAddress := web3.HexToAddress(rawContractAddress)
 
-	ok, err := c.ticket.IsIssuedTicket(r.Context(), contractAddress)
+	ok, err := tickets.IsIssuedTicket(r.Context(), contractAddress)
 	if err != nil {
 		logger.Error("failed to check if ticket collection exists", "error", err)
 		ErrorJSON(w, "failed to check if ticket collection exists", http.StatusInternalServerError)
@@ -844,16 +1309,7 @@ func (c *TicketCtrl) verifyQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 5. get onchain ticket collection
-	onchainTicket, err := c.ticket.OnChainTicketInfo(r.Context(), contractAddress)
-	if err != nil {
-		logger.Error("failed to get onchain ticket collection", "error", err)
-		ErrorJSON(w, "failed to get onchain ticket collection", http.StatusInternalServerError)
-		go ws.ErrorEvent(id, "verify-qr", "failed to get onchain ticket collection")
-		return
-	}
-
-	// 6. get user from db
+	// 5. get user from db
 	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
 	if err != nil {
 		logger.Error("failed to find user by id", "error", err)
@@ -862,16 +1318,20 @@ func (c *TicketCtrl) verifyQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 7. check if user is owner of ticket collection
-	accountAddress := web3.HexToAddress(u.AccountAddress)
-
-	if onchainTicket.Issuer.Big().Cmp(accountAddress.Big()) != 0 {
-		ErrorJSON(w, "user is not owner of ticket collection", http.StatusBadRequest)
-		go ws.ErrorEvent(id, "verify-qr", "user is not owner of ticket collection")
+	// 6. check if user is the issuer of ticket collection or a delegated scanner
+	if err := c.authorizeScanner(r.Context(), tickets, contractAddress, u); err != nil {
+		if err == staff.ErrNotDelegated {
+			ErrorJSON(w, "user is not owner or scanner of ticket collection", http.StatusBadRequest)
+			go ws.ErrorEvent(id, "verify-qr", "user is not owner or scanner of ticket collection")
+			return
+		}
+		logger.Error("failed to authorize scanner", "error", err)
+		ErrorJSON(w, "failed to authorize scanner", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "verify-qr", "failed to authorize scanner")
 		return
 	}
 
-	// 8. get admin id
+	// 7. get admin id
 	admin, err := c.user.FindAdmin(r.Context())
 	if err != nil {
 		logger.Error("failed to find admin", "error", err)
@@ -880,33 +1340,24 @@ func (c *TicketCtrl) verifyQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 8. create qr code
-	var mtpProofRequest protocol.ZeroKnowledgeProofRequest
-
-	// random number
-	mtpProofRequest.ID = id.UUID().ID()
-	mtpProofRequest.CircuitID = string(circuits.AtomicQuerySigV2CircuitID)
-	mtpProofRequest.Query = map[string]interface{}{
-		"allowedIssuers": []string{admin.ID},
-		"credentialSubject": map[string]interface{}{
-			"ticket_address": map[string]interface{}{
-				"$eq": rawContractAddress,
-			},
-		},
-		"context": "ipfs://QmfNkUAwq73r1HmMmzYDZ9REBqLrqdXmQm8xBdq7QbQvHz",
-		"type":    "Ownership",
+	// 8. compile the proofs the collection asks for at the door
+	collection, err := proofCollection(r.Context(), tickets, rawContractAddress)
+	if err != nil {
+		logger.Error("failed to find ticket collection by contract address", "error", err)
+		ErrorJSON(w, "failed to find ticket collection by contract address", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "verify-qr", "failed to find ticket collection by contract address")
+		return
 	}
 
+	// 9. create qr code
 	qrCode, err := c.auth.AuthorizationRequest(r.Context(), auth.AuthorizationRequestParams{
 		ID:          sessionId,
 		Reason:      "Verify ticket ownership",
 		Message:     fmt.Sprintf("Scan the QR code to verify ticket ownership for %s", rawContractAddress),
 		Sender:      u.ID,
-		CallbackUrl: fmt.Sprintf("%s/v1/tickets/verify-callback?sessionId=%s&contractAddress=%s", c.serverUrl, sessionId, rawContractAddress),
-		Scope: []protocol.ZeroKnowledgeProofRequest{
-			mtpProofRequest,
-		},
-		Timeout: 1 * time.Hour,
+		CallbackUrl: fmt.Sprintf("%s/v1/tickets/verify-callback?sessionId=%s&contractAddress=%s&chainId=%d", c.serverUrl, sessionId, rawContractAddress, tickets.ChainID()),
+		Scope:       collection.VerifyScope(id.UUID().ID(), admin.ID),
+		Timeout:     1 * time.Hour,
 	})
 	if err != nil {
 		logger.Error("failed to create authorization request", "error", err)
@@ -924,7 +1375,7 @@ func (c *TicketCtrl) verifyQR(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	// 8. return qr code
+	// 10. return qr code
 	resp := CommonResponse{
 		Status:  http.StatusOK,
 		Message: "Successfully created authorization request",
@@ -938,17 +1389,26 @@ func (c *TicketCtrl) verifyQR(w http.ResponseWriter, r *http.Request) {
 //
 // @Tags			tickets
 // @Summary		verify callback
-// @Description	verify callback
+// @Description	verifies ticket ownership and redeems the ticket under the collection re-entry policy
 // @Accept			json
 // @Produce		json
 // @Param			sessionId		query	string	true	"session id"
 // @Param			contractAddress	query	string	true	"contract address"
 // @Param			token			body	string	true	"token"
-// @Success		200			{object}	CommonResponse
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=checkin.Checkin}
 // @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		409			{object}	CommonResponse
 // @Failure		500			{object}	CommonResponse
 // @Router			/v1/tickets/verify-callback [post]
 func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get session id from query
 	sessionId := r.URL.Query().Get("sessionId")
 
@@ -969,6 +1429,7 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 		ErrorJSON(w, "failed to read token from body", http.StatusInternalServerError)
 		return
 	}
+	defer r.Body.Close()
 
 	go ws.Send(ws.Message{
 		ID:   id,
@@ -979,7 +1440,24 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	// 4. verify token
+	// 4. get the scanner the request was issued for
+	request, err := c.auth.FindAuthorizationRequest(r.Context(), sessionId)
+	if err != nil {
+		logger.Error("failed to find authorization request", "error", err)
+		ErrorJSON(w, "failed to find authorization request", http.StatusBadRequest)
+		go ws.ErrorEvent(id, "verify-callback", "failed to find authorization request")
+		return
+	}
+
+	scanner, err := c.user.FindUserByID(r.Context(), request.From)
+	if err != nil {
+		logger.Error("failed to find scanner by id", "error", err)
+		ErrorJSON(w, "failed to find scanner by id", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "verify-callback", "failed to find scanner by id")
+		return
+	}
+
+	// 5. verify token
 	resp, err := c.auth.AuthorizationCallback(r.Context(), sessionId, string(tokenBytes), false)
 	if err != nil {
 		logger.Error("failed to handle verify callback", "error", err)
@@ -988,10 +1466,10 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 5. get user id from verification response
+	// 6. get user id from verification response
 	userID := resp.From
 
-	// 6. get user from db
+	// 7. get user from db
 	u, err := c.user.FindUserByID(r.Context(), userID)
 	if err != nil {
 		logger.Error("failed to find user by id", "error", err)
@@ -1000,11 +1478,10 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 7. check if user has ticket
-	tbaAddress := web3.HexToAddress(u.TbaAddress)
+	// 8. check if user has ticket in any linked wallet, the holding tba is the one checked in
 	contractAddress := web3.HexToAddress(rawContractAddress)
 
-	ok, err := c.ticket.HasTicket(r.Context(), contractAddress, tbaAddress)
+	holder, ok, err := heldTicket(r.Context(), tickets, contractAddress, u)
 	if err != nil {
 		logger.Error("failed to check if user has ticket", "error", err)
 		ErrorJSON(w, "failed to check if user has ticket", http.StatusInternalServerError)
@@ -1018,6 +1495,63 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 9. check the scanner is still the issuer or a delegated scanner, delegations may have been revoked since the qr was shown
+	if err := c.authorizeScanner(r.Context(), tickets, contractAddress, scanner); err != nil {
+		if err == staff.ErrNotDelegated {
+			ErrorJSON(w, "scanner is not owner or scanner of ticket collection", http.StatusForbidden)
+			go ws.ErrorEvent(id, "verify-callback", "scanner is not owner or scanner of ticket collection")
+			return
+		}
+		logger.Error("failed to authorize scanner", "error", err)
+		ErrorJSON(w, "failed to authorize scanner", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "verify-callback", "failed to authorize scanner")
+		return
+	}
+
+	// 10. get the tickets the holding tba has, entries are counted per ticket so a transfer does not reset them
+	held, err := tickets.FindTicketsOfCollection(r.Context(), contractAddress, web3.HexToAddress(holder))
+	if err != nil {
+		logger.Error("failed to find held tickets", "error", err)
+		ErrorJSON(w, "failed to find held tickets", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "verify-callback", "failed to find held tickets")
+		return
+	}
+
+	if len(held) == 0 {
+		ErrorJSON(w, "ticket is not indexed yet, try again shortly", http.StatusConflict)
+		go ws.ErrorEvent(id, "verify-callback", "ticket is not indexed yet, try again shortly")
+		return
+	}
+
+	// 11. redeem the first held ticket with entries left under the collection re-entry policy
+	var entry *checkin.Checkin
+
+	for _, t := range held {
+		entry, err = c.checkins.Redeem(r.Context(), checkin.RedeemParams{
+			ChainID:         tickets.ChainID(),
+			ContractAddress: rawContractAddress,
+			TokenID:         t.TokenID,
+			HolderDID:       userID,
+			TbaAddress:      holder,
+			ScannedBy:       scanner.ID,
+		})
+		if err != checkin.ErrEntryLimitReached {
+			break
+		}
+	}
+
+	if err != nil {
+		if err == checkin.ErrEntryLimitReached {
+			ErrorJSON(w, "ticket already used", http.StatusConflict)
+			go ws.ErrorEvent(id, "verify-callback", "ticket already used")
+			return
+		}
+		logger.Error("failed to redeem ticket", "error", err)
+		ErrorJSON(w, "failed to redeem ticket", http.StatusInternalServerError)
+		go ws.ErrorEvent(id, "verify-callback", "failed to redeem ticket")
+		return
+	}
+
 	go ws.Send(ws.Message{
 		ID:   id,
 		Type: ws.EventMessage,
@@ -1028,10 +1562,11 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	// 5. return success response
+	// 12. return success response
 	response := CommonResponse{
 		Status:  http.StatusOK,
 		Message: fmt.Sprintf("Successfully verified ticket ownership for user with ID %s", userID),
+		Data:    entry,
 	}
 
 	_ = WriteJSON(w, http.StatusOK, response)
@@ -1041,7 +1576,7 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 //
 // @Tags			tickets
 // @Summary		creates ticket
-// @Description	creates ticket
+// @Description	creates ticket, requires the tickets:create permission
 // @Accept			json
 // @Produce		json
 // @Param			name			formData	string	true	"ticket name"
@@ -1056,12 +1591,22 @@ func (c *TicketCtrl) verifyCallback(w http.ResponseWriter, r *http.Request) {
 // @Param			tokenPrice		formData	int64	true	"ticket token price (min 1 token)"
 // @Param			totalSupply		formData	int64	true	"ticket total supply (min 1 ticket)"
 // @Param			saleDuration	formData	int64	true	"ticket sale duration in days (min 1 day)"
-// @Success		201			{object}	CommonResponse{data=ticket.TicketCollection}
+// @Param			sessionId		formData	string	false	"session id to push the result to"
+// @Param			proofPolicy		formData	string	false	"proof policy json, see ticket.ProofPolicy"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		202			{object}	CommonResponse{data=job.Job}
 // @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
 // @Failure		500			{object}	CommonResponse
 // @Security 		BearerAuth
 // @Router			/v1/tickets/create [post]
 func (c *TicketCtrl) createTicket(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
 	// 1. get jwt user from context
 	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
@@ -1176,6 +1721,24 @@ func (c *TicketCtrl) createTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rawProofPolicy := r.FormValue("proofPolicy")
+
+	var proofPolicy *ticket.ProofPolicy
+
+	if rawProofPolicy != "" {
+		proofPolicy = &ticket.ProofPolicy{}
+
+		if err := json.Unmarshal([]byte(rawProofPolicy), proofPolicy); err != nil {
+			ErrorJSON(w, "failed to parse proof policy", http.StatusBadRequest)
+			return
+		}
+
+		if err := proofPolicy.Validate(); err != nil {
+			ErrorJSON(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// 4. upload banner image to ipfs
 	pinResp, err := c.ipfs.PinFile(r.Context(), bannerImage, fmt.Sprintf("%s_%s", uuid.New().String(), imgHeader.Filename))
 	if err != nil {
@@ -1190,64 +1753,170 @@ func (c *TicketCtrl) createTicket(w http.ResponseWriter, r *http.Request) {
 		ticketUri = fmt.Sprintf("https://ipfs.io/ipfs/%s", ticketUri)
 	}
 
-	// 5. call contract to create new ticket collection
-	ticketIssued, err := c.ticket.IssueTicket(r.Context(), ticket.IssueTicketParams{
-		TicketName:       name,
-		TicketSymbol:     symbol,
-		TicketUri:        ticketUri,
-		Issuer:           web3.HexToAddress(u.AccountAddress),
-		TicketAmount:     totalSupplyBigInt,
-		TicketEthPrice:   ethPriceBigInt,
-		TicketTokenPrice: tokenPriceBigInt,
-		SaleDuration:     big.NewInt(0).Mul(big.NewInt(int64(saleDurationInt)), big.NewInt(86400)),
+	// 5. enqueue ticket collection issuing, the result is pushed to the ws session if given
+	issuerAddress := strings.ToLower(u.AccountAddress)
+
+	key, renew := jobKey(r, jobIssueTicket, u.ID, strconv.FormatInt(tickets.ChainID(), 10), issuerAddress, name, symbol, description, organizer, location, date,
+		ticketUri, ethPriceBigInt.String(), tokenPriceBigInt.String(), totalSupplyBigInt.String(), saleDuration, rawProofPolicy)
+
+	j, err := c.jobs.Enqueue(r.Context(), job.EnqueueParams{
+		Type:      jobIssueTicket,
+		Key:       key,
+		Renew:     renew,
+		UserID:    u.ID,
+		SessionID: r.FormValue("sessionId"),
+		Payload: issueTicketPayload{
+			ChainID:       tickets.ChainID(),
+			IssuerAddress: issuerAddress,
+			Name:          name,
+			Symbol:        symbol,
+			Description:   description,
+			Organizer:     organizer,
+			Location:      location,
+			Date:          date,
+			BannerUrl:     bannerUrl,
+			TicketUri:     ticketUri,
+			EthPrice:      ethPriceBigInt.String(),
+			TokenPrice:    tokenPriceBigInt.String(),
+			TotalSupply:   totalSupplyBigInt.String(),
+			SaleDuration:  saleDurationInt,
+			ProofPolicy:   proofPolicy,
+		},
 	})
 	if err != nil {
-		logger.Error("failed to issue ticket", "error", err)
+		logger.Error("failed to enqueue ticket issuing", "error", err)
 		ErrorJSON(w, "failed to issue ticket", http.StatusInternalServerError)
 		return
 	}
 
-	// 4. get onchain ticket collection data
-	onchainTicket, err := c.ticket.OnChainTicketInfo(r.Context(), ticketIssued.TicketAddress)
+	// 6. return accepted job
+	writeJobAccepted(w, j)
+}
+
+// UpdateProofPolicy godoc
+//
+// @Tags			tickets
+// @Summary		sets the proof policy of a ticket collection
+// @Description	sets the zero-knowledge proofs holders present before a purchase and at the door, issuer only. An empty body clears the policy.
+// @Accept			json
+// @Produce		json
+// @Param			contractAddress	path	string				true	"contract address"
+// @Param			request			body	ticket.ProofPolicy	true	"proof policy"
+// @Param			chainId	query	int	false	"chain id, the default network when omitted"
+// @Success		200			{object}	CommonResponse{data=ticket.ProofPolicy}
+// @Failure		400			{object}	CommonResponse
+// @Failure		403			{object}	CommonResponse
+// @Failure		500			{object}	CommonResponse
+// @Security 		BearerAuth
+// @Router			/v1/tickets/{contractAddress}/proof-policy [put]
+func (c *TicketCtrl) updateProofPolicy(w http.ResponseWriter, r *http.Request) {
+	tickets, err := ReadNetwork(r, c.networks)
+	if err != nil {
+		ErrorJSON(w, err.Error())
+		return
+	}
+
+	// 1. get jwt user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
-		logger.Error("failed to get onchain ticket collection data", "error", err)
-		ErrorJSON(w, "failed to get onchain ticket collection data", http.StatusInternalServerError)
+		ErrorJSON(w, "user not found")
 		return
 	}
 
-	// 5. save ticket collection to db
-	params := ticket.CreateTicketCollectionParams{
-		ContractAddress: strings.ToLower(ticketIssued.TicketAddress.Hex()),
-		IssuerAddress:   strings.ToLower(u.AccountAddress),
-		Name:            name,
-		Symbol:          symbol,
-		Description:     description,
-		Organizer:       organizer,
-		Location:        location,
-		Date:            date,
-		BannerUrl:       bannerUrl,
-		TicketUrl:       ticketUri,
-		EthPrice:        ethPriceBigInt.String(),
-		TokenPrice:      tokenPriceBigInt.String(),
-		TotalSupply:     totalSupplyBigInt.String(),
-		Remaining:       totalSupplyBigInt.String(),
-		SaleStartAt:     onchainTicket.SaleStartAt.Int64(),
-		SaleEndAt:       onchainTicket.SaleEndAt.Int64(),
+	// 2. get user from db
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
+	if err != nil {
+		logger.Error("failed to find user by id", "error", err)
+		ErrorJSON(w, "failed to find user by id", http.StatusInternalServerError)
+		return
 	}
 
-	ticketCollection, err := c.ticket.CreateTicketCollection(r.Context(), params)
+	// 3. check that the user issued the collection
+	rawContractAddress := strings.ToLower(chi.URLParam(r, "contractAddress"))
+
+	collection, err := tickets.FindTicketCollectionByContractAddress(r.Context(), rawContractAddress)
 	if err != nil {
-		logger.Error("failed to save ticket collection", "error", err)
-		ErrorJSON(w, "failed to save ticket collection", http.StatusInternalServerError)
+		if err == ticket.ErrTicketCollectionNotFound {
+			ErrorJSON(w, "ticket collection not found", http.StatusBadRequest)
+			return
+		}
+		logger.Error("failed to find ticket collection by contract address", "error", err)
+		ErrorJSON(w, "failed to find ticket collection by contract address", http.StatusInternalServerError)
+		return
+	}
+
+	if !u.HasWallet(collection.IssuerAddress) {
+		ErrorJSON(w, "only the issuer can update the proof policy", http.StatusForbidden)
+		return
+	}
+
+	// 4. read policy from body, an empty policy clears it
+	var policy ticket.ProofPolicy
+
+	if err := ReadJSON(w, r, &policy); err != nil {
+		ErrorJSON(w, "invalid request body")
+		return
+	}
+
+	var update *ticket.ProofPolicy
+
+	if len(policy.Whitelist) > 0 || len(policy.Verify) > 0 || policy.Personhood != nil {
+		update = &policy
+	}
+
+	// 5. save policy
+	if err := tickets.UpdateProofPolicy(r.Context(), rawContractAddress, update); err != nil {
+		if errors.Is(err, ticket.ErrInvalidProofPolicy) {
+			ErrorJSON(w, err.Error())
+			return
+		}
+		logger.Error("failed to update proof policy", "error", err)
+		ErrorJSON(w, "failed to update proof policy", http.StatusInternalServerError)
 		return
 	}
 
-	// 6. return success response
 	resp := CommonResponse{
-		Status:  http.StatusCreated,
-		Message: "Successfully created ticket collection",
-		Data:    ticketCollection,
+		Status:  http.StatusOK,
+		Message: "Successfully updated proof policy",
+		Data:    update,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// authorizeScanner checks that the user may scan tickets of the collection, either as its on-chain
+// issuer from any linked wallet or through an active scanner delegation from the issuer.
+func (c *TicketCtrl) authorizeScanner(ctx context.Context, tickets ticket.Service, contractAddress common.Address, u *user.User) error {
+	onchainTicket, err := tickets.OnChainTicketInfo(ctx, contractAddress)
+	if err != nil {
+		return err
+	}
+
+	if u.HasWallet(onchainTicket.Issuer.Hex()) {
+		return nil
+	}
+
+	_, err = c.staff.Authorize(ctx, staff.AuthorizeParams{
+		ChainID:         tickets.ChainID(),
+		ContractAddress: contractAddress.Hex(),
+		IssuerAddress:   onchainTicket.Issuer.Hex(),
+		StaffID:         u.ID,
+		Role:            staff.RoleScanner,
+	})
+
+	return err
+}
+
+// proofCollection returns the collection whose proof policy applies to contractAddress. A collection
+// the subscriber has not indexed yet asks for no proofs beyond the platform's own.
+func proofCollection(ctx context.Context, tickets ticket.Service, contractAddress string) (*ticket.TicketCollection, error) {
+	collection, err := tickets.FindTicketCollectionByContractAddress(ctx, strings.ToLower(contractAddress))
+	if err != nil {
+		if err == ticket.ErrTicketCollectionNotFound {
+			return &ticket.TicketCollection{ContractAddress: strings.ToLower(contractAddress)}, nil
+		}
+		return nil, err
 	}
 
-	_ = WriteJSON(w, http.StatusCreated, resp)
+	return collection, nil
 }
@@ -1,17 +1,29 @@
 package rest
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"math/big"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/heroticket/internal/app/ws"
+	"github.com/heroticket/internal/imaging"
 	"github.com/heroticket/internal/logger"
+	"github.com/heroticket/internal/pagination"
 	"github.com/heroticket/internal/service/auth"
+	"github.com/heroticket/internal/service/checkin"
+	"github.com/heroticket/internal/service/did"
+	"github.com/heroticket/internal/service/ipfs"
+	"github.com/heroticket/internal/service/job"
 	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/nullifier"
+	"github.com/heroticket/internal/service/staff"
 	"github.com/heroticket/internal/service/ticket"
 	"github.com/heroticket/internal/service/user"
 	"github.com/heroticket/internal/web3"
@@ -20,19 +32,33 @@ import (
 type UserCtrl struct {
 	serverUrl string
 
-	auth   auth.Service
-	jwt    jwt.Service
-	user   user.Service
-	ticket ticket.Service
+	auth       auth.Service
+	checkins   checkin.Service
+	did        did.Service
+	ipfs       ipfs.Service
+	jobs       job.Service
+	jwt        jwt.Service
+	networks   *ticket.Networks
+	nullifiers nullifier.Service
+	staffs     staff.Service
+	user       user.Service
+	ticket     ticket.Service
 }
 
-func NewUserCtrl(auth auth.Service, jwt jwt.Service, user user.Service, ticket ticket.Service, serverUrl string) *UserCtrl {
+func NewUserCtrl(auth auth.Service, checkins checkin.Service, did did.Service, ipfs ipfs.Service, jobs job.Service, jwt jwt.Service, networks *ticket.Networks, nullifiers nullifier.Service, staffs staff.Service, user user.Service, serverUrl string) *UserCtrl {
 	return &UserCtrl{
-		serverUrl: serverUrl,
-		auth:      auth,
-		jwt:       jwt,
-		user:      user,
-		ticket:    ticket,
+		serverUrl:  serverUrl,
+		auth:       auth,
+		checkins:   checkins,
+		did:        did,
+		ipfs:       ipfs,
+		jobs:       jobs,
+		jwt:        jwt,
+		networks:   networks,
+		nullifiers: nullifiers,
+		staffs:     staffs,
+		user:       user,
+		ticket:     networks.Default(),
 	}
 }
 
@@ -50,8 +76,20 @@ func (c *UserCtrl) Handler() http.Handler {
 	r.Group(func(r chi.Router) {
 		r.Use(TokenRequired(c.jwt))
 		r.Get("/info", c.info)
+		r.Put("/me", c.updateProfile)
+		r.Delete("/me", c.deleteAccount)
+		r.Get("/me/export", c.exportData)
+		r.Put("/me/handle", c.setHandle)
+		r.Post("/me/avatar", c.uploadAvatar)
+		r.Post("/me/banner", c.uploadBanner)
+		r.Post("/logout", c.logout)
+		r.Post("/logout-all", c.logoutAll)
+		r.Get("/siwe/{accountAddress}", c.siweChallenge)
 		r.Post("/register/{accountAddress}", c.register)
 		r.Post("/update-token-balance", c.updateTokenBalance)
+		r.Post("/wallets/{accountAddress}", c.linkWallet)
+		r.Delete("/wallets/{accountAddress}", c.unlinkWallet)
+		r.Put("/wallets/{accountAddress}/primary", c.setPrimaryWallet)
 	})
 
 	return r
@@ -191,10 +229,15 @@ func (c *UserCtrl) loginCallback(w http.ResponseWriter, r *http.Request) {
 	userID := resp.From
 
 	// 5. generate jwt token
-	tokenPair, err := c.jwt.GenerateTokenPair(jwt.JWTUser{
+	tokenPair, err := c.jwt.GenerateTokenPair(r.Context(), jwt.JWTUser{
 		ID: userID,
 	})
 	if err != nil {
+		if err == jwt.ErrUserSuspended {
+			ErrorJSON(w, "account suspended", http.StatusForbidden)
+			go ws.ErrorEvent(id, "login-callback", "account suspended")
+			return
+		}
 		logger.Error("failed to generate jwt token", "error", err)
 		ErrorJSON(w, "failed to generate jwt token", http.StatusInternalServerError)
 		go ws.ErrorEvent(id, "login-callback", "failed to generate jwt token")
@@ -228,12 +271,13 @@ type RefreshTokenRequest struct {
 //
 //	@Tags			users
 //	@Summary		refreshes token pair
-//	@Description	refreshes token pair
+//	@Description	rotates the refresh token, a refresh token can be used once. Using one again logs out its session.
 //	@Accept 		json
 //	@Produce		json
 //	@Param			body		body		RefreshTokenRequest	true	"refresh token request"
 //	@Success		200			{object}	CommonResponse{data=jwt.TokenPair}
 //	@Failure		400			{object}	CommonResponse
+//	@Failure		401			{object}	CommonResponse
 //	@Failure		500			{object}	CommonResponse
 //	@Router			/v1/users/refresh [post]
 func (c *UserCtrl) refresh(w http.ResponseWriter, r *http.Request) {
@@ -249,29 +293,99 @@ func (c *UserCtrl) refresh(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
-	// 2. validate token
-	jwtUser, err := c.jwt.VerifyToken(req.RefreshToken, jwt.TokenRoleRefresh)
+	// 2. validate and rotate token
+	newTokenPair, err := c.jwt.RefreshTokenPair(r.Context(), req.RefreshToken)
 	if err != nil {
-		logger.Error("invalid token", "error", err)
-		ErrorJSON(w, "invalid token", http.StatusBadRequest)
+		switch err {
+		case jwt.ErrTokenRevoked:
+			ErrorJSON(w, "token revoked", http.StatusUnauthorized)
+		case jwt.ErrTokenReused:
+			logger.Info("refresh token reused, session revoked", "error", err)
+			ErrorJSON(w, "token revoked", http.StatusUnauthorized)
+		case jwt.ErrUserSuspended:
+			ErrorJSON(w, "account suspended", http.StatusForbidden)
+		default:
+			logger.Error("invalid token", "error", err)
+			ErrorJSON(w, "invalid token", http.StatusBadRequest)
+		}
 		return
 	}
 
-	// 3. generate new token pair
-	newTokenPair, err := c.jwt.GenerateTokenPair(jwt.JWTUser{
-		ID: jwtUser.ID,
-	})
+	// 3. return new token pair as json response
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully refreshed token pair",
+		Data:    newTokenPair,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// Logout godoc
+//
+//	@Tags			users
+//	@Summary		logs out
+//	@Description	revokes the access and refresh tokens of the session of the access token
+//	@Produce		json
+//	@Success		200			{object}	CommonResponse
+//	@Failure		400			{object}	CommonResponse
+//	@Failure		500			{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/logout [post]
+func (c *UserCtrl) logout(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
-		logger.Error("failed to generate token pair", "error", err)
-		ErrorJSON(w, "failed to generate token pair", http.StatusInternalServerError)
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. revoke the session
+	if err := c.jwt.RevokeSession(r.Context(), *jwtUser); err != nil {
+		logger.Error("failed to revoke session", "error", err)
+		ErrorJSON(w, "failed to log out", http.StatusInternalServerError)
 		return
 	}
 
-	// 4. return new token pair as json response
 	resp := CommonResponse{
 		Status:  http.StatusOK,
-		Message: "Successfully refreshed token pair",
-		Data:    newTokenPair,
+		Message: "Successfully logged out",
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// LogoutAll godoc
+//
+//	@Tags			users
+//	@Summary		logs out all sessions
+//	@Description	revokes every token issued to the user so far, on every device
+//	@Produce		json
+//	@Success		200			{object}	CommonResponse
+//	@Failure		400			{object}	CommonResponse
+//	@Failure		500			{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/logout-all [post]
+func (c *UserCtrl) logoutAll(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. revoke every session of the user
+	if err := c.jwt.RevokeAllSessions(r.Context(), jwtUser.ID); err != nil {
+		logger.Error("failed to revoke sessions", "error", err)
+		ErrorJSON(w, "failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully logged out all sessions",
 	}
 
 	_ = WriteJSON(w, http.StatusOK, resp)
@@ -336,19 +450,28 @@ func (c *UserCtrl) info(w http.ResponseWriter, r *http.Request) {
 	_ = WriteJSON(w, http.StatusOK, resp)
 }
 
-// Register godoc
+// UpdateProfileRequest changes the fields present in the body.
+type UpdateProfileRequest struct {
+	Name *string `json:"name"`
+	Bio  *string `json:"bio"`
+}
+
+// UpdateProfile godoc
 //
 //	@Tags			users
-//	@Summary		registers user
-//	@Description	registers user
+//	@Summary		updates the profile of the user
+//	@Description	updates the name and bio present in the body
+//	@Accept 		json
 //	@Produce		json
-//	@Param			accountAddress 	path	string	true	"account address"
-//	@Success		201		{object}	CommonResponse{data=user.User}
+//	@Param			request	body		UpdateProfileRequest	true	"profile changes"
+//	@Success		200		{object}	CommonResponse{data=user.User}
 //	@Failure		400		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		409		{object}	CommonResponse
 //	@Failure		500		{object}	CommonResponse
 //	@Security 		BearerAuth
-//	@Router			/v1/users/register/{accountAddress} [post]
-func (c *UserCtrl) register(w http.ResponseWriter, r *http.Request) {
+//	@Router			/v1/users/me [put]
+func (c *UserCtrl) updateProfile(w http.ResponseWriter, r *http.Request) {
 	// 1. get user from context
 	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
@@ -357,101 +480,267 @@ func (c *UserCtrl) register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 2. get account address from path params
-	rawAccountAddress := strings.ToLower(chi.URLParam(r, "accountAddress"))
+	// 2. read request
+	var req UpdateProfileRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "invalid request")
+		return
+	}
 
-	// 3. validate account address
-	if !web3.IsAddressValid(rawAccountAddress) {
-		ErrorJSON(w, "invalid account address", http.StatusInternalServerError)
+	// 3. update profile
+	u, err := c.user.UpdateProfile(r.Context(), user.UpdateProfileParams{
+		ID:   jwtUser.ID,
+		Name: req.Name,
+		Bio:  req.Bio,
+	})
+	if err != nil {
+		switch err {
+		case user.ErrNothingToUpdate, user.ErrInvalidName, user.ErrInvalidBio:
+			ErrorJSON(w, err.Error())
+		case user.ErrNameTaken:
+			ErrorJSON(w, err.Error(), http.StatusConflict)
+		case user.ErrUserNotFound:
+			ErrorJSON(w, "user not registered yet", http.StatusNotFound)
+		default:
+			logger.Error("failed to update profile", "error", err)
+			ErrorJSON(w, "failed to update profile", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// 4. check if id is already registered or not
-	_, err = c.user.FindUserByID(r.Context(), jwtUser.ID)
-	if err == nil {
-		ErrorJSON(w, "user already registered", http.StatusBadRequest)
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully updated profile",
+		Data:    u,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+type SetHandleRequest struct {
+	Handle string `json:"handle"`
+}
+
+// SetHandle godoc
+//
+//	@Tags			users
+//	@Summary		sets the handle of the user
+//	@Description	sets the unique @handle of the user, 3 to 20 letters, digits or underscores starting with a letter. Handles are unique whatever their case, reserved names are refused and after the first one a handle can be changed once per cooldown.
+//	@Accept 		json
+//	@Produce		json
+//	@Param			request	body		SetHandleRequest	true	"handle"
+//	@Success		200		{object}	CommonResponse{data=user.User}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		409		{object}	CommonResponse
+//	@Failure		429		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/me/handle [put]
+func (c *UserCtrl) setHandle(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
 		return
 	}
 
-	if err != nil && err != user.ErrUserNotFound {
-		ErrorJSON(w, "failed to check if user is already registered or not", http.StatusInternalServerError)
+	// 2. read request
+	var req SetHandleRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "invalid request")
+		return
+	}
+
+	// 3. set handle
+	u, err := c.user.SetHandle(r.Context(), jwtUser.ID, strings.TrimPrefix(req.Handle, "@"))
+	if err != nil {
+		switch err {
+		case user.ErrInvalidHandle, user.ErrHandleBlocked:
+			ErrorJSON(w, err.Error())
+		case user.ErrHandleTaken, user.ErrUserChanged:
+			ErrorJSON(w, err.Error(), http.StatusConflict)
+		case user.ErrHandleCooldown:
+			c.handleCooldown(w, r, jwtUser.ID)
+		case user.ErrUserNotFound:
+			ErrorJSON(w, "user not registered yet", http.StatusNotFound)
+		default:
+			logger.Error("failed to set handle", "error", err)
+			ErrorJSON(w, "failed to set handle", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	accountAddress := web3.HexToAddress(rawAccountAddress)
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully set handle",
+		Data:    u,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
 
-	// 5. check if account address has tba or not
-	tba, err := c.ticket.TbaByAddress(r.Context(), accountAddress)
+// handleCooldown tells the user when they may change their handle again.
+func (c *UserCtrl) handleCooldown(w http.ResponseWriter, r *http.Request, id string) {
+	u, err := c.user.FindUserByID(r.Context(), id)
 	if err != nil {
-		logger.Error("failed to get tba by address", "error", err)
-		ErrorJSON(w, "failed to get tba by address", http.StatusInternalServerError)
+		ErrorJSON(w, user.ErrHandleCooldown.Error(), http.StatusTooManyRequests)
 		return
 	}
 
-	// TODO: uri should be dynamic
-	uri := "https://ipfs.io/ipfs/QmfFbvLH37DebBqmVBm7V8ecfzgjFPnPeHRYiYk1PNoW84/2level.png"
+	ends := c.user.HandleCooldownEnds(u)
+	ErrorJSON(w, fmt.Sprintf("%s, it can be changed again after %s", user.ErrHandleCooldown, ends.UTC().Format(time.RFC3339)), http.StatusTooManyRequests)
+}
 
-	if tba == nil || tba.Big().Cmp(big.NewInt(0)) == 0 {
-		// 5-1. if tba does not exist, create tba
-		tbaCreated, err := c.ticket.CreateTBA(r.Context(), accountAddress, uri)
-		if err != nil {
-			logger.Error("failed to create tba", "error", err)
-			ErrorJSON(w, "failed to create tba", http.StatusInternalServerError)
+// UploadAvatar godoc
+//
+//	@Tags			users
+//	@Summary		uploads the avatar of the user
+//	@Description	uploads a jpeg, png or gif of up to 5MB, cropped to a square and resized to 400x400 before being pinned to ipfs
+//	@Accept 		mpfd
+//	@Produce		json
+//	@Param			avatar	formData	file	true	"avatar image"
+//	@Success		200		{object}	CommonResponse{data=user.User}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		413		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/me/avatar [post]
+func (c *UserCtrl) uploadAvatar(w http.ResponseWriter, r *http.Request) {
+	c.uploadImage(w, r, "avatar", imaging.Avatar)
+}
+
+// UploadBanner godoc
+//
+//	@Tags			users
+//	@Summary		uploads the banner of the user
+//	@Description	uploads a jpeg, png or gif of up to 10MB, cropped to 3:1 and resized to 1500x500 before being pinned to ipfs
+//	@Accept 		mpfd
+//	@Produce		json
+//	@Param			banner	formData	file	true	"banner image"
+//	@Success		200		{object}	CommonResponse{data=user.User}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		413		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/me/banner [post]
+func (c *UserCtrl) uploadBanner(w http.ResponseWriter, r *http.Request) {
+	c.uploadImage(w, r, "banner", imaging.Banner)
+}
+
+// uploadImage pins the image in the form field to ipfs and sets it as the avatar or banner of the user.
+func (c *UserCtrl) uploadImage(w http.ResponseWriter, r *http.Request, field string, spec imaging.Spec) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. read image from form data, the limit leaves room for the multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, spec.MaxBytes+MaxBodyBytes)
+
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			ErrorJSON(w, imaging.ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
 			return
 		}
+		ErrorJSON(w, fmt.Sprintf("%s image is required", field))
+		return
+	}
+	defer file.Close()
 
-		tba = &tbaCreated.Account
+	// 3. check and resize image
+	img, err := imaging.Process(file, spec)
+	if err != nil {
+		switch err {
+		case imaging.ErrTooLarge:
+			ErrorJSON(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case imaging.ErrUnsupportedType, imaging.ErrInvalidImage:
+			ErrorJSON(w, err.Error())
+		default:
+			logger.Error("failed to process image", "error", err)
+			ErrorJSON(w, "failed to process image", http.StatusInternalServerError)
+		}
+		return
 	}
 
-	tokenBalance, err := c.ticket.TokenBalanceOf(r.Context(), *tba)
+	// 4. upload image to ipfs
+	pinResp, err := c.ipfs.PinFile(r.Context(), bytes.NewReader(img.Data), uuid.New().String()+img.Ext)
 	if err != nil {
-		logger.Error("failed to get token balance", "error", err)
-		ErrorJSON(w, "failed to get token balance", http.StatusInternalServerError)
+		logger.Error("failed to pin file to ipfs", "error", err)
+		ErrorJSON(w, "failed to pin file to ipfs", http.StatusInternalServerError)
 		return
 	}
 
-	tbaAddress := strings.ToLower(tba.Hex())
+	url := fmt.Sprintf("https://ipfs.io/ipfs/%s", pinResp.IpfsHash)
+
+	// 5. set image on user
+	params := user.UpdateUserParams{ID: jwtUser.ID}
+	if field == "avatar" {
+		params.Avatar = url
+	} else {
+		params.Banner = url
+	}
 
-	// 6. create user
-	params := user.CreateUserParams{
-		ID:              jwtUser.ID,
-		AccountAddress:  rawAccountAddress,
-		TbaAddress:      tbaAddress,
-		Name:            rawAccountAddress,
-		Avatar:          uri,
-		TbaTokenBalance: tokenBalance.String(),
-		IsAdmin:         false,
+	if err := c.user.UpdateUser(r.Context(), params); err != nil {
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "user not registered yet", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to update user", "error", err)
+		ErrorJSON(w, "failed to update user", http.StatusInternalServerError)
+		return
 	}
 
-	u, err := c.user.CreateUser(r.Context(), params)
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
 	if err != nil {
-		logger.Error("failed to create user", "error", err)
-		ErrorJSON(w, "failed to create user", http.StatusInternalServerError)
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
 		return
 	}
 
-	// 7. return user as json response
 	resp := CommonResponse{
-		Status:  http.StatusCreated,
-		Message: "Successfully created user",
+		Status:  http.StatusOK,
+		Message: fmt.Sprintf("Successfully uploaded %s", field),
 		Data:    u,
 	}
 
-	_ = WriteJSON(w, http.StatusCreated, resp)
+	_ = WriteJSON(w, http.StatusOK, resp)
 }
 
-// UpdateTokenBalance godoc
+// UserExport is the personal data kept about a user off-chain.
+type UserExport struct {
+	ExportedAt int64      `json:"exportedAt"`
+	User       *user.User `json:"user"`
+	// GrantEvents are the roles and permissions granted to and revoked from the user
+	GrantEvents       []*user.GrantEvent         `json:"grantEvents"`
+	Claims            []*did.Claim               `json:"claims"`
+	IssuedCollections []*ticket.TicketCollection `json:"issuedCollections"`
+	Checkins          []*checkin.Checkin         `json:"checkins"`
+	// Notifications are the jobs the user started, whose progress and results were pushed to them
+	Notifications []*job.Job `json:"notifications"`
+}
+
+// ExportData godoc
 //
 //	@Tags			users
-//	@Summary		updates token balance
-//	@Description	updates token balance
+//	@Summary		exports the data of the user
+//	@Description	returns the user record, credential claims, collections issued on every network, check-ins, notifications and role changes of the user as a json download
 //	@Produce		json
-//	@Success		201		{object}	CommonResponse{data=string}
+//	@Success		200		{object}	CommonResponse{data=UserExport}
 //	@Failure		400		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
 //	@Failure		500		{object}	CommonResponse
 //	@Security 		BearerAuth
-//	@Router			/v1/users/update-token-balance [post]
-func (c *UserCtrl) updateTokenBalance(w http.ResponseWriter, r *http.Request) {
+//	@Router			/v1/users/me/export [get]
+func (c *UserCtrl) exportData(w http.ResponseWriter, r *http.Request) {
 	// 1. get user from context
 	jwtUser, err := c.jwt.FromContext(r.Context())
 	if err != nil {
@@ -472,35 +761,676 @@ func (c *UserCtrl) updateTokenBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tbaAddress := web3.HexToAddress(u.TbaAddress)
+	// 3. collect the data of the user
+	export, err := c.collectExport(r.Context(), u)
+	if err != nil {
+		logger.Error("failed to export user data", "error", err)
+		ErrorJSON(w, "failed to export user data", http.StatusInternalServerError)
+		return
+	}
 
-	// 3. get token balance
-	balance, err := c.ticket.TokenBalanceOf(r.Context(), tbaAddress)
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully exported user data",
+		Data:    export,
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"heroticket-export-%d.json\"", export.ExportedAt))
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *UserCtrl) collectExport(ctx context.Context, u *user.User) (*UserExport, error) {
+	export := &UserExport{
+		ExportedAt:  time.Now().Unix(),
+		User:        u,
+		GrantEvents: []*user.GrantEvent{},
+	}
+
+	for page := int64(1); ; page++ {
+		events, err := c.user.FindGrantEvents(ctx, user.GrantEventFilter{UserID: u.ID, Page: page, Limit: pagination.MaxLimit})
+		if err != nil {
+			return nil, err
+		}
+
+		export.GrantEvents = append(export.GrantEvents, events.Items...)
+
+		if !events.Pagination.HasNext {
+			break
+		}
+	}
+
+	claims, err := c.did.FindClaims(ctx, u.ID)
 	if err != nil {
-		logger.Error("failed to get token balance", "error", err)
-		ErrorJSON(w, "failed to get token balance", http.StatusInternalServerError)
+		return nil, err
+	}
+	export.Claims = claims
+
+	issued, err := issuedCollections(ctx, c.networks, u)
+	if err != nil {
+		return nil, err
+	}
+	export.IssuedCollections = issued
+
+	checkins, err := c.checkins.FindCheckinsByHolder(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.Checkins = checkins
+
+	jobs, err := c.jobs.FindJobsByUser(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.Notifications = jobs
+
+	return export, nil
+}
+
+type DeleteAccountRequest struct {
+	// AccountAddress is the primary wallet of the user, to confirm the deletion
+	AccountAddress string `json:"accountAddress"`
+}
+
+// DeleteAccount godoc
+//
+//	@Tags			users
+//	@Summary		deletes the account of the user
+//	@Description	revokes the credentials issued to the user, removes the user from check-ins and notifications, logs out every session and deletes the user record. Tickets, tbas and collections on-chain stay, the role change audit log is kept.
+//	@Accept 		json
+//	@Produce		json
+//	@Param			request	body		DeleteAccountRequest	true	"primary wallet of the user, as confirmation"
+//	@Success		200		{object}	CommonResponse
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		403		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/me [delete]
+func (c *UserCtrl) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
 		return
 	}
 
-	// 4. update user
-	u.TbaTokenBalance = balance.String()
+	// 2. read request
+	var req DeleteAccountRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		ErrorJSON(w, "invalid request")
+		return
+	}
 
-	err = c.user.UpdateUser(r.Context(), user.UpdateUserParams{
-		ID:              u.ID,
-		TBATokenBalance: balance.String(),
-	})
+	// 3. get user from db and check the confirmation
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
 	if err != nil {
-		logger.Error("failed to update user", "error", err)
-		ErrorJSON(w, "failed to update user", http.StatusInternalServerError)
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "user not registered yet", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
 		return
 	}
 
-	// 5. return token balance as json response
-	resp := CommonResponse{
-		Status:  http.StatusCreated,
-		Message: "Successfully updated token balance",
-		Data:    balance.String(),
+	if !strings.EqualFold(req.AccountAddress, u.AccountAddress) {
+		ErrorJSON(w, "account address does not match the primary wallet")
+		return
 	}
 
-	_ = WriteJSON(w, http.StatusCreated, resp)
+	// the admin identity issues every credential
+	if u.IsAdmin {
+		ErrorJSON(w, "the admin account cannot be deleted", http.StatusForbidden)
+		return
+	}
+
+	// 4. revoke the credentials issued to the user, each is forgotten once revoked so a retry goes on
+	admin, err := c.user.FindAdmin(r.Context())
+	if err != nil {
+		logger.Error("failed to find admin", "error", err)
+		ErrorJSON(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	claims, err := c.did.FindClaims(r.Context(), u.ID)
+	if err != nil {
+		logger.Error("failed to find claims", "error", err)
+		ErrorJSON(w, "failed to find claims", http.StatusInternalServerError)
+		return
+	}
+
+	for _, claim := range claims {
+		if err := c.did.RevokeClaim(r.Context(), admin.ID, claim); err != nil {
+			logger.Error("failed to revoke claim", "error", err, "claim", claim.ID)
+			ErrorJSON(w, "failed to revoke claims", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// 5. remove the user from check-ins and notifications
+	if err := c.checkins.AnonymizeHolder(r.Context(), u.ID); err != nil {
+		logger.Error("failed to anonymize check-ins", "error", err)
+		ErrorJSON(w, "failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.jobs.AnonymizeUserJobs(r.Context(), u.ID); err != nil {
+		logger.Error("failed to anonymize jobs", "error", err)
+		ErrorJSON(w, "failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	// 6. log out every session before the user is gone, so no refresh token outlives the account.
+	// A failed deletion is retried after logging in again.
+	if err := c.jwt.RevokeAllSessions(r.Context(), u.ID); err != nil {
+		logger.Error("failed to revoke sessions", "error", err)
+		ErrorJSON(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	// 7. delete user
+	if err := c.user.DeleteUser(r.Context(), u.ID); err != nil {
+		logger.Error("failed to delete user", "error", err)
+		ErrorJSON(w, "failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("account deleted", "id", u.ID, "claims", len(claims))
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully deleted account",
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// SiweChallenge godoc
+//
+//	@Tags			users
+//	@Summary		returns a sign-in with ethereum message
+//	@Description	returns an EIP-4361 message proving control of the wallet, to sign with personal_sign and send to register
+//	@Produce		json
+//	@Param			accountAddress 	path	string	true	"account address"
+//	@Success		200		{object}	CommonResponse{data=auth.SiweChallenge}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/siwe/{accountAddress} [get]
+func (c *UserCtrl) siweChallenge(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. get account address from path params
+	rawAccountAddress := strings.ToLower(chi.URLParam(r, "accountAddress"))
+
+	if !web3.IsAddressValid(rawAccountAddress) {
+		ErrorJSON(w, "invalid account address")
+		return
+	}
+
+	// 3. create challenge
+	challenge, err := c.auth.SiweChallenge(r.Context(), auth.SiweChallengeParams{
+		Subject: jwtUser.ID,
+		Address: web3.HexToAddress(rawAccountAddress),
+	})
+	if err != nil {
+		logger.Error("failed to create sign-in challenge", "error", err)
+		ErrorJSON(w, "failed to create sign-in challenge", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: "Successfully created sign-in challenge",
+		Data:    challenge,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// SiweProof is a signed sign-in challenge.
+type SiweProof struct {
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// TODO: uri should be dynamic
+const defaultAvatar = "https://ipfs.io/ipfs/QmfFbvLH37DebBqmVBm7V8ecfzgjFPnPeHRYiYk1PNoW84/2level.png"
+
+// verifySiwe reads a SiweProof from the body and verifies the subject signed it with the wallet.
+// It writes the error response and returns false when the proof does not hold.
+func (c *UserCtrl) verifySiwe(w http.ResponseWriter, r *http.Request, subject, accountAddress string) bool {
+	var proof SiweProof
+
+	if err := ReadJSON(w, r, &proof); err != nil {
+		ErrorJSON(w, "invalid request body")
+		return false
+	}
+
+	err := c.auth.SiweVerify(r.Context(), auth.SiweVerifyParams{
+		Subject:   subject,
+		Address:   web3.HexToAddress(accountAddress),
+		Nonce:     proof.Nonce,
+		Signature: proof.Signature,
+	})
+	if err != nil {
+		switch err {
+		case auth.ErrSiweChallengeNotFound, auth.ErrSiweInvalidSignature, auth.ErrSiweSignerMismatch, auth.ErrSiweSubjectMismatch:
+			ErrorJSON(w, err.Error(), http.StatusUnauthorized)
+		default:
+			logger.Error("failed to verify sign-in proof", "error", err)
+			ErrorJSON(w, "failed to verify sign-in proof", http.StatusInternalServerError)
+		}
+		return false
+	}
+
+	return true
+}
+
+// Register godoc
+//
+//	@Tags			users
+//	@Summary		registers user
+//	@Description	registers user with a wallet proven by a signed sign-in challenge. A wallet registered to another identity is relinked to the caller with its profile, delegations, check-ins and jobs, unless that identity is an admin, holds grants or is moderated. A wallet linked to another identity is unlinked from it.
+//	@Accept			json
+//	@Produce		json
+//	@Param			accountAddress 	path	string		true	"account address"
+//	@Param			proof			body	SiweProof	true	"signed sign-in challenge"
+//	@Param			sessionId		query	string	false	"session id to push the result to"
+//	@Success		200		{object}	CommonResponse{data=user.User}
+//	@Success		202		{object}	CommonResponse{data=job.Job}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		401		{object}	CommonResponse
+//	@Failure		403		{object}	CommonResponse
+//	@Failure		409		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/register/{accountAddress} [post]
+func (c *UserCtrl) register(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. get account address from path params
+	rawAccountAddress := strings.ToLower(chi.URLParam(r, "accountAddress"))
+
+	// 3. validate account address
+	if !web3.IsAddressValid(rawAccountAddress) {
+		ErrorJSON(w, "invalid account address", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. check if id is already registered or not
+	_, err = c.user.FindUserByID(r.Context(), jwtUser.ID)
+	if err == nil {
+		ErrorJSON(w, "user already registered", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil && err != user.ErrUserNotFound {
+		ErrorJSON(w, "failed to check if user is already registered or not", http.StatusInternalServerError)
+		return
+	}
+
+	// 5. verify the caller controls the wallet
+	if !c.verifySiwe(w, r, jwtUser.ID, rawAccountAddress) {
+		return
+	}
+
+	// 6. relink the wallet when another identity registered it
+	linked, err := c.user.FindUserByAccountAddress(r.Context(), rawAccountAddress)
+	if err != nil && err != user.ErrUserNotFound {
+		logger.Error("failed to find user by account address", "error", err)
+		ErrorJSON(w, "failed to find user by account address", http.StatusInternalServerError)
+		return
+	}
+
+	if linked != nil && linked.AccountAddress != rawAccountAddress {
+		// 6-1. a secondary wallet is only unlinked from the other identity, then registered as usual
+		if _, err := c.user.UnlinkWallet(r.Context(), linked.ID, rawAccountAddress); err != nil {
+			logger.Error("failed to unlink wallet", "error", err)
+			ErrorJSON(w, "failed to unlink wallet", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("unlinked wallet", "accountAddress", rawAccountAddress, "from", linked.ID, "to", jwtUser.ID)
+
+		linked = nil
+	}
+
+	if linked != nil {
+		// 6-2. admin rights, grants and moderation stay with the identity they were given to
+		if err := linked.CanRelink(time.Now().Unix()); err != nil {
+			ErrorJSON(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		// 6-3. move the data keyed by the old identity first, a failed relink is retried by registering again
+		if err := c.staffs.ReassignStaff(r.Context(), linked.ID, jwtUser.ID); err != nil {
+			logger.Error("failed to move staff delegations", "error", err)
+			ErrorJSON(w, "failed to move staff delegations", http.StatusInternalServerError)
+			return
+		}
+
+		if err := c.nullifiers.ReassignUser(r.Context(), linked.ID, jwtUser.ID); err != nil {
+			logger.Error("failed to move nullifiers", "error", err)
+			ErrorJSON(w, "failed to move nullifiers", http.StatusInternalServerError)
+			return
+		}
+
+		if err := c.checkins.ReassignHolder(r.Context(), linked.ID, jwtUser.ID); err != nil {
+			logger.Error("failed to move check-ins", "error", err)
+			ErrorJSON(w, "failed to move check-ins", http.StatusInternalServerError)
+			return
+		}
+
+		if err := c.jobs.ReassignUserJobs(r.Context(), linked.ID, jwtUser.ID); err != nil {
+			logger.Error("failed to move jobs", "error", err)
+			ErrorJSON(w, "failed to move jobs", http.StatusInternalServerError)
+			return
+		}
+
+		// 6-4. log out every session of the old identity
+		if err := c.jwt.RevokeAllSessions(r.Context(), linked.ID); err != nil {
+			logger.Error("failed to revoke sessions", "error", err)
+			ErrorJSON(w, "failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+
+		// 6-5. move the user record
+		u, err := c.user.RelinkUser(r.Context(), linked.ID, jwtUser.ID)
+		if err != nil {
+			logger.Error("failed to relink user", "error", err)
+
+			switch err {
+			case user.ErrRelinkRefused:
+				ErrorJSON(w, err.Error(), http.StatusForbidden)
+			case user.ErrUserChanged:
+				ErrorJSON(w, err.Error(), http.StatusConflict)
+			default:
+				ErrorJSON(w, "failed to relink user", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		logger.Info("relinked wallet", "accountAddress", rawAccountAddress, "from", linked.ID, "to", jwtUser.ID)
+
+		resp := CommonResponse{
+			Status:  http.StatusOK,
+			Message: "Successfully relinked wallet",
+			Data:    u,
+		}
+
+		_ = WriteJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	// 7. enqueue registration, the result is pushed to the ws session if given
+	key, renew := jobKey(r, jobRegister, jwtUser.ID, rawAccountAddress)
+
+	j, err := c.jobs.Enqueue(r.Context(), job.EnqueueParams{
+		Type:      jobRegister,
+		Key:       key,
+		Renew:     renew,
+		UserID:    jwtUser.ID,
+		SessionID: r.URL.Query().Get("sessionId"),
+		Payload: registerPayload{
+			UserID:         jwtUser.ID,
+			AccountAddress: rawAccountAddress,
+			Avatar:         defaultAvatar,
+		},
+	})
+	if err != nil {
+		logger.Error("failed to enqueue registration", "error", err)
+		ErrorJSON(w, "failed to register user", http.StatusInternalServerError)
+		return
+	}
+
+	// 8. return accepted job
+	writeJobAccepted(w, j)
+}
+
+// UpdateTokenBalance godoc
+//
+//	@Tags			users
+//	@Summary		updates token balance
+//	@Description	updates token balance
+//	@Produce		json
+//	@Success		201		{object}	CommonResponse{data=string}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/update-token-balance [post]
+func (c *UserCtrl) updateTokenBalance(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. get user from db
+	u, err := c.user.FindUserByID(r.Context(), jwtUser.ID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "user not registered yet", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
+		return
+	}
+
+	tbaAddress := web3.HexToAddress(u.TbaAddress)
+
+	// 3. get token balance
+	balance, err := c.ticket.TokenBalanceOf(r.Context(), tbaAddress)
+	if err != nil {
+		logger.Error("failed to get token balance", "error", err)
+		ErrorJSON(w, "failed to get token balance", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. update user
+	u.TbaTokenBalance = balance.String()
+
+	err = c.user.UpdateUser(r.Context(), user.UpdateUserParams{
+		ID:              u.ID,
+		TBATokenBalance: balance.String(),
+	})
+	if err != nil {
+		logger.Error("failed to update user", "error", err)
+		ErrorJSON(w, "failed to update user", http.StatusInternalServerError)
+		return
+	}
+
+	// 5. return token balance as json response
+	resp := CommonResponse{
+		Status:  http.StatusCreated,
+		Message: "Successfully updated token balance",
+		Data:    balance.String(),
+	}
+
+	_ = WriteJSON(w, http.StatusCreated, resp)
+}
+
+// LinkWallet godoc
+//
+//	@Tags			users
+//	@Summary		links a wallet
+//	@Description	links another wallet, proven by a signed sign-in challenge, to the registered user. Tickets held by the tba of any linked wallet count as the user's.
+//	@Accept			json
+//	@Produce		json
+//	@Param			accountAddress 	path	string		true	"account address"
+//	@Param			proof			body	SiweProof	true	"signed sign-in challenge"
+//	@Param			sessionId		query	string	false	"session id to push the result to"
+//	@Success		202		{object}	CommonResponse{data=job.Job}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		401		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		409		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/wallets/{accountAddress} [post]
+func (c *UserCtrl) linkWallet(w http.ResponseWriter, r *http.Request) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. get account address from path params
+	rawAccountAddress := strings.ToLower(chi.URLParam(r, "accountAddress"))
+
+	if !web3.IsAddressValid(rawAccountAddress) {
+		ErrorJSON(w, "invalid account address")
+		return
+	}
+
+	// 3. the user must be registered
+	if _, err := c.user.FindUserByID(r.Context(), jwtUser.ID); err != nil {
+		if err == user.ErrUserNotFound {
+			ErrorJSON(w, "user not registered yet", http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to find user", "error", err)
+		ErrorJSON(w, "failed to find user", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. the wallet must not belong to another user
+	linked, err := c.user.FindUserByAccountAddress(r.Context(), rawAccountAddress)
+	if err != nil && err != user.ErrUserNotFound {
+		logger.Error("failed to find user by account address", "error", err)
+		ErrorJSON(w, "failed to find user by account address", http.StatusInternalServerError)
+		return
+	}
+
+	if linked != nil && linked.ID != jwtUser.ID {
+		ErrorJSON(w, user.ErrWalletAlreadyLinked.Error(), http.StatusConflict)
+		return
+	}
+
+	// 5. verify the caller controls the wallet
+	if !c.verifySiwe(w, r, jwtUser.ID, rawAccountAddress) {
+		return
+	}
+
+	// 6. enqueue linking, the tba of the wallet may have to be created first
+	key, renew := jobKey(r, jobLinkWallet, jwtUser.ID, rawAccountAddress)
+
+	j, err := c.jobs.Enqueue(r.Context(), job.EnqueueParams{
+		Type:      jobLinkWallet,
+		Key:       key,
+		Renew:     renew,
+		UserID:    jwtUser.ID,
+		SessionID: r.URL.Query().Get("sessionId"),
+		Payload: linkWalletPayload{
+			UserID:         jwtUser.ID,
+			AccountAddress: rawAccountAddress,
+			Avatar:         defaultAvatar,
+		},
+	})
+	if err != nil {
+		logger.Error("failed to enqueue wallet link", "error", err)
+		ErrorJSON(w, "failed to link wallet", http.StatusInternalServerError)
+		return
+	}
+
+	// 7. return accepted job
+	writeJobAccepted(w, j)
+}
+
+// UnlinkWallet godoc
+//
+//	@Tags			users
+//	@Summary		unlinks a wallet
+//	@Description	unlinks a secondary wallet from the user, the primary wallet has to be changed first
+//	@Produce		json
+//	@Param			accountAddress 	path	string	true	"account address"
+//	@Success		200		{object}	CommonResponse{data=user.User}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		409		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/wallets/{accountAddress} [delete]
+func (c *UserCtrl) unlinkWallet(w http.ResponseWriter, r *http.Request) {
+	c.updateWallets(w, r, "Successfully unlinked wallet", c.user.UnlinkWallet)
+}
+
+// SetPrimaryWallet godoc
+//
+//	@Tags			users
+//	@Summary		sets the primary wallet
+//	@Description	makes a linked wallet the primary one, the one purchased tickets go to
+//	@Produce		json
+//	@Param			accountAddress 	path	string	true	"account address"
+//	@Success		200		{object}	CommonResponse{data=user.User}
+//	@Failure		400		{object}	CommonResponse
+//	@Failure		404		{object}	CommonResponse
+//	@Failure		409		{object}	CommonResponse
+//	@Failure		500		{object}	CommonResponse
+//	@Security 		BearerAuth
+//	@Router			/v1/users/wallets/{accountAddress}/primary [put]
+func (c *UserCtrl) setPrimaryWallet(w http.ResponseWriter, r *http.Request) {
+	c.updateWallets(w, r, "Successfully set primary wallet", c.user.SetPrimaryWallet)
+}
+
+func (c *UserCtrl) updateWallets(w http.ResponseWriter, r *http.Request, message string, update func(ctx context.Context, id, accountAddress string) (*user.User, error)) {
+	// 1. get user from context
+	jwtUser, err := c.jwt.FromContext(r.Context())
+	if err != nil {
+		logger.Error("failed to get user from context", "error", err)
+		ErrorJSON(w, "user not found")
+		return
+	}
+
+	// 2. get account address from path params
+	rawAccountAddress := strings.ToLower(chi.URLParam(r, "accountAddress"))
+
+	if !web3.IsAddressValid(rawAccountAddress) {
+		ErrorJSON(w, "invalid account address")
+		return
+	}
+
+	// 3. update the wallets of the user
+	u, err := update(r.Context(), jwtUser.ID, rawAccountAddress)
+	if err != nil {
+		switch err {
+		case user.ErrUserNotFound:
+			ErrorJSON(w, "user not registered yet", http.StatusNotFound)
+		case user.ErrWalletNotLinked:
+			ErrorJSON(w, err.Error(), http.StatusNotFound)
+		case user.ErrPrimaryWallet, user.ErrUserChanged:
+			ErrorJSON(w, err.Error(), http.StatusConflict)
+		default:
+			logger.Error("failed to update wallets", "error", err)
+			ErrorJSON(w, "failed to update wallets", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := CommonResponse{
+		Status:  http.StatusOK,
+		Message: message,
+		Data:    u,
+	}
+
+	_ = WriteJSON(w, http.StatusOK, resp)
 }
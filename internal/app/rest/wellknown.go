@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/heroticket/internal/service/jwt"
+)
+
+// WellKnownCtrl serves the documents other services discover us by, outside the versioned api.
+type WellKnownCtrl struct {
+	jwt jwt.Service
+}
+
+func NewWellKnownCtrl(jwt jwt.Service) *WellKnownCtrl {
+	return &WellKnownCtrl{
+		jwt: jwt,
+	}
+}
+
+func (c *WellKnownCtrl) Pattern() string {
+	return "/.well-known"
+}
+
+func (c *WellKnownCtrl) Handler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/jwks.json", c.jwks)
+
+	return r
+}
+
+// JWKS godoc
+//
+//	@Tags			common
+//	@Summary		returns the token signing keys
+//	@Description	returns the public keys access and refresh tokens are signed with as a JSON Web Key Set, select the key by the kid header of a token
+//	@Produce		json
+//	@Success		200	{object}	jwt.JWKS
+//	@Router			/.well-known/jwks.json [get]
+func (c *WellKnownCtrl) jwks(w http.ResponseWriter, r *http.Request) {
+	// new keys are published well before they sign, so verifiers may cache the set for a while
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	_ = WriteJSON(w, http.StatusOK, c.jwt.JWKS())
+}
@@ -20,6 +20,19 @@ func New(ctx context.Context, addr string) (*cache.Cache, error) {
 	}), nil
 }
 
+// NewRemote returns a cache without the local in-process layer, for state every server must see
+// as soon as it is written.
+func NewRemote(ctx context.Context, addr string) (*cache.Cache, error) {
+	client, err := new(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.New(&cache.Options{
+		Redis: client,
+	}), nil
+}
+
 func new(ctx context.Context, addr string) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr: addr,
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/heroticket/internal/config"
+	"github.com/heroticket/internal/service/jwt"
+	"github.com/heroticket/internal/service/user"
+)
+
+func newKeyRing(ctx context.Context, cfg config.JwtServiceConfig, repo jwt.KeyRepository) (*jwt.KeyRing, error) {
+	encryptionKey, err := base64.StdEncoding.DecodeString(cfg.KeyEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt key encryption key: %w", err)
+	}
+
+	return jwt.NewKeyRing(ctx, jwt.KeyRingConfig{
+		Repo:           repo,
+		Algorithm:      jwt.Algorithm(cfg.Algorithm),
+		EncryptionKey:  encryptionKey,
+		RotationPeriod: time.Duration(cfg.KeyRotationDays) * 24 * time.Hour,
+		// tokens of a replaced key verify as long as a refresh token it signed lives
+		GracePeriod: time.Duration(cfg.RefreshTokenExpiry) * time.Second,
+	})
+}
+
+func jwtOptions(cfg config.JwtServiceConfig) []jwt.Option {
+	opts := []jwt.Option{jwt.WithAudience(cfg.Audience), jwt.WithIssuer(cfg.Issuer)}
+
+	if cfg.AccessTokenExpiry > 0 {
+		opts = append(opts, jwt.WithAccessTokenExpiry(time.Duration(cfg.AccessTokenExpiry)*time.Second))
+	}
+
+	if cfg.RefreshTokenExpiry > 0 {
+		opts = append(opts, jwt.WithRefreshTokenExpiry(time.Duration(cfg.RefreshTokenExpiry)*time.Second))
+	}
+
+	return opts
+}
+
+// userClaims returns the roles and permissions of a user for access tokens, refusing suspended users.
+// Users who logged in but did not register yet hold the user role only.
+func userClaims(users user.Service) jwt.ClaimsFunc {
+	return func(ctx context.Context, userID string) ([]string, []string, error) {
+		u, err := users.FindUserByID(ctx, userID)
+		if err != nil && err != user.ErrUserNotFound {
+			return nil, nil, err
+		}
+
+		if u == nil {
+			u = &user.User{ID: userID}
+		}
+
+		if u.Suspended(time.Now().Unix()) {
+			return nil, nil, jwt.ErrUserSuspended
+		}
+
+		roles := u.EffectiveRoles()
+		perms := u.EffectivePermissions()
+
+		roleNames := make([]string, len(roles))
+		for i, r := range roles {
+			roleNames[i] = string(r)
+		}
+
+		permNames := make([]string, len(perms))
+		for i, p := range perms {
+			permNames[i] = string(p)
+		}
+
+		return roleNames, permNames, nil
+	}
+}
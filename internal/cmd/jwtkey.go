@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heroticket/internal/config"
+	"github.com/heroticket/internal/db/mongo"
+	"github.com/heroticket/internal/service/jwt"
+	jwtrepo "github.com/heroticket/internal/service/jwt/repository/mongo"
+)
+
+const jwtKeyUsage = `usage: jwtkey [-config file] <command>
+
+commands:
+  list      list the keys tokens are signed and verified with
+  generate  create the first key of an empty key ring
+  rotate    create a new signing key, the current keys verify for the grace period after it signs
+`
+
+// JwtKey manages the jwt signing keys of the server config and returns the exit code.
+func JwtKey(args []string) int {
+	fs := flag.NewFlagSet("jwtkey", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), jwtKeyUsage) }
+
+	configFile := fs.String("config", "", "server config file, config.json (production) or config.dev.json when empty")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if *configFile == "" && os.Getenv("GO_ENV") != "production" {
+		*configFile = "config.dev.json"
+	}
+
+	if err := jwtKey(fs.Arg(0), *configFile, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "jwtkey:", err)
+		return 1
+	}
+
+	return 0
+}
+
+func jwtKey(command, configFile string, out io.Writer) error {
+	cfg, err := config.NewServerConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mongoClient, err := mongo.New(ctx, cfg.MongoUrl)
+	if err != nil {
+		return err
+	}
+	defer mongoClient.Disconnect(context.Background())
+
+	repo, err := jwtrepo.New(ctx, mongoClient, cfg.Jwt.DbName)
+	if err != nil {
+		return err
+	}
+
+	keys, err := newKeyRing(ctx, cfg.Jwt, repo)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+	case "generate":
+		if len(keys.Keys()) > 0 {
+			return fmt.Errorf("key ring already has keys, use rotate")
+		}
+
+		if _, err := keys.Generate(ctx); err != nil {
+			return err
+		}
+	case "rotate":
+		if _, err := keys.Rotate(ctx); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	return printKeys(out, keys.Keys())
+}
+
+func printKeys(out io.Writer, keys []jwt.Key) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "KID\tALGORITHM\tCREATED\tACTIVE\tEXPIRES")
+
+	for _, k := range keys {
+		expires := "-"
+		if k.ExpiresAt != 0 {
+			expires = formatUnix(k.ExpiresAt)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.ID, k.Algorithm, formatUnix(k.CreatedAt), formatUnix(k.ActiveAt), expires)
+	}
+
+	return w.Flush()
+}
+
+func formatUnix(sec int64) string {
+	return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+}
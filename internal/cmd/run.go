@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"syscall"
@@ -17,18 +21,29 @@ import (
 	"github.com/heroticket/internal/db/mongo"
 	"github.com/heroticket/internal/logger"
 	"github.com/heroticket/internal/service/auth"
+	"github.com/heroticket/internal/service/checkin"
+	crepo "github.com/heroticket/internal/service/checkin/repository/mongo"
 	"github.com/heroticket/internal/service/did"
 	drepo "github.com/heroticket/internal/service/did/repository/mongo"
 	"github.com/heroticket/internal/service/ipfs"
+	"github.com/heroticket/internal/service/job"
+	jrepo "github.com/heroticket/internal/service/job/repository/mongo"
 	"github.com/heroticket/internal/service/jwt"
+	jwtrepo "github.com/heroticket/internal/service/jwt/repository/mongo"
 	"github.com/heroticket/internal/service/notice"
 	nrepo "github.com/heroticket/internal/service/notice/repository/mongo"
+	"github.com/heroticket/internal/service/nullifier"
+	nullrepo "github.com/heroticket/internal/service/nullifier/repository/mongo"
+	"github.com/heroticket/internal/service/staff"
+	srepo "github.com/heroticket/internal/service/staff/repository/mongo"
 	"github.com/heroticket/internal/service/ticket"
 	trepo "github.com/heroticket/internal/service/ticket/repository/mongo"
 	"github.com/heroticket/internal/service/user"
 	urepo "github.com/heroticket/internal/service/user/repository/mongo"
 	"github.com/heroticket/internal/web3"
+	txrepo "github.com/heroticket/internal/web3/repository/mongo"
 	"github.com/heroticket/pkg/contracts/heroticket"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
 )
 
 func Run() {
@@ -72,23 +87,35 @@ func Run() {
 
 	logger.Info("Successfully connected to Redis for DID")
 
+	jwtRedis, err := redis.NewRemote(ctx, cfg.Jwt.RedisUrl)
+	handleErr(err)
+
+	logger.Info("Successfully connected to Redis for JWT")
+
 	authCache := redis.NewCache(authRedis)
 	didCache := redis.NewCache(didRedis)
+	jwtCache := redis.NewCache(jwtRedis)
+
+	// identities are issued and verified on the default network
+	defaultNetwork := cfg.DefaultNetwork()
 
 	auths := auth.New(auth.AuthServiceConfig{
 		IPFSUrl:         cfg.Auth.IPFSUrl,
-		RPCUrl:          cfg.RpcUrl,
-		ContractAddress: cfg.Auth.ContractAddress,
-		ResolverPrefix:  cfg.Auth.ResolverPrefix,
+		RPCUrl:          defaultNetwork.RpcUrls[0],
+		ContractAddress: defaultNetwork.Did.StateContractAddress,
+		ResolverPrefix:  defaultNetwork.Did.ResolverPrefix(),
 		KeyDir:          cfg.Auth.KeyDir,
 		ReqCache:        authCache,
+		SiweDomain:      siweDomain(cfg.ServerUrl),
+		SiweUri:         cfg.ServerUrl,
+		SiweChainID:     defaultNetwork.ChainID,
 	})
 
 	didRepo, err := drepo.New(ctx, mongoClient, cfg.Did.DbName)
 	handleErr(err)
 
 	dids := did.New(did.DidServiceConfig{
-		RPCUrl:    cfg.RpcUrl,
+		RPCUrl:    defaultNetwork.RpcUrls[0],
 		IssuerUrl: cfg.Did.IssuerUrl,
 		Username:  cfg.Did.Username,
 		Password:  cfg.Did.Password,
@@ -101,31 +128,98 @@ func Run() {
 		Secret: cfg.Ipfs.Secret,
 	})
 
-	jwts := jwt.New(cfg.Jwt.AccessTokenKey, cfg.Jwt.RefreshTokenKey,
-		jwt.WithAudience(cfg.Jwt.Audience), jwt.WithIssuer(cfg.Jwt.Issuer))
+	userRepo, err := urepo.New(ctx, mongoClient, cfg.User.DbName)
+	handleErr(err)
+
+	userOpts := []user.Option{user.WithBlockedHandles(cfg.User.BlockedHandles...)}
+	if cfg.User.HandleCooldownDays > 0 {
+		userOpts = append(userOpts, user.WithHandleCooldown(time.Duration(cfg.User.HandleCooldownDays)*24*time.Hour))
+	}
 
-	notices := notice.New(nrepo.New(mongoClient, cfg.Notice.DbName))
+	users := user.New(userRepo, userOpts...)
+
+	keyRepo, err := jwtrepo.New(ctx, mongoClient, cfg.Jwt.DbName)
+	handleErr(err)
 
-	ethclient, err := web3.NewClient(ctx, cfg.RpcUrl)
+	jwtKeys, err := newKeyRing(ctx, cfg.Jwt, keyRepo)
 	handleErr(err)
 
-	heroticketContract, err := heroticket.NewHeroticket(web3.HexToAddress(cfg.Ticket.ContractAddress), ethclient)
+	if len(jwtKeys.Keys()) == 0 {
+		key, err := jwtKeys.Generate(ctx)
+		handleErr(err)
+
+		logger.Info("Generated the first jwt signing key", "kid", key.ID, "algorithm", key.Algorithm)
+	}
+
+	jwts := jwt.New(jwtCache, jwtKeys, append(jwtOptions(cfg.Jwt), jwt.WithClaims(userClaims(users)))...)
+
+	keyCtx, keyCancel := context.WithCancel(context.Background())
+	defer keyCancel()
+
+	go jwtKeys.Run(keyCtx)
+
+	noticeRepo, err := nrepo.New(ctx, mongoClient, cfg.Notice.DbName)
 	handleErr(err)
 
+	notices := notice.New(noticeRepo)
+
 	pvk, err := web3.ParsePrivateKey(cfg.Ticket.PrivateKey)
 	handleErr(err)
 
-	ticketRepo, err := trepo.New(ctx, mongoClient, cfg.Ticket.DbName)
+	var maxFeePerGas *big.Int
+
+	if cfg.Ticket.MaxFeePerGasGwei > 0 {
+		maxFeePerGas = web3.GweiToWei(cfg.Ticket.MaxFeePerGasGwei)
+	}
+
+	txCtx, txCancel := context.WithCancel(context.Background())
+	defer txCancel()
+
+	services := make([]ticket.Service, 0, len(cfg.Networks))
+
+	for _, n := range cfg.Networks {
+		svc, txManager, err := newTicketService(ctx, cfg, n, mongoClient, pvk, maxFeePerGas)
+		handleErr(err)
+
+		go txManager.Run(txCtx)
+
+		services = append(services, svc)
+
+		logger.Info("Successfully connected to network", "name", n.Name, "chainId", n.ChainID)
+	}
+
+	tickets, err := ticket.NewNetworks(defaultNetwork.ChainID, services...)
 	handleErr(err)
 
-	tickets := ticket.New(ethclient, heroticketContract, pvk, ticketRepo, cfg.Ticket.MoralisApiKey)
+	_ = mongo.NewTx(mongoClient)
 
-	userRepo, err := urepo.New(ctx, mongoClient, cfg.User.DbName)
+	checkinRepo, err := crepo.New(ctx, mongoClient, cfg.Checkin.DbName)
 	handleErr(err)
 
-	users := user.New(userRepo)
+	checkins := checkin.New(checkinRepo)
 
-	_ = mongo.NewTx(mongoClient)
+	nullifierRepo, err := nullrepo.New(ctx, mongoClient, cfg.Nullifier.DbName)
+	handleErr(err)
+
+	nullifiers := nullifier.New(nullifier.NullifierServiceConfig{
+		Repo:   nullifierRepo,
+		Secret: cfg.Nullifier.Secret,
+	})
+
+	staffRepo, err := srepo.New(ctx, mongoClient, cfg.Staff.DbName)
+	handleErr(err)
+
+	staffs := staff.New(staffRepo)
+
+	jobRepo, err := jrepo.New(ctx, mongoClient, cfg.Job.DbName)
+	handleErr(err)
+
+	jobs := job.New(job.JobServiceConfig{
+		Repo:        jobRepo,
+		Notify:      rest.NotifyJob,
+		Workers:     cfg.Job.Workers,
+		MaxAttempts: cfg.Job.MaxAttempts,
+	})
 
 	// find admin user
 	_, err = users.FindAdmin(ctx)
@@ -133,9 +227,9 @@ func Run() {
 		if err == user.ErrUserNotFound {
 			resp, err := dids.CreateIdentity(ctx, did.CreateIdentityRequest{
 				DidMetadata: did.DidMetadata{
-					Blockchain: "polygon",
-					Method:     "polygonid",
-					Network:    "mumbai",
+					Blockchain: defaultNetwork.Did.Blockchain,
+					Method:     defaultNetwork.Did.Method,
+					Network:    defaultNetwork.Did.Network,
 					Type:       did.BJJ,
 				},
 			})
@@ -154,13 +248,31 @@ func Run() {
 		}
 	}
 
+	checkinCtrl := rest.NewCheckinCtrl(checkins, jwts, tickets, users)
 	claimCtrl := rest.NewClaimCtrl(dids, jwts, tickets, users)
-	noticeCtrl := rest.NewNoticeCtrl(notices, users)
+	noticeCtrl := rest.NewNoticeCtrl(jwts, notices, users)
 	profileCtrl := rest.NewProfileCtrl(tickets, users)
-	ticketCtrl := rest.NewTicketCtrl(auths, ipfss, jwts, tickets, users, cfg.ServerUrl)
-	userCtrl := rest.NewUserCtrl(auths, jwts, users, tickets, cfg.ServerUrl)
+	staffCtrl := rest.NewStaffCtrl(jwts, tickets, staffs, users)
+	ticketCtrl := rest.NewTicketCtrl(auths, checkins, ipfss, jobs, jwts, tickets, nullifiers, staffs, users, cfg.ServerUrl)
+	// users register their tba on the default network
+	userCtrl := rest.NewUserCtrl(auths, checkins, dids, ipfss, jobs, jwts, tickets, nullifiers, staffs, users, cfg.ServerUrl)
+	jobCtrl := rest.NewJobCtrl(jobs, jwts)
+	adminCtrl := rest.NewAdminCtrl(jwts, tickets, users)
+	wellKnownCtrl := rest.NewWellKnownCtrl(jwts)
+
+	ticketCtrl.RegisterJobHandlers()
+	userCtrl.RegisterJobHandlers()
+
+	jobCtx, jobCancel := context.WithCancel(context.Background())
+	jobsDone := make(chan struct{})
 
-	srv := app.New(app.DefaultConfig(), claimCtrl, noticeCtrl, profileCtrl, ticketCtrl, userCtrl)
+	go func() {
+		defer close(jobsDone)
+		jobs.Run(jobCtx)
+	}()
+
+	srv := app.New(app.DefaultConfig(), adminCtrl, checkinCtrl, claimCtrl, noticeCtrl, profileCtrl, staffCtrl, ticketCtrl, userCtrl, jobCtrl)
+	srv.MountRoot(wellKnownCtrl)
 
 	logger.Info("Starting server")
 
@@ -177,6 +289,14 @@ func Run() {
 		err := srv.Shutdown(ctx)
 		handleErr(err)
 
+		// let workers finish their current job before the tx manager stops
+		jobCancel()
+		<-jobsDone
+
+		keyCancel()
+
+		txCancel()
+
 		logger.Info("Successfully shutdown server")
 
 		err = mongoClient.Disconnect(ctx)
@@ -190,8 +310,85 @@ func Run() {
 	<-stop
 }
 
+// newTicketService connects to network n and returns its ticket service along with the
+// tx manager sending its transactions, which the caller must run.
+func newTicketService(ctx context.Context, cfg *config.ServerConfig, n config.NetworkConfig, mongoClient *mongodriver.Client, pvk *ecdsa.PrivateKey, maxFeePerGas *big.Int) (ticket.Service, *web3.TxManager, error) {
+	ethclient, err := web3.NewNetworkClient(ctx, n.ChainID, n.RpcUrls...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contractAddress := web3.HexToAddress(n.ContractAddress)
+
+	heroticketContract, err := heroticket.NewHeroticket(contractAddress, ethclient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ticketRepo, err := trepo.New(ctx, mongoClient, n.DbName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txRepo, err := txrepo.New(ctx, mongoClient, n.DbName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txManager, err := web3.NewTxManager(ctx, web3.TxManagerConfig{
+		Backend:      ethclient,
+		PrivateKey:   pvk,
+		ChainID:      big.NewInt(n.ChainID),
+		Repo:         txRepo,
+		MaxFeePerGas: maxFeePerGas,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ownedNFTs ticket.OwnedNFTProvider
+
+	switch cfg.Ticket.OwnedNftProvider {
+	case ticket.OwnedNFTProviderMoralis:
+		ownedNFTs = ticket.NewMoralisProvider(ticket.MoralisProviderConfig{
+			ApiKey:  cfg.Ticket.MoralisApiKey,
+			Chain:   n.IndexerChain,
+			ChainID: n.ChainID,
+		})
+	case "", ticket.OwnedNFTProviderLocal:
+		ownedNFTs = ticket.NewLocalProvider(ticketRepo, heroticketContract)
+	default:
+		return nil, nil, fmt.Errorf("unknown owned nft provider: %s", cfg.Ticket.OwnedNftProvider)
+	}
+
+	svc := ticket.New(ticket.TicketServiceConfig{
+		ChainID:         n.ChainID,
+		Client:          ethclient,
+		Hero:            heroticketContract,
+		ContractAddress: contractAddress,
+		TxManager:       txManager,
+		Repo:            ticketRepo,
+		OwnedNFTs:       ownedNFTs,
+
+		GasLimitMultiplier: cfg.Ticket.GasLimitMultiplier,
+		MaxFeePerGas:       maxFeePerGas,
+	})
+
+	return svc, txManager, nil
+}
+
 func handleErr(err error) {
 	if err != nil {
 		logger.Panic(err.Error())
 	}
 }
+
+// siweDomain returns the host sign-in messages name, the server url itself when it does not parse.
+func siweDomain(serverUrl string) string {
+	u, err := url.Parse(serverUrl)
+	if err != nil || u.Host == "" {
+		return serverUrl
+	}
+
+	return u.Host
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/heroticket/internal/app/shutdown"
+	"github.com/heroticket/internal/config"
+	"github.com/heroticket/internal/db/mongo"
+	"github.com/heroticket/internal/logger"
+	trepo "github.com/heroticket/internal/service/ticket/repository/mongo"
+	urepo "github.com/heroticket/internal/service/user/repository/mongo"
+	"github.com/heroticket/internal/subscriber"
+	srepo "github.com/heroticket/internal/subscriber/repository/mongo"
+	"github.com/heroticket/internal/web3"
+	"github.com/heroticket/pkg/contracts/heroticket"
+)
+
+func Subscribe() {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Info("recovered from panic", "panic", r)
+		}
+	}()
+
+	err := logger.New(false, "service", "heroticket-subscriber")
+	handleErr(err)
+
+	var configFile string
+
+	if os.Getenv("GO_ENV") != "production" {
+		configFile = "config.dev.json"
+	}
+
+	cfg, err := config.NewSubscriberConfig(configFile)
+	if err != nil {
+		panic(err)
+	}
+
+	logger.Info("Successfully loaded config")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mongoClient, err := mongo.New(ctx, cfg.MongoUrl)
+	handleErr(err)
+
+	logger.Info("Successfully connected to MongoDB")
+
+	ethclient, err := web3.NewClient(ctx, cfg.RpcUrl)
+	handleErr(err)
+
+	logger.Info("Successfully connected to RPC node")
+
+	chainID := cfg.ChainID
+
+	if chainID == 0 {
+		id, err := ethclient.ChainID(ctx)
+		handleErr(err)
+
+		chainID = id.Int64()
+	}
+
+	contractAddress := web3.HexToAddress(cfg.ContractAddress)
+
+	heroticketContract, err := heroticket.NewHeroticket(contractAddress, ethclient)
+	handleErr(err)
+
+	ticketRepo, err := trepo.New(ctx, mongoClient, cfg.TicketDbName)
+	handleErr(err)
+
+	userRepo, err := urepo.New(ctx, mongoClient, cfg.UserDbName)
+	handleErr(err)
+
+	sub := subscriber.New(subscriber.SubscriberConfig{
+		ChainID:         chainID,
+		Client:          ethclient,
+		Hero:            heroticketContract,
+		ContractAddress: contractAddress,
+		Repo:            srepo.New(mongoClient, cfg.DbName),
+		TicketRepo:      ticketRepo,
+		UserRepo:        userRepo,
+		StartBlock:      cfg.StartBlock,
+		BatchSize:       cfg.BatchSize,
+		RewindBlocks:    cfg.RewindBlocks,
+
+		ReconcileInterval: time.Duration(cfg.ReconcileInterval) * time.Second,
+	})
+
+	runCtx, stopRun := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	logger.Info("Starting subscriber")
+
+	go func() {
+		defer close(done)
+
+		if err := sub.Run(runCtx); err != nil && err != context.Canceled {
+			logger.Error("subscriber stopped", "error", err)
+		}
+	}()
+
+	stop := shutdown.GracefulShutdown(func() {
+		stopRun()
+		<-done
+
+		logger.Info("Successfully stopped subscriber")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		ethclient.Close()
+
+		err := mongoClient.Disconnect(ctx)
+		handleErr(err)
+
+		logger.Info("Successfully disconnected from MongoDB")
+
+		logger.Sync()
+	}, syscall.SIGINT, syscall.SIGTERM)
+
+	<-stop
+}
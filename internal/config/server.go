@@ -1,13 +1,20 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
 
 type AuthServiceConfig struct {
-	IPFSUrl         string `mapstructure:"ipfsUrl"`
-	ContractAddress string `mapstructure:"contractAddress"`
-	ResolverPrefix  string `mapstructure:"resolverPrefix"`
-	KeyDir          string `mapstructure:"keyDir"`
-	RedisUrl        string `mapstructure:"redisUrl"`
+	IPFSUrl  string `mapstructure:"ipfsUrl"`
+	KeyDir   string `mapstructure:"keyDir"`
+	RedisUrl string `mapstructure:"redisUrl"`
+}
+
+type CheckinServiceConfig struct {
+	DbName string `mapstructure:"dbName"`
 }
 
 type DidServiceConfig struct {
@@ -23,41 +30,167 @@ type IpfsServiceConfig struct {
 	Secret string `mapstructure:"secret"`
 }
 
+type JobServiceConfig struct {
+	DbName      string `mapstructure:"dbName"`
+	Workers     int    `mapstructure:"workers"`
+	MaxAttempts int    `mapstructure:"maxAttempts"`
+}
+
 type JwtServiceConfig struct {
-	Issuer             string `mapstructure:"issuer"`
-	Audience           string `mapstructure:"audience"`
-	AccessTokenKey     string `mapstructure:"accessTokenKey"`
-	RefreshTokenKey    string `mapstructure:"refreshTokenKey"`
-	AccessTokenExpiry  int64  `mapstructure:"accessTokenExpiry"`
-	RefreshTokenExpiry int64  `mapstructure:"refreshTokenExpiry"`
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+	// AccessTokenExpiry and RefreshTokenExpiry are in seconds, the defaults when zero
+	AccessTokenExpiry  int64 `mapstructure:"accessTokenExpiry"`
+	RefreshTokenExpiry int64 `mapstructure:"refreshTokenExpiry"`
+	// RedisUrl is where sessions and revoked tokens are kept
+	RedisUrl string `mapstructure:"redisUrl"`
+	// DbName is the database of the signing keys
+	DbName string `mapstructure:"dbName"`
+	// Algorithm is "ES256" (default) or "EdDSA", it applies to keys created from now on
+	Algorithm string `mapstructure:"algorithm"`
+	// KeyRotationDays is how long a key signs before it is rotated, 30 when zero
+	KeyRotationDays int64 `mapstructure:"keyRotationDays"`
+	// KeyEncryptionKey is the base64 of 32 random bytes (openssl rand -base64 32) the signing keys are
+	// encrypted with in the database. Keys encrypted with a previous value no longer load, so a change
+	// takes a new key ring.
+	KeyEncryptionKey string `mapstructure:"keyEncryptionKey"`
 }
 
 type NoticeServiceConfig struct {
 	DbName string `mapstructure:"dbName"`
 }
 
+type NullifierServiceConfig struct {
+	DbName string `mapstructure:"dbName"`
+	// Secret keys the per-collection nullifier hash, changing it forgets every used nullifier
+	Secret string `mapstructure:"secret"`
+}
+
+type StaffServiceConfig struct {
+	DbName string `mapstructure:"dbName"`
+}
+
 type TicketServiceConfig struct {
-	DbName          string `mapstructure:"dbName"`
-	ContractAddress string `mapstructure:"contractAddress"`
-	PrivateKey      string `mapstructure:"privateKey"`
-	MoralisApiKey   string `mapstructure:"moralisApiKey"`
+	PrivateKey    string `mapstructure:"privateKey"`
+	MoralisApiKey string `mapstructure:"moralisApiKey"`
+	// OwnedNftProvider is "local" (default) or "moralis"
+	OwnedNftProvider string `mapstructure:"ownedNftProvider"`
+
+	GasLimitMultiplier float64 `mapstructure:"gasLimitMultiplier"`
+	MaxFeePerGasGwei   float64 `mapstructure:"maxFeePerGasGwei"`
+}
+
+// DidNetworkConfig is the Polygon ID network identities are issued and verified on.
+type DidNetworkConfig struct {
+	Blockchain           string `mapstructure:"blockchain"`
+	Method               string `mapstructure:"method"`
+	Network              string `mapstructure:"network"`
+	StateContractAddress string `mapstructure:"stateContractAddress"`
+}
+
+// ResolverPrefix returns the prefix of the state resolver of the network, e.g. "polygon:amoy".
+func (c DidNetworkConfig) ResolverPrefix() string {
+	return c.Blockchain + ":" + c.Network
+}
+
+type NetworkConfig struct {
+	Name    string `mapstructure:"name"`
+	ChainID int64  `mapstructure:"chainId"`
+	// RpcUrls are tried in order until one connects
+	RpcUrls         []string `mapstructure:"rpcUrls"`
+	ContractAddress string   `mapstructure:"contractAddress"`
+	// DbName is the database of the network's tickets, tbas and pending transactions
+	DbName string           `mapstructure:"dbName"`
+	Did    DidNetworkConfig `mapstructure:"did"`
+	// IndexerChain is the chain name of the network in the owned nft indexer, the hex chain id when empty
+	IndexerChain string `mapstructure:"indexerChain"`
 }
 
 type UserServiceConfig struct {
 	DbName string `mapstructure:"dbName"`
+	// HandleCooldownDays is how long a user waits between handle changes, 30 when zero
+	HandleCooldownDays int64 `mapstructure:"handleCooldownDays"`
+	// BlockedHandles are words no handle may contain, on top of the built-in reserved names
+	BlockedHandles []string `mapstructure:"blockedHandles"`
 }
 
 type ServerConfig struct {
-	Auth      AuthServiceConfig   `mapstructure:"auth"`
-	Did       DidServiceConfig    `mapstructure:"did"`
-	Ipfs      IpfsServiceConfig   `mapstructure:"ipfs"`
-	Jwt       JwtServiceConfig    `mapstructure:"jwt"`
-	Notice    NoticeServiceConfig `mapstructure:"notice"`
-	Ticket    TicketServiceConfig `mapstructure:"ticket"`
-	User      UserServiceConfig   `mapstructure:"user"`
-	MongoUrl  string              `mapstructure:"mongoUrl"`
-	RpcUrl    string              `mapstructure:"rpcUrl"`
-	ServerUrl string              `mapstructure:"serverUrl"`
+	Auth      AuthServiceConfig      `mapstructure:"auth"`
+	Checkin   CheckinServiceConfig   `mapstructure:"checkin"`
+	Did       DidServiceConfig       `mapstructure:"did"`
+	Ipfs      IpfsServiceConfig      `mapstructure:"ipfs"`
+	Job       JobServiceConfig       `mapstructure:"job"`
+	Jwt       JwtServiceConfig       `mapstructure:"jwt"`
+	Notice    NoticeServiceConfig    `mapstructure:"notice"`
+	Nullifier NullifierServiceConfig `mapstructure:"nullifier"`
+	Staff     StaffServiceConfig     `mapstructure:"staff"`
+	Ticket    TicketServiceConfig    `mapstructure:"ticket"`
+	User      UserServiceConfig      `mapstructure:"user"`
+	Networks  []NetworkConfig        `mapstructure:"networks"`
+	// DefaultChainID is the network used when a request does not name one, the first network when 0
+	DefaultChainID int64  `mapstructure:"defaultChainId"`
+	MongoUrl       string `mapstructure:"mongoUrl"`
+	ServerUrl      string `mapstructure:"serverUrl"`
+}
+
+// DefaultNetwork returns the network identities and users are registered on.
+func (c *ServerConfig) DefaultNetwork() NetworkConfig {
+	for _, n := range c.Networks {
+		if n.ChainID == c.DefaultChainID {
+			return n
+		}
+	}
+
+	return c.Networks[0]
+}
+
+func (c *ServerConfig) validate() error {
+	if len(c.Networks) == 0 {
+		return errors.New("at least one network is required")
+	}
+
+	seen := make(map[int64]bool, len(c.Networks))
+	dbNames := make(map[string]bool, len(c.Networks))
+
+	for _, n := range c.Networks {
+		if n.ChainID <= 0 {
+			return fmt.Errorf("network %q: invalid chain id", n.Name)
+		}
+
+		if seen[n.ChainID] {
+			return fmt.Errorf("network %q: duplicate chain id %d", n.Name, n.ChainID)
+		}
+
+		if len(n.RpcUrls) == 0 {
+			return fmt.Errorf("network %q: rpc url is required", n.Name)
+		}
+
+		// tickets and pending transactions are not keyed by chain, so networks can't share a database
+		if n.DbName == "" {
+			return fmt.Errorf("network %q: db name is required", n.Name)
+		}
+
+		if dbNames[n.DbName] {
+			return fmt.Errorf("network %q: db name %q is used by another network", n.Name, n.DbName)
+		}
+
+		seen[n.ChainID] = true
+		dbNames[n.DbName] = true
+	}
+
+	if c.Nullifier.Secret == "" {
+		return errors.New("nullifier secret is required")
+	}
+
+	if c.Jwt.KeyEncryptionKey == "" {
+		return errors.New("jwt key encryption key is required")
+	}
+
+	if c.DefaultChainID != 0 && !seen[c.DefaultChainID] {
+		return fmt.Errorf("default chain id %d is not a configured network", c.DefaultChainID)
+	}
+
+	return nil
 }
 
 func NewServerConfig(filename string) (*ServerConfig, error) {
@@ -77,5 +210,9 @@ func NewServerConfig(filename string) (*ServerConfig, error) {
 		return nil, err
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
@@ -3,9 +3,21 @@ package config
 import "github.com/spf13/viper"
 
 type SubscriberConfig struct {
+	// ChainID tags indexed collections, read from the node when 0
+	ChainID         int64  `mapstructure:"chainId"`
 	RpcUrl          string `mapstructure:"rpcUrl"`
 	ContractAddress string `mapstructure:"contractAddress"`
 	MongoUrl        string `mapstructure:"mongoUrl"`
+	DbName          string `mapstructure:"dbName"`
+	TicketDbName    string `mapstructure:"ticketDbName"`
+	UserDbName      string `mapstructure:"userDbName"`
+	StartBlock      uint64 `mapstructure:"startBlock"`
+	BatchSize       uint64 `mapstructure:"batchSize"`
+	// RewindBlocks is how many blocks before the checkpoint a restart backfills from
+	RewindBlocks uint64 `mapstructure:"rewindBlocks"`
+
+	// ReconcileInterval is the interval between reconcile jobs in seconds
+	ReconcileInterval int64 `mapstructure:"reconcileInterval"`
 }
 
 func NewSubscriberConfig(filename string) (*SubscriberConfig, error) {
@@ -0,0 +1,64 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/heroticket/internal/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type counter struct {
+	Name  string `bson:"_id"`
+	Value int64  `bson:"seq"`
+}
+
+type mongoSequence struct {
+	client *mongo.Client
+	dbname string
+}
+
+// NewSequence returns sequences stored in the counters collection of the database, one document per name.
+func NewSequence(client *mongo.Client, dbname string) db.Sequence {
+	return &mongoSequence{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (s *mongoSequence) Next(ctx context.Context, name string) (int64, error) {
+	filter := bson.M{"_id": name}
+	update := bson.M{"$inc": bson.M{"seq": int64(1)}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var c counter
+
+	err := s.collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&c)
+	if mongo.IsDuplicateKeyError(err) {
+		// two first uses raced to insert the counter, the loser increments the inserted one
+		err = s.collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&c)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Value, nil
+}
+
+func (s *mongoSequence) AtLeast(ctx context.Context, name string, value int64) error {
+	filter := bson.M{"_id": name}
+	update := bson.M{"$max": bson.M{"seq": value}}
+	opts := options.Update().SetUpsert(true)
+
+	_, err := s.collection().UpdateOne(ctx, filter, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		_, err = s.collection().UpdateOne(ctx, filter, update, opts)
+	}
+
+	return err
+}
+
+func (s *mongoSequence) collection() *mongo.Collection {
+	return s.client.Database(s.dbname).Collection("counters")
+}
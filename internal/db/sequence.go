@@ -0,0 +1,11 @@
+package db
+
+import "context"
+
+// Sequence generates increasing numeric ids, such as notice or order numbers, without races between instances.
+type Sequence interface {
+	// Next returns the next value of the named sequence, starting at 1
+	Next(ctx context.Context, name string) (int64, error)
+	// AtLeast moves the named sequence forward to value when it is behind, to continue ids assigned before it existed
+	AtLeast(ctx context.Context, name string, value int64) error
+}
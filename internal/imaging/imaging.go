@@ -0,0 +1,150 @@
+// Package imaging validates uploaded images and resizes them to standard dimensions.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrTooLarge        = errors.New("image is too large")
+	ErrUnsupportedType = errors.New("image must be a jpeg, png or gif")
+	ErrInvalidImage    = errors.New("invalid image")
+)
+
+// MaxPixels bounds the decoded size of an upload, so a small file cannot expand to gigabytes in memory
+const MaxPixels = 40_000_000
+
+// Spec is the size an upload is cropped and resized to.
+type Spec struct {
+	Width    int
+	Height   int
+	MaxBytes int64
+}
+
+var (
+	Avatar = Spec{Width: 400, Height: 400, MaxBytes: 5 << 20}
+	Banner = Spec{Width: 1500, Height: 500, MaxBytes: 10 << 20}
+)
+
+// Image is a processed upload, encoded as jpeg or png.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// Process reads an upload of up to spec.MaxBytes, checks its sniffed content type, decodes it and
+// returns it cropped to the aspect ratio of the spec from the center and resized to its dimensions.
+// Jpegs stay jpegs, pngs and gifs become pngs to keep their transparency.
+func Process(r io.Reader, spec Spec) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, spec.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > spec.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	dst := Resize(src, spec.Width, spec.Height)
+
+	var buf bytes.Buffer
+
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		return &Image{Data: buf.Bytes(), ContentType: "image/jpeg", Ext: ".jpg"}, nil
+	}
+
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+
+	return &Image{Data: buf.Bytes(), ContentType: "image/png", Ext: ".png"}, nil
+}
+
+// Resize crops src to the aspect ratio of width by height from the center and scales it to that
+// size, averaging the source pixels covered by each destination pixel.
+func Resize(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+
+	// the largest centered crop with the target aspect ratio
+	cw, ch := bounds.Dx(), bounds.Dy()
+	if cw*height > ch*width {
+		cw = ch * width / height
+	} else {
+		ch = cw * height / width
+	}
+	cw, ch = max(cw, 1), max(ch, 1)
+
+	crop := image.Rect(0, 0, cw, ch).Add(bounds.Min).Add(image.Pt((bounds.Dx()-cw)/2, (bounds.Dy()-ch)/2))
+
+	// premultiplied pixels average without dark fringes around transparency
+	rgba := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	draw.Draw(rgba, rgba.Bounds(), src, crop.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * ch / height
+		y1 := max((y+1)*ch/height, y0+1)
+
+		for x := 0; x < width; x++ {
+			x0 := x * cw / width
+			x1 := max((x+1)*cw/width, x0+1)
+
+			var r, g, b, a, n uint64
+
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessCropsAndResizes(t *testing.T) {
+	// a wide image, red on the left third and blue elsewhere, so a centered square crop is all blue
+	src := image.NewRGBA(image.Rect(0, 0, 900, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 900; x++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x < 300 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	img, err := Process(bytes.NewReader(encodePNG(t, src)), Avatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.ContentType != "image/png" || img.Ext != ".png" {
+		t.Fatalf("content type = %s %s, want png", img.ContentType, img.Ext)
+	}
+
+	out, err := png.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := out.Bounds().Size(); got != image.Pt(Avatar.Width, Avatar.Height) {
+		t.Fatalf("size = %v, want %dx%d", got, Avatar.Width, Avatar.Height)
+	}
+
+	if r, _, b, _ := out.At(0, 0).RGBA(); r != 0 || b != 0xffff {
+		t.Fatalf("corner is not blue, the crop is not centered")
+	}
+}
+
+func TestProcessKeepsJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3000, 1000)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Process(&buf, Banner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if format != "jpeg" || cfg.Width != Banner.Width || cfg.Height != Banner.Height {
+		t.Fatalf("got %s %dx%d, want jpeg %dx%d", format, cfg.Width, cfg.Height, Banner.Width, Banner.Height)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	small := Spec{Width: 10, Height: 10, MaxBytes: 64}
+
+	if _, err := Process(strings.NewReader("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), small); err != ErrUnsupportedType {
+		t.Fatalf("svg: err = %v, want %v", err, ErrUnsupportedType)
+	}
+
+	if _, err := Process(bytes.NewReader(make([]byte, 65)), small); err != ErrTooLarge {
+		t.Fatalf("oversized: err = %v, want %v", err, ErrTooLarge)
+	}
+
+	// a png signature followed by garbage sniffs as png but does not decode
+	if _, err := Process(bytes.NewReader([]byte("\x89PNG\r\n\x1a\nnot really")), small); err != ErrInvalidImage {
+		t.Fatalf("corrupt: err = %v, want %v", err, ErrInvalidImage)
+	}
+}
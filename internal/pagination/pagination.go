@@ -0,0 +1,72 @@
+package pagination
+
+import "math"
+
+const (
+	DefaultPage  int64 = 1
+	DefaultLimit int64 = 10
+	MaxLimit     int64 = 20
+)
+
+type Pagination struct {
+	Total       int64 `json:"total"`
+	Pages       int64 `json:"pages"`
+	CurrentPage int64 `json:"currentPage"`
+	Limit       int64 `json:"limit"`
+	Start       int64 `json:"start"`
+	End         int64 `json:"end"`
+	HasNext     bool  `json:"hasNext"`
+	HasPrev     bool  `json:"hasPrev"`
+}
+
+// New returns the pagination for total items, clamping page and limit to valid ranges.
+func New(total, page, limit int64) *Pagination {
+	if page < 1 {
+		page = 1
+	}
+
+	if limit < 1 {
+		limit = 1
+	}
+
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	pages := int64(math.Ceil(float64(total) / float64(limit)))
+
+	if page > pages {
+		page = pages
+	}
+
+	// 2 pages before and after the current page
+	start := max(1, page-2)
+	end := min(pages, page+2)
+
+	if end < start {
+		end = start
+	}
+
+	hasNext := page < pages
+	hasPrev := page > 1
+
+	return &Pagination{
+		Total:       total,
+		Pages:       pages,
+		CurrentPage: page,
+		Limit:       limit,
+		Start:       start,
+		End:         end,
+		HasNext:     hasNext,
+		HasPrev:     hasPrev,
+	}
+}
+
+// Skip returns the number of items to skip to reach the current page.
+func (p *Pagination) Skip() int64 {
+	if p.CurrentPage < 1 {
+		return 0
+	}
+
+	return (p.CurrentPage - 1) * p.Limit
+}
@@ -16,6 +16,9 @@ import (
 type Service interface {
 	AuthorizationRequest(ctx context.Context, params AuthorizationRequestParams) (protocol.AuthorizationRequestMessage, error)
 	AuthorizationCallback(ctx context.Context, id, token string, deleteOnSuccess bool) (*protocol.AuthorizationResponseMessage, error)
+	FindAuthorizationRequest(ctx context.Context, id string) (protocol.AuthorizationRequestMessage, error)
+	SiweChallenge(ctx context.Context, params SiweChallengeParams) (*SiweChallenge, error)
+	SiweVerify(ctx context.Context, params SiweVerifyParams) error
 }
 
 type AuthServiceConfig struct {
@@ -25,6 +28,11 @@ type AuthServiceConfig struct {
 	ResolverPrefix  string
 	KeyDir          string
 	ReqCache        cache.Cache
+
+	// SiweDomain and SiweUri name the server in sign-in messages, SiweChainID the chain wallets sign for
+	SiweDomain  string
+	SiweUri     string
+	SiweChainID int64
 }
 
 type AuthService struct {
@@ -34,6 +42,10 @@ type AuthService struct {
 	resolverPrefix  string
 	keyDir          string
 	reqCache        cache.Cache
+
+	siweDomain  string
+	siweUri     string
+	siweChainID int64
 }
 
 func New(config AuthServiceConfig) Service {
@@ -44,6 +56,9 @@ func New(config AuthServiceConfig) Service {
 		resolverPrefix:  config.ResolverPrefix,
 		keyDir:          config.KeyDir,
 		reqCache:        config.ReqCache,
+		siweDomain:      config.SiweDomain,
+		siweUri:         config.SiweUri,
+		siweChainID:     config.SiweChainID,
 	}
 }
 
@@ -124,3 +139,13 @@ func (s *AuthService) AuthorizationCallback(ctx context.Context, id, token strin
 
 	return response, nil
 }
+
+// FindAuthorizationRequest returns the pending authorization request of a session, whose From is the
+// DID the server issued it for.
+func (s *AuthService) FindAuthorizationRequest(ctx context.Context, id string) (protocol.AuthorizationRequestMessage, error) {
+	var request protocol.AuthorizationRequestMessage
+
+	err := s.reqCache.Get(ctx, id, &request)
+
+	return request, err
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/heroticket/internal/cache"
+)
+
+var (
+	ErrSiweChallengeNotFound = errors.New("sign-in challenge not found or expired")
+	ErrSiweInvalidSignature  = errors.New("invalid sign-in signature")
+	ErrSiweSignerMismatch    = errors.New("sign-in message was not signed by the account")
+	ErrSiweSubjectMismatch   = errors.New("sign-in challenge was issued for another user")
+)
+
+var DefaultSiweTimeout = 5 * time.Minute
+
+const siweStatement = "Link this wallet to your Heroticket identity %s."
+
+type SiweChallengeParams struct {
+	// Subject is the DID the wallet is linked to
+	Subject string
+	Address common.Address
+}
+
+// SiweChallenge is an EIP-4361 message for the wallet to sign with personal_sign.
+type SiweChallenge struct {
+	Nonce     string `json:"nonce"`
+	Message   string `json:"message"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+type SiweVerifyParams struct {
+	Subject   string
+	Address   common.Address
+	Nonce     string
+	Signature string
+}
+
+type siweSession struct {
+	Subject string `json:"subject"`
+	Address string `json:"address"`
+	Message string `json:"message"`
+}
+
+// SiweChallenge creates a single use sign-in message proving control of params.Address.
+func (s *AuthService) SiweChallenge(ctx context.Context, params SiweChallengeParams) (*SiweChallenge, error) {
+	nonce, err := siweNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	issuedAt := time.Now().UTC()
+	expiresAt := issuedAt.Add(DefaultSiweTimeout)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s wants you to sign in with your Ethereum account:\n", s.siweDomain)
+	fmt.Fprintf(&b, "%s\n\n", params.Address.Hex())
+	fmt.Fprintf(&b, siweStatement+"\n\n", params.Subject)
+	fmt.Fprintf(&b, "URI: %s\n", s.siweUri)
+	fmt.Fprintf(&b, "Version: 1\n")
+	fmt.Fprintf(&b, "Chain ID: %d\n", s.siweChainID)
+	fmt.Fprintf(&b, "Nonce: %s\n", nonce)
+	fmt.Fprintf(&b, "Issued At: %s\n", issuedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Expiration Time: %s", expiresAt.Format(time.RFC3339))
+
+	session := siweSession{
+		Subject: params.Subject,
+		Address: params.Address.Hex(),
+		Message: b.String(),
+	}
+
+	if err := s.reqCache.Set(ctx, siweKey(nonce), session, DefaultSiweTimeout); err != nil {
+		return nil, err
+	}
+
+	return &SiweChallenge{
+		Nonce:     nonce,
+		Message:   session.Message,
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+// SiweVerify checks that the challenge of params.Nonce was signed by params.Address for params.Subject.
+// A challenge is consumed by its first verification, successful or not.
+func (s *AuthService) SiweVerify(ctx context.Context, params SiweVerifyParams) error {
+	var session siweSession
+
+	key := siweKey(params.Nonce)
+
+	if err := s.reqCache.Get(ctx, key, &session); err != nil {
+		if err == cache.ErrCacheMiss {
+			return ErrSiweChallengeNotFound
+		}
+		return err
+	}
+
+	_ = s.reqCache.Delete(ctx, key)
+
+	if session.Subject != params.Subject {
+		return ErrSiweSubjectMismatch
+	}
+
+	if !strings.EqualFold(session.Address, params.Address.Hex()) {
+		return ErrSiweSignerMismatch
+	}
+
+	signer, err := RecoverPersonalSigner(session.Message, params.Signature)
+	if err != nil {
+		return err
+	}
+
+	if signer != params.Address {
+		return ErrSiweSignerMismatch
+	}
+
+	return nil
+}
+
+// RecoverPersonalSigner returns the address that signed message with personal_sign (EIP-191).
+func RecoverPersonalSigner(message, signature string) (common.Address, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return common.Address{}, ErrSiweInvalidSignature
+	}
+
+	// wallets return v as 27 or 28, crypto expects the recovery id
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return common.Address{}, ErrSiweInvalidSignature
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+func siweNonce() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func siweKey(nonce string) string {
+	return "siwe:" + nonce
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/heroticket/internal/cache"
+)
+
+type memoryCache struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func (c *memoryCache) Exists(ctx context.Context, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.items[key]
+	return ok
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value interface{}, ttls ...time.Duration) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = b
+	return nil
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string, value interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.items[key]
+	if !ok {
+		return cache.ErrCacheMiss
+	}
+
+	return json.Unmarshal(b, value)
+}
+
+func (c *memoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+	return nil
+}
+
+func newSiweService() *AuthService {
+	return New(AuthServiceConfig{
+		ReqCache:    &memoryCache{items: make(map[string][]byte)},
+		SiweDomain:  "heroticket.xyz",
+		SiweUri:     "https://heroticket.xyz",
+		SiweChainID: 80002,
+	}).(*AuthService)
+}
+
+// personalSign signs like a wallet's personal_sign, with v as 27 or 28.
+func personalSign(t *testing.T, message string) (string, string) {
+	t.Helper()
+
+	pvk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), pvk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig[crypto.RecoveryIDOffset] += 27
+
+	return crypto.PubkeyToAddress(pvk.PublicKey).Hex(), hexutil.Encode(sig)
+}
+
+func TestRecoverPersonalSigner(t *testing.T) {
+	address, sig := personalSign(t, "hello")
+
+	signer, err := RecoverPersonalSigner("hello", sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signer.Hex() != address {
+		t.Fatalf("signer = %s, want %s", signer.Hex(), address)
+	}
+
+	if _, err := RecoverPersonalSigner("hello", "0x1234"); err != ErrSiweInvalidSignature {
+		t.Fatalf("short signature: err = %v, want %v", err, ErrSiweInvalidSignature)
+	}
+}
+
+func TestSiweVerify(t *testing.T) {
+	ctx := context.Background()
+	svc := newSiweService()
+
+	pvk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := crypto.PubkeyToAddress(pvk.PublicKey)
+
+	sign := func(message string) string {
+		sig, err := crypto.Sign(accounts.TextHash([]byte(message)), pvk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig[crypto.RecoveryIDOffset] += 27
+
+		return hexutil.Encode(sig)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		challenge, err := svc.SiweChallenge(ctx, SiweChallengeParams{Subject: "did:a", Address: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(challenge.Message, "heroticket.xyz wants you to sign in with your Ethereum account:\n"+address.Hex()) {
+			t.Fatalf("unexpected message:\n%s", challenge.Message)
+		}
+
+		params := SiweVerifyParams{Subject: "did:a", Address: address, Nonce: challenge.Nonce, Signature: sign(challenge.Message)}
+
+		if err := svc.SiweVerify(ctx, params); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := svc.SiweVerify(ctx, params); err != ErrSiweChallengeNotFound {
+			t.Fatalf("replay: err = %v, want %v", err, ErrSiweChallengeNotFound)
+		}
+	})
+
+	t.Run("other signer", func(t *testing.T) {
+		challenge, err := svc.SiweChallenge(ctx, SiweChallengeParams{Subject: "did:a", Address: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, sig := personalSign(t, challenge.Message)
+
+		err = svc.SiweVerify(ctx, SiweVerifyParams{Subject: "did:a", Address: address, Nonce: challenge.Nonce, Signature: sig})
+		if err != ErrSiweSignerMismatch {
+			t.Fatalf("err = %v, want %v", err, ErrSiweSignerMismatch)
+		}
+	})
+
+	t.Run("other subject", func(t *testing.T) {
+		challenge, err := svc.SiweChallenge(ctx, SiweChallengeParams{Subject: "did:a", Address: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = svc.SiweVerify(ctx, SiweVerifyParams{Subject: "did:b", Address: address, Nonce: challenge.Nonce, Signature: sign(challenge.Message)})
+		if err != ErrSiweSubjectMismatch {
+			t.Fatalf("err = %v, want %v", err, ErrSiweSubjectMismatch)
+		}
+	})
+}
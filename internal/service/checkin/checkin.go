@@ -0,0 +1,164 @@
+package checkin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/heroticket/internal/pagination"
+)
+
+var (
+	ErrCheckinNotFound   = errors.New("check-in not found")
+	ErrPolicyNotFound    = errors.New("re-entry policy not found")
+	ErrEntryLimitReached = errors.New("ticket has no entries left")
+	ErrCheckinChanged    = errors.New("check-in changed concurrently")
+	ErrNothingToUndo     = errors.New("check-in has no entries to undo")
+	ErrInvalidPolicy     = errors.New("invalid re-entry policy")
+)
+
+const (
+	// PolicySingle admits a ticket once
+	PolicySingle = "single"
+	// PolicyMulti admits a ticket up to MaxEntries times in total
+	PolicyMulti = "multi"
+	// PolicyDaily admits a ticket up to MaxEntries times per calendar day in Timezone
+	PolicyDaily = "daily"
+)
+
+// Policy is the re-entry policy of a ticket collection.
+type Policy struct {
+	ID              string `json:"-" bson:"_id"`
+	ChainID         int64  `json:"chainId" bson:"chainId"`
+	ContractAddress string `json:"contractAddress" bson:"contractAddress"`
+	Type            string `json:"type" bson:"type"`
+	MaxEntries      int    `json:"maxEntries" bson:"maxEntries"`
+	Timezone        string `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	UpdatedAt       int64  `json:"updatedAt" bson:"updatedAt"`
+}
+
+// DefaultPolicy is the policy of collections whose issuer did not set one.
+func DefaultPolicy(chainID int64, contractAddress string) *Policy {
+	return &Policy{
+		ID:              Key(chainID, contractAddress),
+		ChainID:         chainID,
+		ContractAddress: contractAddress,
+		Type:            PolicySingle,
+		MaxEntries:      1,
+	}
+}
+
+func (p *Policy) Validate() error {
+	switch p.Type {
+	case PolicySingle:
+		if p.MaxEntries > 1 {
+			return fmt.Errorf("%w: single admits one entry", ErrInvalidPolicy)
+		}
+	case PolicyMulti, PolicyDaily:
+		if p.MaxEntries < 1 {
+			return fmt.Errorf("%w: max entries must be at least 1", ErrInvalidPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPolicy, p.Type)
+	}
+
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPolicy, p.Timezone)
+	}
+
+	return nil
+}
+
+// limit returns the entries allowed in total, or per day when perDay is set.
+func (p *Policy) limit() (max int, perDay bool) {
+	switch p.Type {
+	case PolicyMulti:
+		return p.MaxEntries, false
+	case PolicyDaily:
+		return p.MaxEntries, true
+	default:
+		return 1, false
+	}
+}
+
+// Day returns the calendar day of t in the policy timezone, UTC when unset.
+func (p *Policy) Day(t time.Time) string {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	return t.In(loc).Format(time.DateOnly)
+}
+
+// Checkin records the entries of one ticket, whoever held it. HolderDID and TbaAddress are the holder
+// of the last entry.
+type Checkin struct {
+	ID              string         `json:"id" bson:"_id"`
+	ChainID         int64          `json:"chainId" bson:"chainId"`
+	ContractAddress string         `json:"contractAddress" bson:"contractAddress"`
+	TokenID         uint64         `json:"tokenId" bson:"tokenId"`
+	HolderDID       string         `json:"holderDid" bson:"holderDid"`
+	TbaAddress      string         `json:"tbaAddress" bson:"tbaAddress"`
+	Count           int            `json:"count" bson:"count"`
+	Days            map[string]int `json:"days,omitempty" bson:"days,omitempty"`
+	Entries         []Entry        `json:"entries" bson:"entries"`
+	CreatedAt       int64          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       int64          `json:"updatedAt" bson:"updatedAt"`
+}
+
+type Entry struct {
+	At  int64  `json:"at" bson:"at"`
+	Day string `json:"day" bson:"day"`
+	// ScannedBy is the DID of the issuer or staff member who ran the scan
+	ScannedBy string `json:"scannedBy,omitempty" bson:"scannedBy,omitempty"`
+}
+
+type Checkins struct {
+	Items      []*Checkin             `json:"items"`
+	Pagination *pagination.Pagination `json:"pagination"`
+}
+
+// Key returns the key of a ticket collection on a chain.
+func Key(chainID int64, contractAddress string) string {
+	return fmt.Sprintf("%d-%s", chainID, strings.ToLower(contractAddress))
+}
+
+// CheckinID returns the id of the check-in of a ticket, so a transferred ticket keeps its entries.
+func CheckinID(chainID int64, contractAddress string, tokenID uint64) string {
+	return fmt.Sprintf("%s-%d", Key(chainID, contractAddress), tokenID)
+}
+
+type RedeemParams struct {
+	ChainID         int64
+	ContractAddress string
+	TokenID         uint64
+	HolderDID       string
+	TbaAddress      string
+	ScannedBy       string
+}
+
+type RedeemCheckinParams struct {
+	RedeemParams
+	ID  string
+	At  int64
+	Day string
+	// MaxEntries is the entries allowed in total, or on Day when PerDay is set
+	MaxEntries int
+	PerDay     bool
+}
+
+type UndoCheckinParams struct {
+	ID string
+	// Count is the count the check-in is expected to have, so concurrent entries are not undone
+	Count int
+	Day   string
+}
+
+type CheckinFilter struct {
+	ChainID         int64
+	ContractAddress string
+	Page            int64
+	Limit           int64
+}
@@ -0,0 +1,79 @@
+package checkin
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   error
+	}{
+		{"single", Policy{Type: PolicySingle, MaxEntries: 1}, nil},
+		{"single without max", Policy{Type: PolicySingle}, nil},
+		{"single twice", Policy{Type: PolicySingle, MaxEntries: 2}, ErrInvalidPolicy},
+		{"multi", Policy{Type: PolicyMulti, MaxEntries: 3}, nil},
+		{"multi without max", Policy{Type: PolicyMulti}, ErrInvalidPolicy},
+		{"daily", Policy{Type: PolicyDaily, MaxEntries: 1, Timezone: "Asia/Seoul"}, nil},
+		{"daily negative max", Policy{Type: PolicyDaily, MaxEntries: -1}, ErrInvalidPolicy},
+		{"daily unknown timezone", Policy{Type: PolicyDaily, MaxEntries: 1, Timezone: "Mars/Olympus"}, ErrInvalidPolicy},
+		{"unknown type", Policy{Type: "weekly", MaxEntries: 1}, ErrInvalidPolicy},
+	}
+
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Validate() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPolicyDay(t *testing.T) {
+	// 2024-03-01 20:30 UTC is already 2024-03-02 in Seoul and still 2024-03-01 in New York
+	at := time.Date(2024, 3, 1, 20, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		timezone string
+		want     string
+	}{
+		{"", "2024-03-01"},
+		{"Asia/Seoul", "2024-03-02"},
+		{"America/New_York", "2024-03-01"},
+		{"Mars/Olympus", "2024-03-01"},
+	}
+
+	for _, tt := range tests {
+		p := Policy{Type: PolicyDaily, MaxEntries: 1, Timezone: tt.timezone}
+		if got := p.Day(at); got != tt.want {
+			t.Errorf("Day() in %q = %s, want %s", tt.timezone, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyLimit(t *testing.T) {
+	tests := []struct {
+		policy     Policy
+		wantMax    int
+		wantPerDay bool
+	}{
+		{Policy{Type: PolicySingle}, 1, false},
+		{Policy{Type: PolicyMulti, MaxEntries: 3}, 3, false},
+		{Policy{Type: PolicyDaily, MaxEntries: 2}, 2, true},
+		{Policy{Type: "weekly", MaxEntries: 5}, 1, false},
+	}
+
+	for _, tt := range tests {
+		max, perDay := tt.policy.limit()
+		if max != tt.wantMax || perDay != tt.wantPerDay {
+			t.Errorf("%s limit() = %d, %t, want %d, %t", tt.policy.Type, max, perDay, tt.wantMax, tt.wantPerDay)
+		}
+	}
+}
+
+func TestCheckinID(t *testing.T) {
+	if got, want := CheckinID(11155111, "0xABCdef", 7), "11155111-0xabcdef-7"; got != want {
+		t.Errorf("CheckinID() = %s, want %s", got, want)
+	}
+}
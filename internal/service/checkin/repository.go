@@ -0,0 +1,23 @@
+package checkin
+
+import "context"
+
+type Query interface {
+	FindCheckinByID(ctx context.Context, id string) (*Checkin, error)
+	FindCheckins(ctx context.Context, filter CheckinFilter) (*Checkins, error)
+	FindCheckinsByHolder(ctx context.Context, holderDID string) ([]*Checkin, error)
+	FindPolicy(ctx context.Context, id string) (*Policy, error)
+}
+
+type Command interface {
+	RedeemCheckin(ctx context.Context, params RedeemCheckinParams) (*Checkin, error)
+	UndoCheckin(ctx context.Context, params UndoCheckinParams) (*Checkin, error)
+	AnonymizeHolder(ctx context.Context, did string) error
+	ReassignHolder(ctx context.Context, fromDID, toDID string) error
+	SavePolicy(ctx context.Context, p *Policy) error
+}
+
+type Repository interface {
+	Query
+	Command
+}
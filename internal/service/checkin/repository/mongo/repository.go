@@ -0,0 +1,301 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroticket/internal/pagination"
+	"github.com/heroticket/internal/service/checkin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRepository struct {
+	checkin.Query
+	checkin.Command
+	client *mongo.Client
+	dbname string
+}
+
+func New(ctx context.Context, client *mongo.Client, dbname string) (checkin.Repository, error) {
+	cmd := NewMongoCommand(client, dbname)
+	repo := &mongoRepository{
+		Query:   NewMongoQuery(client, dbname),
+		Command: cmd,
+		client:  client,
+		dbname:  dbname,
+	}
+
+	_, err := cmd.collection().Indexes().CreateMany(
+		ctx,
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "chainId", Value: 1},
+					{Key: "contractAddress", Value: 1},
+					{Key: "updatedAt", Value: -1},
+				},
+			},
+			{
+				Keys: bson.M{"holderDid": 1},
+			},
+		},
+	)
+
+	return repo, err
+}
+
+type mongoQuery struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoQuery(client *mongo.Client, dbname string) checkin.Query {
+	return &mongoQuery{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (q *mongoQuery) FindCheckinByID(ctx context.Context, id string) (*checkin.Checkin, error) {
+	coll := q.collection()
+
+	filter := bson.M{"_id": id}
+
+	var c checkin.Checkin
+
+	if err := coll.FindOne(ctx, filter).Decode(&c); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, checkin.ErrCheckinNotFound
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (q *mongoQuery) FindCheckins(ctx context.Context, filter checkin.CheckinFilter) (*checkin.Checkins, error) {
+	coll := q.collection()
+
+	f := bson.M{
+		"chainId":         filter.ChainID,
+		"contractAddress": filter.ContractAddress,
+	}
+
+	total, err := coll.CountDocuments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := pagination.New(total, filter.Page, filter.Limit)
+
+	opts := options.Find().
+		SetSort(bson.M{"updatedAt": -1}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cursor, err := coll.Find(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]*checkin.Checkin, 0)
+
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return &checkin.Checkins{
+		Items:      items,
+		Pagination: p,
+	}, nil
+}
+
+func (q *mongoQuery) FindCheckinsByHolder(ctx context.Context, holderDID string) ([]*checkin.Checkin, error) {
+	coll := q.collection()
+
+	cursor, err := coll.Find(ctx, bson.M{"holderDid": holderDID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]*checkin.Checkin, 0)
+
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (q *mongoQuery) FindPolicy(ctx context.Context, id string) (*checkin.Policy, error) {
+	coll := q.client.Database(q.dbname).Collection("checkin_policies")
+
+	filter := bson.M{"_id": id}
+
+	var p checkin.Policy
+
+	if err := coll.FindOne(ctx, filter).Decode(&p); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, checkin.ErrPolicyNotFound
+		}
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (q *mongoQuery) collection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("checkins")
+}
+
+type mongoCommand struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoCommand(client *mongo.Client, dbname string) *mongoCommand {
+	return &mongoCommand{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+// RedeemCheckin adds an entry in a single upsert. The filter only matches a check-in with entries
+// left, so a spent one falls through to the insert and fails on the duplicate _id.
+func (c *mongoCommand) RedeemCheckin(ctx context.Context, params checkin.RedeemCheckinParams) (*checkin.Checkin, error) {
+	coll := c.collection()
+
+	filter := bson.M{"_id": params.ID}
+
+	if params.PerDay {
+		filter["days."+params.Day] = bson.M{"$not": bson.M{"$gte": params.MaxEntries}}
+	} else {
+		filter["count"] = bson.M{"$not": bson.M{"$gte": params.MaxEntries}}
+	}
+
+	update := bson.M{
+		"$inc": bson.M{
+			"count":              1,
+			"days." + params.Day: 1,
+		},
+		"$push": bson.M{
+			"entries": checkin.Entry{At: params.At, Day: params.Day, ScannedBy: params.ScannedBy},
+		},
+		"$set": bson.M{
+			"holderDid":  params.HolderDID,
+			"tbaAddress": params.TbaAddress,
+			"updatedAt":  params.At,
+		},
+		"$setOnInsert": bson.M{
+			"chainId":         params.ChainID,
+			"contractAddress": params.ContractAddress,
+			"tokenId":         params.TokenID,
+			"createdAt":       params.At,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var result checkin.Checkin
+
+	if err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, checkin.ErrEntryLimitReached
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AnonymizeHolder removes a DID from the check-ins it last held and the entries it scanned. The
+// check-ins are keyed by ticket, so their entries still limit the ticket whoever holds it next.
+func (c *mongoCommand) AnonymizeHolder(ctx context.Context, did string) error {
+	coll := c.collection()
+
+	_, err := coll.UpdateMany(ctx, bson.M{"holderDid": did}, bson.M{"$set": bson.M{"holderDid": ""}})
+	if err != nil {
+		return err
+	}
+
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"e.scannedBy": did}},
+	})
+
+	_, err = coll.UpdateMany(ctx, bson.M{"entries.scannedBy": did}, bson.M{"$unset": bson.M{"entries.$[e].scannedBy": ""}}, opts)
+
+	return err
+}
+
+// ReassignHolder moves the check-ins held and the entries scanned by a DID to another one.
+func (c *mongoCommand) ReassignHolder(ctx context.Context, fromDID, toDID string) error {
+	coll := c.collection()
+
+	_, err := coll.UpdateMany(ctx, bson.M{"holderDid": fromDID}, bson.M{"$set": bson.M{"holderDid": toDID}})
+	if err != nil {
+		return err
+	}
+
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"e.scannedBy": fromDID}},
+	})
+
+	_, err = coll.UpdateMany(ctx, bson.M{"entries.scannedBy": fromDID}, bson.M{"$set": bson.M{"entries.$[e].scannedBy": toDID}}, opts)
+
+	return err
+}
+
+func (c *mongoCommand) UndoCheckin(ctx context.Context, params checkin.UndoCheckinParams) (*checkin.Checkin, error) {
+	coll := c.collection()
+
+	filter := bson.M{
+		"_id":   params.ID,
+		"count": params.Count,
+	}
+
+	update := bson.M{
+		"$inc": bson.M{
+			"count":              -1,
+			"days." + params.Day: -1,
+		},
+		"$pop": bson.M{
+			"entries": 1,
+		},
+		"$set": bson.M{
+			"updatedAt": time.Now().Unix(),
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var result checkin.Checkin
+
+	if err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, checkin.ErrCheckinChanged
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *mongoCommand) SavePolicy(ctx context.Context, p *checkin.Policy) error {
+	coll := c.client.Database(c.dbname).Collection("checkin_policies")
+
+	filter := bson.M{"_id": p.ID}
+
+	_, err := coll.ReplaceOne(ctx, filter, p, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+func (c *mongoCommand) collection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("checkins")
+}
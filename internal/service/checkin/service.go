@@ -0,0 +1,123 @@
+package checkin
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+type Service interface {
+	Redeem(ctx context.Context, params RedeemParams) (*Checkin, error)
+	Undo(ctx context.Context, id string) (*Checkin, error)
+	FindCheckinByID(ctx context.Context, id string) (*Checkin, error)
+	FindCheckins(ctx context.Context, filter CheckinFilter) (*Checkins, error)
+	FindCheckinsByHolder(ctx context.Context, holderDID string) ([]*Checkin, error)
+	AnonymizeHolder(ctx context.Context, did string) error
+	ReassignHolder(ctx context.Context, fromDID, toDID string) error
+	FindPolicy(ctx context.Context, chainID int64, contractAddress string) (*Policy, error)
+	SavePolicy(ctx context.Context, p *Policy) error
+}
+
+type checkinService struct {
+	repo Repository
+}
+
+func New(repo Repository) Service {
+	return &checkinService{repo: repo}
+}
+
+// Redeem records an entry of the holder under the collection re-entry policy, failing with
+// ErrEntryLimitReached when the policy admits no more entries.
+func (svc *checkinService) Redeem(ctx context.Context, params RedeemParams) (*Checkin, error) {
+	policy, err := svc.FindPolicy(ctx, params.ChainID, params.ContractAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	params.ContractAddress = strings.ToLower(params.ContractAddress)
+	params.TbaAddress = strings.ToLower(params.TbaAddress)
+
+	now := time.Now()
+	max, perDay := policy.limit()
+
+	return svc.repo.RedeemCheckin(ctx, RedeemCheckinParams{
+		RedeemParams: params,
+		ID:           CheckinID(params.ChainID, params.ContractAddress, params.TokenID),
+		At:           now.Unix(),
+		Day:          policy.Day(now),
+		MaxEntries:   max,
+		PerDay:       perDay,
+	})
+}
+
+// Undo removes the last entry of a check-in.
+func (svc *checkinService) Undo(ctx context.Context, id string) (*Checkin, error) {
+	c, err := svc.repo.FindCheckinByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Count == 0 || len(c.Entries) == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	return svc.repo.UndoCheckin(ctx, UndoCheckinParams{
+		ID:    c.ID,
+		Count: c.Count,
+		Day:   c.Entries[len(c.Entries)-1].Day,
+	})
+}
+
+func (svc *checkinService) FindCheckinByID(ctx context.Context, id string) (*Checkin, error) {
+	return svc.repo.FindCheckinByID(ctx, id)
+}
+
+func (svc *checkinService) FindCheckins(ctx context.Context, filter CheckinFilter) (*Checkins, error) {
+	filter.ContractAddress = strings.ToLower(filter.ContractAddress)
+
+	return svc.repo.FindCheckins(ctx, filter)
+}
+
+func (svc *checkinService) FindCheckinsByHolder(ctx context.Context, holderDID string) ([]*Checkin, error) {
+	return svc.repo.FindCheckinsByHolder(ctx, holderDID)
+}
+
+// AnonymizeHolder removes a DID from the check-ins, for a deleted account. The entries stay with
+// their tickets.
+func (svc *checkinService) AnonymizeHolder(ctx context.Context, did string) error {
+	return svc.repo.AnonymizeHolder(ctx, did)
+}
+
+// ReassignHolder moves the check-ins of a DID to the one its wallet was relinked to.
+func (svc *checkinService) ReassignHolder(ctx context.Context, fromDID, toDID string) error {
+	return svc.repo.ReassignHolder(ctx, fromDID, toDID)
+}
+
+// FindPolicy returns the re-entry policy of a collection, DefaultPolicy when none was saved.
+func (svc *checkinService) FindPolicy(ctx context.Context, chainID int64, contractAddress string) (*Policy, error) {
+	p, err := svc.repo.FindPolicy(ctx, Key(chainID, contractAddress))
+	if err != nil {
+		if err == ErrPolicyNotFound {
+			return DefaultPolicy(chainID, contractAddress), nil
+		}
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (svc *checkinService) SavePolicy(ctx context.Context, p *Policy) error {
+	if p.Type == PolicySingle {
+		p.MaxEntries = 1
+	}
+
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	p.ContractAddress = strings.ToLower(p.ContractAddress)
+	p.ID = Key(p.ChainID, p.ContractAddress)
+	p.UpdatedAt = time.Now().Unix()
+
+	return svc.repo.SavePolicy(ctx, p)
+}
@@ -0,0 +1,88 @@
+package checkin
+
+import (
+	"context"
+	"testing"
+)
+
+// memoryRepository redeems check-ins like the mongo one, the other methods are not used.
+type memoryRepository struct {
+	Repository
+	policy   *Policy
+	checkins map[string]*Checkin
+}
+
+func (r *memoryRepository) FindPolicy(ctx context.Context, id string) (*Policy, error) {
+	if r.policy == nil {
+		return nil, ErrPolicyNotFound
+	}
+
+	return r.policy, nil
+}
+
+func (r *memoryRepository) RedeemCheckin(ctx context.Context, params RedeemCheckinParams) (*Checkin, error) {
+	c, ok := r.checkins[params.ID]
+	if !ok {
+		c = &Checkin{ID: params.ID, TokenID: params.TokenID, Days: map[string]int{}}
+		r.checkins[params.ID] = c
+	}
+
+	used := c.Count
+	if params.PerDay {
+		used = c.Days[params.Day]
+	}
+
+	if used >= params.MaxEntries {
+		return nil, ErrEntryLimitReached
+	}
+
+	c.Count++
+	c.Days[params.Day]++
+	c.HolderDID = params.HolderDID
+	c.TbaAddress = params.TbaAddress
+
+	return c, nil
+}
+
+func TestRedeemLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+		// entries is how many redeems of one ticket are admitted in a row today
+		entries int
+	}{
+		{"default single", nil, 1},
+		{"multi", &Policy{Type: PolicyMulti, MaxEntries: 3}, 3},
+		{"daily", &Policy{Type: PolicyDaily, MaxEntries: 2, Timezone: "Asia/Seoul"}, 2},
+	}
+
+	for _, tt := range tests {
+		svc := New(&memoryRepository{policy: tt.policy, checkins: map[string]*Checkin{}})
+
+		params := RedeemParams{ChainID: 1, ContractAddress: "0xC", TokenID: 7, HolderDID: "did:a", TbaAddress: "0xA"}
+
+		for i := 0; i < tt.entries; i++ {
+			if _, err := svc.Redeem(context.Background(), params); err != nil {
+				t.Fatalf("%s: entry %d: %v", tt.name, i+1, err)
+			}
+		}
+
+		if _, err := svc.Redeem(context.Background(), params); err != ErrEntryLimitReached {
+			t.Errorf("%s: entry %d = %v, want %v", tt.name, tt.entries+1, err, ErrEntryLimitReached)
+		}
+
+		// the ticket transferred to another holder has no entries left either
+		params.HolderDID, params.TbaAddress = "did:b", "0xB"
+
+		if _, err := svc.Redeem(context.Background(), params); err != ErrEntryLimitReached {
+			t.Errorf("%s: transferred ticket = %v, want %v", tt.name, err, ErrEntryLimitReached)
+		}
+
+		// another ticket of the collection is counted apart
+		params.TokenID = 8
+
+		if _, err := svc.Redeem(context.Background(), params); err != nil {
+			t.Errorf("%s: other ticket: %v", tt.name, err)
+		}
+	}
+}
@@ -66,6 +66,14 @@ type GetClaimQrCodeResponse struct {
 	Type string `json:"type"`
 }
 
+// GetClaimResponse is the part of an issued credential needed to revoke it.
+type GetClaimResponse struct {
+	ID               string `json:"id"`
+	CredentialStatus struct {
+		RevocationNonce uint64 `json:"revocationNonce"`
+	} `json:"credentialStatus"`
+}
+
 type SaveClaimParams struct {
 	ID              string
 	UserID          string
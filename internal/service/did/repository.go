@@ -4,10 +4,12 @@ import "context"
 
 type Query interface {
 	FindClaim(ctx context.Context, userID, contractAddress string) (*Claim, error)
+	FindClaims(ctx context.Context, userID string) ([]*Claim, error)
 }
 
 type Command interface {
 	SaveClaim(ctx context.Context, params SaveClaimParams) (*Claim, error)
+	DeleteClaim(ctx context.Context, id string) error
 }
 
 type Repository interface {
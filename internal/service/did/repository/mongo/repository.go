@@ -7,6 +7,7 @@ import (
 	"github.com/heroticket/internal/service/did"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoRepository struct {
@@ -53,11 +54,30 @@ func (q *mongoQuery) FindClaim(ctx context.Context, userID string, contractAddre
 		if err == mongo.ErrNoDocuments {
 			return nil, did.ErrClaimNotFound
 		}
+		return nil, err
 	}
 
 	return &claim, nil
 }
 
+func (q *mongoQuery) FindClaims(ctx context.Context, userID string) ([]*did.Claim, error) {
+	coll := q.collection()
+
+	cursor, err := coll.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	claims := make([]*did.Claim, 0)
+
+	if err := cursor.All(ctx, &claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
 func (q *mongoQuery) collection() *mongo.Collection {
 	return q.client.Database(q.dbname).Collection("claims")
 }
@@ -93,6 +113,21 @@ func (c *mongoCommand) SaveClaim(ctx context.Context, params did.SaveClaimParams
 	return claim, nil
 }
 
+func (c *mongoCommand) DeleteClaim(ctx context.Context, id string) error {
+	coll := c.collection()
+
+	res, err := coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return did.ErrClaimNotFound
+	}
+
+	return nil
+}
+
 func (c *mongoCommand) collection() *mongo.Collection {
 	return c.client.Database(c.dbname).Collection("claims")
 }
@@ -15,8 +15,10 @@ type Service interface {
 	CreateIdentity(ctx context.Context, identity CreateIdentityRequest) (*CreateIdentityResponse, error)
 	CreateClaim(ctx context.Context, identifier string, claim CreateClaimRequest) (*CreateClaimResponse, error)
 	FindClaim(ctx context.Context, userID, contractAddress string) (*Claim, error)
+	FindClaims(ctx context.Context, userID string) ([]*Claim, error)
 	GetClaimQrCode(ctx context.Context, identifier string, claimId string) (*GetClaimQrCodeResponse, error)
 	SaveClaim(ctx context.Context, params SaveClaimParams) (*Claim, error)
+	RevokeClaim(ctx context.Context, identifier string, claim *Claim) error
 }
 
 type DidServiceConfig struct {
@@ -136,6 +138,10 @@ func (s *DidService) FindClaim(ctx context.Context, userID, contractAddress stri
 	return s.repo.FindClaim(ctx, userID, contractAddress)
 }
 
+func (s *DidService) FindClaims(ctx context.Context, userID string) ([]*Claim, error) {
+	return s.repo.FindClaims(ctx, userID)
+}
+
 func (s *DidService) GetClaimQrCode(ctx context.Context, identifier string, claimId string) (*GetClaimQrCodeResponse, error) {
 	// check if qrcode exists in cache
 	var qrcode GetClaimQrCodeResponse
@@ -184,6 +190,61 @@ func (s *DidService) SaveClaim(ctx context.Context, params SaveClaimParams) (*Cl
 	return s.repo.SaveClaim(ctx, params)
 }
 
+// RevokeClaim revokes a credential issued by identifier on the issuer node and forgets it, so holders
+// can no longer prove it.
+func (s *DidService) RevokeClaim(ctx context.Context, identifier string, claim *Claim) error {
+	// 1. get the revocation nonce of the credential
+	url := fmt.Sprintf("%s/v1/%s/claims/%s", s.issuerUrl, identifier, claim.ID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	s.setAuthorizationHeader(req)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errorFromResponse(res)
+	}
+
+	var getClaimResponse GetClaimResponse
+
+	if err := json.NewDecoder(res.Body).Decode(&getClaimResponse); err != nil {
+		return err
+	}
+
+	// 2. revoke it
+	url = fmt.Sprintf("%s/v1/%s/claims/revoke/%d", s.issuerUrl, identifier, getClaimResponse.CredentialStatus.RevocationNonce)
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+
+	s.setAuthorizationHeader(req)
+
+	revokeRes, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer revokeRes.Body.Close()
+
+	if revokeRes.StatusCode != http.StatusAccepted {
+		return errorFromResponse(revokeRes)
+	}
+
+	// 3. forget it, the cached qr code would offer a revoked credential
+	_ = s.qrCache.Delete(ctx, claim.ID)
+
+	return s.repo.DeleteClaim(ctx, claim.ID)
+}
+
 func (s *DidService) setAuthorizationHeader(req *http.Request) {
 	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", s.username, s.password)))))
 }
@@ -0,0 +1,120 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrNoJobAvailable  = errors.New("no job available")
+	ErrUnknownJobType  = errors.New("unknown job type")
+	ErrJobNotRetryable = errors.New("job is not retryable")
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+func (s Status) Valid() bool {
+	return s == StatusPending || s == StatusRunning || s == StatusSucceeded || s == StatusFailed
+}
+
+// Job is a unit of work run by the worker pool.
+// Key identifies the request that created the job, so the same request never enqueues it twice.
+// State is saved by the handler between steps so a retried job can skip steps that already happened.
+type Job struct {
+	ID          string          `json:"id" bson:"_id"`
+	Type        string          `json:"type" bson:"type"`
+	Key         string          `json:"-" bson:"key"`
+	UserID      string          `json:"userId" bson:"userId"`
+	SessionID   string          `json:"-" bson:"sessionId,omitempty"`
+	Status      Status          `json:"status" bson:"status"`
+	Payload     json.RawMessage `json:"-" bson:"payload"`
+	State       json.RawMessage `json:"-" bson:"state,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty" bson:"result,omitempty"`
+	Error       string          `json:"error,omitempty" bson:"error,omitempty"`
+	Attempts    int             `json:"attempts" bson:"attempts"`
+	MaxAttempts int             `json:"maxAttempts" bson:"maxAttempts"`
+	RunAt       int64           `json:"runAt" bson:"runAt"`
+	LockedUntil int64           `json:"-" bson:"lockedUntil"`
+	CreatedAt   int64           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   int64           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Done reports whether the job reached a final status.
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+func (j *Job) DecodePayload(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// DecodeState decodes the saved state into v, leaving v untouched when nothing was saved yet.
+func (j *Job) DecodeState(v any) error {
+	if len(j.State) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(j.State, v)
+}
+
+type EnqueueParams struct {
+	Type string
+	Key  string
+	// Renew enqueues a new job when the one created for Key already succeeded, for keys derived from
+	// the request rather than sent by the client: repeating such a request later is a new request.
+	Renew     bool
+	UserID    string
+	SessionID string
+	Payload   any
+}
+
+type CreateJobParams struct {
+	Type        string
+	Key         string
+	UserID      string
+	SessionID   string
+	Payload     json.RawMessage
+	MaxAttempts int
+}
+
+type FailJobParams struct {
+	ID    string
+	Error string
+	// RetryAt schedules another attempt at the given unix time; 0 fails the job for good
+	RetryAt int64
+}
+
+// permanentError marks an error that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps err so the worker fails the job without retrying it.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var perr *permanentError
+	return errors.As(err, &perr)
+}
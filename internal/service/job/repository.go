@@ -0,0 +1,29 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type Query interface {
+	FindJobByID(ctx context.Context, id string) (*Job, error)
+	FindJobsByUser(ctx context.Context, userID string) ([]*Job, error)
+}
+
+type Command interface {
+	CreateJob(ctx context.Context, params CreateJobParams) (*Job, error)
+	RequeueJob(ctx context.Context, id string, params CreateJobParams) (*Job, error)
+	ReleaseJobKey(ctx context.Context, id string) error
+	ClaimJob(ctx context.Context, types []string, lease time.Duration) (*Job, error)
+	SaveJobState(ctx context.Context, id string, state json.RawMessage) error
+	CompleteJob(ctx context.Context, id string, result json.RawMessage) (*Job, error)
+	FailJob(ctx context.Context, params FailJobParams) (*Job, error)
+	AnonymizeUserJobs(ctx context.Context, userID string) error
+	ReassignUserJobs(ctx context.Context, fromUserID, toUserID string) error
+}
+
+type Repository interface {
+	Query
+	Command
+}
@@ -0,0 +1,357 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/heroticket/internal/service/job"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRepository struct {
+	job.Query
+	job.Command
+	client *mongo.Client
+	dbname string
+}
+
+func New(ctx context.Context, client *mongo.Client, dbname string) (job.Repository, error) {
+	cmd := NewMongoCommand(client, dbname)
+	repo := &mongoRepository{
+		Query:   NewMongoQuery(client, dbname),
+		Command: cmd,
+		client:  client,
+		dbname:  dbname,
+	}
+
+	_, err := cmd.collection().Indexes().CreateMany(
+		ctx,
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.M{"key": 1},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "runAt", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+		},
+	)
+
+	return repo, err
+}
+
+type mongoQuery struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoQuery(client *mongo.Client, dbname string) job.Query {
+	return &mongoQuery{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (q *mongoQuery) FindJobByID(ctx context.Context, id string) (*job.Job, error) {
+	coll := q.collection()
+
+	filter := bson.M{"_id": id}
+
+	var j job.Job
+
+	if err := coll.FindOne(ctx, filter).Decode(&j); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, job.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+func (q *mongoQuery) FindJobsByUser(ctx context.Context, userID string) ([]*job.Job, error) {
+	coll := q.collection()
+
+	cursor, err := coll.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := make([]*job.Job, 0)
+
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (q *mongoQuery) collection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("jobs")
+}
+
+type mongoCommand struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoCommand(client *mongo.Client, dbname string) *mongoCommand {
+	return &mongoCommand{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+// AnonymizeUserJobs drops the user and their data from the finished jobs of the user. The key, which
+// names the user, becomes unique to the job.
+func (c *mongoCommand) AnonymizeUserJobs(ctx context.Context, userID string) error {
+	coll := c.collection()
+
+	filter := bson.M{
+		"userId": userID,
+		"status": bson.M{"$in": []job.Status{job.StatusSucceeded, job.StatusFailed}},
+	}
+
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"userId":    "",
+			"key":       bson.M{"$concat": bson.A{"anonymized:", "$_id"}},
+			"updatedAt": time.Now().Unix(),
+		}},
+		bson.M{"$unset": bson.A{"sessionId", "payload", "state", "result", "error"}},
+	}
+
+	_, err := coll.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (c *mongoCommand) ReassignUserJobs(ctx context.Context, fromUserID, toUserID string) error {
+	coll := c.collection()
+
+	update := bson.M{
+		"$set": bson.M{"userId": toUserID, "updatedAt": time.Now().Unix()},
+	}
+
+	_, err := coll.UpdateMany(ctx, bson.M{"userId": fromUserID}, update)
+	return err
+}
+
+// CreateJob inserts a pending job unless one with the same key exists, and returns the stored job either way.
+func (c *mongoCommand) CreateJob(ctx context.Context, params job.CreateJobParams) (*job.Job, error) {
+	coll := c.collection()
+
+	now := time.Now().Unix()
+
+	filter := bson.M{"key": params.Key}
+
+	update := bson.M{
+		"$setOnInsert": job.Job{
+			ID:          uuid.New().String(),
+			Type:        params.Type,
+			Key:         params.Key,
+			UserID:      params.UserID,
+			SessionID:   params.SessionID,
+			Status:      job.StatusPending,
+			Payload:     params.Payload,
+			MaxAttempts: params.MaxAttempts,
+			RunAt:       now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var j job.Job
+
+	err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&j)
+	if mongo.IsDuplicateKeyError(err) {
+		// lost the upsert race to a concurrent request with the same key
+		err = coll.FindOne(ctx, filter).Decode(&j)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+// RequeueJob resets a failed job to pending with the payload of the retried request.
+// Saved state is kept, so the job resumes after its last completed step.
+func (c *mongoCommand) RequeueJob(ctx context.Context, id string, params job.CreateJobParams) (*job.Job, error) {
+	coll := c.collection()
+
+	now := time.Now().Unix()
+
+	filter := bson.M{"_id": id, "status": job.StatusFailed}
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":      job.StatusPending,
+			"sessionId":   params.SessionID,
+			"payload":     params.Payload,
+			"attempts":    0,
+			"maxAttempts": params.MaxAttempts,
+			"error":       "",
+			"runAt":       now,
+			"updatedAt":   now,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var j job.Job
+
+	if err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&j); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, job.ErrJobNotRetryable
+		}
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+// ReleaseJobKey frees the key of a succeeded job for a new one, suffixing it with the job id so the
+// job itself is kept. Releasing a key that was already released is a no-op.
+func (c *mongoCommand) ReleaseJobKey(ctx context.Context, id string) error {
+	coll := c.collection()
+
+	filter := bson.M{"_id": id, "status": job.StatusSucceeded}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"key":       bson.M{"$concat": bson.A{"$key", ":", "$_id"}},
+			"updatedAt": time.Now().Unix(),
+		}}},
+	}
+
+	_, err := coll.UpdateOne(ctx, filter, update)
+
+	return err
+}
+
+// ClaimJob marks the next due job of the given types as running for lease.
+// Running jobs whose lease expired, e.g. because their worker died, are claimed again.
+func (c *mongoCommand) ClaimJob(ctx context.Context, types []string, lease time.Duration) (*job.Job, error) {
+	coll := c.collection()
+
+	now := time.Now()
+
+	filter := bson.M{
+		"type": bson.M{"$in": types},
+		"$or": bson.A{
+			bson.M{"status": job.StatusPending, "runAt": bson.M{"$lte": now.Unix()}},
+			bson.M{"status": job.StatusRunning, "lockedUntil": bson.M{"$lte": now.Unix()}},
+		},
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":      job.StatusRunning,
+			"lockedUntil": now.Add(lease).Unix(),
+			"updatedAt":   now.Unix(),
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "runAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var j job.Job
+
+	if err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&j); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, job.ErrNoJobAvailable
+		}
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+func (c *mongoCommand) SaveJobState(ctx context.Context, id string, state json.RawMessage) error {
+	coll := c.collection()
+
+	filter := bson.M{"_id": id}
+
+	update := bson.M{
+		"$set": bson.M{
+			"state":     state,
+			"updatedAt": time.Now().Unix(),
+		},
+	}
+
+	res, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return job.ErrJobNotFound
+	}
+
+	return nil
+}
+
+func (c *mongoCommand) CompleteJob(ctx context.Context, id string, result json.RawMessage) (*job.Job, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"status":      job.StatusSucceeded,
+			"result":      result,
+			"error":       "",
+			"lockedUntil": 0,
+			"updatedAt":   time.Now().Unix(),
+		},
+	}
+
+	return c.finish(ctx, id, update)
+}
+
+func (c *mongoCommand) FailJob(ctx context.Context, params job.FailJobParams) (*job.Job, error) {
+	set := bson.M{
+		"status":      job.StatusFailed,
+		"error":       params.Error,
+		"lockedUntil": 0,
+		"updatedAt":   time.Now().Unix(),
+	}
+
+	if params.RetryAt > 0 {
+		set["status"] = job.StatusPending
+		set["runAt"] = params.RetryAt
+	}
+
+	return c.finish(ctx, params.ID, bson.M{"$set": set})
+}
+
+func (c *mongoCommand) finish(ctx context.Context, id string, update bson.M) (*job.Job, error) {
+	coll := c.collection()
+
+	filter := bson.M{"_id": id}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var j job.Job
+
+	if err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&j); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, job.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+func (c *mongoCommand) collection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("jobs")
+}
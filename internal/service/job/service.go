@@ -0,0 +1,324 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/heroticket/internal/logger"
+)
+
+var (
+	DefaultWorkers      = 4
+	DefaultMaxAttempts  = 3
+	DefaultPollInterval = time.Second
+	DefaultLease        = 10 * time.Minute
+	DefaultRetryBackoff = 30 * time.Second
+)
+
+// Handler runs a job and returns its result. Returning an error wrapped with Permanent fails the job without retrying.
+type Handler func(ctx context.Context, j *Job) (any, error)
+
+// Notifier is called whenever a job reaches a final status.
+type Notifier func(j *Job)
+
+type Service interface {
+	Enqueue(ctx context.Context, params EnqueueParams) (*Job, error)
+	FindJobByID(ctx context.Context, id string) (*Job, error)
+	FindJobsByUser(ctx context.Context, userID string) ([]*Job, error)
+	AnonymizeUserJobs(ctx context.Context, userID string) error
+	ReassignUserJobs(ctx context.Context, fromUserID, toUserID string) error
+	SaveState(ctx context.Context, j *Job, state any) error
+	Handle(jobType string, h Handler)
+	Run(ctx context.Context)
+}
+
+type JobServiceConfig struct {
+	Repo         Repository
+	Notify       Notifier
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	Lease        time.Duration
+	RetryBackoff time.Duration
+}
+
+type JobService struct {
+	repo   Repository
+	notify Notifier
+
+	workers      int
+	maxAttempts  int
+	pollInterval time.Duration
+	lease        time.Duration
+	retryBackoff time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func New(cfg JobServiceConfig) Service {
+	svc := &JobService{
+		repo:   cfg.Repo,
+		notify: cfg.Notify,
+
+		workers:      DefaultWorkers,
+		maxAttempts:  DefaultMaxAttempts,
+		pollInterval: DefaultPollInterval,
+		lease:        DefaultLease,
+		retryBackoff: DefaultRetryBackoff,
+
+		handlers: make(map[string]Handler),
+	}
+
+	if cfg.Workers > 0 {
+		svc.workers = cfg.Workers
+	}
+
+	if cfg.MaxAttempts > 0 {
+		svc.maxAttempts = cfg.MaxAttempts
+	}
+
+	if cfg.PollInterval > 0 {
+		svc.pollInterval = cfg.PollInterval
+	}
+
+	if cfg.Lease > 0 {
+		svc.lease = cfg.Lease
+	}
+
+	if cfg.RetryBackoff > 0 {
+		svc.retryBackoff = cfg.RetryBackoff
+	}
+
+	return svc
+}
+
+// Enqueue creates a job for the request identified by params.Key, or returns the job already created for it.
+// A job that failed for good is queued again, so the client can retry the same request, and with
+// params.Renew a job that succeeded gives its key up to a new job.
+func (s *JobService) Enqueue(ctx context.Context, params EnqueueParams) (*Job, error) {
+	s.mu.RLock()
+	_, ok := s.handlers[params.Type]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownJobType
+	}
+
+	payload, err := json.Marshal(params.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	createParams := CreateJobParams{
+		Type:        params.Type,
+		Key:         params.Key,
+		UserID:      params.UserID,
+		SessionID:   params.SessionID,
+		Payload:     payload,
+		MaxAttempts: s.maxAttempts,
+	}
+
+	j, err := s.repo.CreateJob(ctx, createParams)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case j.Status == StatusFailed:
+		return s.repo.RequeueJob(ctx, j.ID, createParams)
+	case j.Status == StatusSucceeded && params.Renew:
+		if err := s.repo.ReleaseJobKey(ctx, j.ID); err != nil {
+			return nil, err
+		}
+
+		return s.repo.CreateJob(ctx, createParams)
+	default:
+		return j, nil
+	}
+}
+
+func (s *JobService) FindJobByID(ctx context.Context, id string) (*Job, error) {
+	return s.repo.FindJobByID(ctx, id)
+}
+
+// FindJobsByUser returns the jobs of a user, newest first. Their results are the notifications the user was sent.
+func (s *JobService) FindJobsByUser(ctx context.Context, userID string) ([]*Job, error) {
+	return s.repo.FindJobsByUser(ctx, userID)
+}
+
+// AnonymizeUserJobs removes a deleted user from their finished jobs, running ones finish first.
+func (s *JobService) AnonymizeUserJobs(ctx context.Context, userID string) error {
+	return s.repo.AnonymizeUserJobs(ctx, userID)
+}
+
+// ReassignUserJobs moves the jobs of a user to the identity their wallet was relinked to.
+func (s *JobService) ReassignUserJobs(ctx context.Context, fromUserID, toUserID string) error {
+	return s.repo.ReassignUserJobs(ctx, fromUserID, toUserID)
+}
+
+// SaveState persists the progress of a running job.
+func (s *JobService) SaveState(ctx context.Context, j *Job, state any) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SaveJobState(ctx, j.ID, raw); err != nil {
+		return err
+	}
+
+	j.State = raw
+
+	return nil
+}
+
+// Handle registers the handler of a job type. Handlers must be registered before Run.
+func (s *JobService) Handle(jobType string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[jobType] = h
+}
+
+// Run starts the worker pool and blocks until ctx is canceled and every worker finished its current job.
+func (s *JobService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (s *JobService) work(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		j, err := s.repo.ClaimJob(ctx, s.types(), s.lease)
+		if err != nil {
+			if !errors.Is(err, ErrNoJobAvailable) && ctx.Err() == nil {
+				logger.Error("failed to claim job", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.pollInterval):
+			}
+
+			continue
+		}
+
+		s.process(j)
+	}
+}
+
+// process runs j to completion. It deliberately ignores the worker context so a shutdown
+// does not abort a chain write halfway; the lease bounds how long it may take.
+func (s *JobService) process(j *Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.lease)
+	defer cancel()
+
+	s.mu.RLock()
+	h, ok := s.handlers[j.Type]
+	s.mu.RUnlock()
+
+	var (
+		result any
+		err    error
+	)
+
+	if ok {
+		result, err = s.run(ctx, h, j)
+	} else {
+		err = Permanent(ErrUnknownJobType)
+	}
+
+	if err == nil {
+		s.complete(ctx, j, result)
+		return
+	}
+
+	logger.Error("job failed", "id", j.ID, "type", j.Type, "attempt", j.Attempts, "error", err)
+
+	params := FailJobParams{
+		ID:    j.ID,
+		Error: err.Error(),
+	}
+
+	if !IsPermanent(err) && j.Attempts < j.MaxAttempts {
+		params.RetryAt = time.Now().Add(s.retryBackoff * time.Duration(j.Attempts)).Unix()
+	}
+
+	failed, err := s.repo.FailJob(ctx, params)
+	if err != nil {
+		logger.Error("failed to save job failure", "id", j.ID, "error", err)
+		return
+	}
+
+	if failed.Done() {
+		s.notifyDone(failed)
+	}
+}
+
+// run calls h, turning a panic into an error so it fails j like any other error, up to its attempts,
+// instead of taking the server down.
+func (s *JobService) run(ctx context.Context, h Handler, j *Job) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("job panicked", "id", j.ID, "type", j.Type, "panic", r, "stack", string(debug.Stack()))
+			result, err = nil, fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return h(ctx, j)
+}
+
+func (s *JobService) complete(ctx context.Context, j *Job, result any) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		logger.Error("failed to encode job result", "id", j.ID, "error", err)
+		raw = nil
+	}
+
+	done, err := s.repo.CompleteJob(ctx, j.ID, raw)
+	if err != nil {
+		logger.Error("failed to complete job", "id", j.ID, "error", err)
+		return
+	}
+
+	s.notifyDone(done)
+}
+
+func (s *JobService) notifyDone(j *Job) {
+	if s.notify != nil {
+		s.notify(j)
+	}
+}
+
+func (s *JobService) types() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	types := make([]string, 0, len(s.handlers))
+
+	for t := range s.handlers {
+		types = append(types, t)
+	}
+
+	return types
+}
@@ -1,17 +1,25 @@
 package jwt
 
 import (
+	"context"
 	"errors"
+	"slices"
 	"time"
 )
 
 var (
-	ErrInvalidContext          = errors.New("invalid context")
-	ErrInvalidToken            = errors.New("invalid token")
-	ErrInvalidTokenRole        = errors.New("invalid token role")
-	ErrInvalidSigningMethod    = errors.New("invalid token signing method")
-	ErrAccessTokenKeyRequired  = errors.New("access token key is required")
-	ErrRefreshTokenKeyRequired = errors.New("refresh token key is required")
+	ErrInvalidContext       = errors.New("invalid context")
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrInvalidTokenRole     = errors.New("invalid token role")
+	ErrInvalidSigningMethod = errors.New("invalid token signing method")
+	ErrInvalidAlgorithm     = errors.New("invalid signing algorithm")
+	ErrInvalidKey           = errors.New("invalid signing key")
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrNoSigningKey         = errors.New("no active signing key")
+	ErrInvalidEncryptionKey = errors.New("key encryption key must be 32 bytes")
+	ErrTokenRevoked         = errors.New("token revoked")
+	ErrTokenReused          = errors.New("refresh token reused")
+	ErrUserSuspended        = errors.New("user suspended")
 )
 
 type TokenRole uint8
@@ -21,6 +29,18 @@ const (
 	TokenRoleRefresh
 )
 
+// use is the value of the "use" claim telling access and refresh tokens apart, since both are signed by the same keys
+func (r TokenRole) use() string {
+	switch r {
+	case TokenRoleAccess:
+		return "access"
+	case TokenRoleRefresh:
+		return "refresh"
+	default:
+		return ""
+	}
+}
+
 type TokenPair struct {
 	AccessToken        string        `json:"accessToken"`
 	RefreshToken       string        `json:"refreshToken"`
@@ -30,6 +50,32 @@ type TokenPair struct {
 
 type JWTUser struct {
 	ID string `json:"id"`
+	// SessionID names the login the token was issued for, it is shared by every rotated pair
+	SessionID string `json:"sessionId,omitempty"`
+	// Roles and Permissions are carried by access tokens, as they were when the token was issued
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+func (u *JWTUser) HasRole(roles ...string) bool {
+	for _, r := range roles {
+		if slices.Contains(u.Roles, r) {
+			return true
+		}
+	}
+	return false
 }
 
+func (u *JWTUser) HasPermission(perms ...string) bool {
+	for _, p := range perms {
+		if slices.Contains(u.Permissions, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimsFunc returns the roles and permissions access tokens of the user carry.
+type ClaimsFunc func(ctx context.Context, userID string) (roles, permissions []string, err error)
+
 type JWTUserKey struct{}
@@ -0,0 +1,217 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type Algorithm string
+
+const (
+	AlgorithmES256 Algorithm = "ES256"
+	AlgorithmEdDSA Algorithm = "EdDSA"
+)
+
+func (a Algorithm) Valid() bool {
+	return a == AlgorithmES256 || a == AlgorithmEdDSA
+}
+
+// Key is a key of the key ring. A key signs from ActiveAt until a newer key is active,
+// and its tokens verify until ExpiresAt.
+type Key struct {
+	ID        string    `json:"id" bson:"_id"`
+	Algorithm Algorithm `json:"algorithm" bson:"algorithm"`
+	// PrivateKey is PKCS #8 PEM encoded, sealed with the key ring encryption key in the repository
+	PrivateKey string `json:"-" bson:"privateKey"`
+	CreatedAt  int64  `json:"createdAt" bson:"createdAt"`
+	// ActiveAt leaves verifiers time to fetch the public key before tokens are signed with it
+	ActiveAt int64 `json:"activeAt" bson:"activeAt"`
+	// ExpiresAt is zero until the key is replaced
+	ExpiresAt int64 `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+// GenerateKey generates a key for alg which becomes active at activeAt.
+func GenerateKey(alg Algorithm, activeAt time.Time) (*Key, error) {
+	var private crypto.PrivateKey
+
+	switch alg {
+	case AlgorithmES256:
+		pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = pk
+	case AlgorithmEdDSA:
+		_, pk, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = pk
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:         uuid.NewString(),
+		Algorithm:  alg,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:  time.Now().UTC().Unix(),
+		ActiveAt:   activeAt.UTC().Unix(),
+	}, nil
+}
+
+// sealedPrefix marks a private key sealed with AES-GCM, keys stored before sealing are plain PEM.
+const sealedPrefix = "sealed:"
+
+// newKeyCipher returns the AES-256-GCM cipher the private keys are sealed with.
+func newKeyCipher(encryptionKey []byte) (cipher.AEAD, error) {
+	if len(encryptionKey) != 32 {
+		return nil, ErrInvalidEncryptionKey
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal returns a copy of k whose private key is encrypted with aead, bound to the key id.
+func (k *Key) seal(aead cipher.AEAD) (*Key, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := *k
+	sealed.PrivateKey = sealedPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(k.PrivateKey), []byte(k.ID)))
+
+	return &sealed, nil
+}
+
+// open returns a copy of k whose private key is decrypted with aead. A plain PEM key is returned as is.
+func (k *Key) open(aead cipher.AEAD) (*Key, error) {
+	if !strings.HasPrefix(k.PrivateKey, sealedPrefix) {
+		return k, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(k.PrivateKey, sealedPrefix))
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, ErrInvalidKey
+	}
+
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(k.ID))
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	opened := *k
+	opened.PrivateKey = string(plain)
+
+	return &opened, nil
+}
+
+func (k *Key) Active(now int64) bool {
+	return k.ActiveAt <= now && !k.Expired(now)
+}
+
+func (k *Key) Expired(now int64) bool {
+	return k.ExpiresAt != 0 && k.ExpiresAt <= now
+}
+
+// signingKey is a parsed Key.
+type signingKey struct {
+	*Key
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+func (k *Key) parse() (*signingKey, error) {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	sk := &signingKey{Key: k, private: private}
+
+	switch pk := private.(type) {
+	case *ecdsa.PrivateKey:
+		if k.Algorithm != AlgorithmES256 || pk.Curve != elliptic.P256() {
+			return nil, ErrInvalidKey
+		}
+		sk.method = jwt.SigningMethodES256
+		sk.public = &pk.PublicKey
+	case ed25519.PrivateKey:
+		if k.Algorithm != AlgorithmEdDSA {
+			return nil, ErrInvalidKey
+		}
+		sk.method = jwt.SigningMethodEdDSA
+		sk.public = pk.Public()
+	default:
+		return nil, ErrInvalidKey
+	}
+
+	return sk, nil
+}
+
+// JWK is the public part of a key as a JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *signingKey) jwk() JWK {
+	jwk := JWK{
+		Kid: k.ID,
+		Alg: string(k.Algorithm),
+		Use: "sig",
+	}
+
+	switch pk := k.public.(type) {
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pk.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pk.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pk)
+	}
+
+	return jwk
+}
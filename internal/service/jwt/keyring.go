@@ -0,0 +1,290 @@
+package jwt
+
+import (
+	"context"
+	"crypto/cipher"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/heroticket/internal/logger"
+)
+
+var (
+	defaultAlgorithm      = AlgorithmES256
+	defaultRotationPeriod = time.Hour * 24 * 30
+	// defaultPublishDelay is how long a new key is served in the JWKS before it signs, longer than
+	// the JWKS may be cached and than servers take to reload the ring
+	defaultPublishDelay   = time.Minute * 10
+	defaultReloadInterval = time.Minute
+)
+
+type KeyRingConfig struct {
+	Repo      KeyRepository
+	Algorithm Algorithm
+	// EncryptionKey is the AES-256 key the private keys are sealed with in the repository
+	EncryptionKey []byte
+	// RotationPeriod is how long a key signs before a new one replaces it
+	RotationPeriod time.Duration
+	// GracePeriod is how long tokens of a replaced key still verify, at least the refresh token expiry
+	GracePeriod  time.Duration
+	PublishDelay time.Duration
+}
+
+// KeyRing holds the keys tokens are signed and verified with. Keys are kept in the repository so
+// every server signs with the same key, each server reloads them periodically.
+type KeyRing struct {
+	cfg  KeyRingConfig
+	aead cipher.AEAD
+
+	mu      sync.RWMutex
+	signing *signingKey
+	keys    map[string]*signingKey
+	newest  *Key
+}
+
+// NewKeyRing loads the key ring, an empty ring needs Generate before it can sign.
+func NewKeyRing(ctx context.Context, cfg KeyRingConfig) (*KeyRing, error) {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = defaultAlgorithm
+	}
+
+	if !cfg.Algorithm.Valid() {
+		return nil, ErrInvalidAlgorithm
+	}
+
+	if cfg.RotationPeriod <= 0 {
+		cfg.RotationPeriod = defaultRotationPeriod
+	}
+
+	if cfg.GracePeriod <= 0 {
+		cfg.GracePeriod = defaultRefreshTokenExpiry
+	}
+
+	if cfg.PublishDelay <= 0 {
+		cfg.PublishDelay = defaultPublishDelay
+	}
+
+	aead, err := newKeyCipher(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &KeyRing{cfg: cfg, aead: aead}
+
+	if err := r.Load(ctx); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Load reads the keys from the repository.
+func (r *KeyRing) Load(ctx context.Context) error {
+	keys, err := r.cfg.Repo.FindKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Unix()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ActiveAt != keys[j].ActiveAt {
+			return keys[i].ActiveAt < keys[j].ActiveAt
+		}
+		if keys[i].CreatedAt != keys[j].CreatedAt {
+			return keys[i].CreatedAt < keys[j].CreatedAt
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	parsed := make(map[string]*signingKey, len(keys))
+
+	var signing *signingKey
+	var newest *Key
+
+	for _, k := range keys {
+		if k.Expired(now) {
+			continue
+		}
+
+		opened, err := k.open(r.aead)
+		if err != nil {
+			logger.Error("failed to open jwt key", "kid", k.ID, "error", err)
+			continue
+		}
+
+		sk, err := opened.parse()
+		if err != nil {
+			logger.Error("failed to parse jwt key", "kid", k.ID, "error", err)
+			continue
+		}
+
+		parsed[k.ID] = sk
+
+		if k.Active(now) {
+			signing = sk
+		}
+
+		if newest == nil || k.CreatedAt >= newest.CreatedAt {
+			newest = k
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = parsed
+	r.newest = newest
+	r.signing = signing
+
+	return nil
+}
+
+// Generate adds a key which signs right away, without expiring the others. It is meant for an empty ring.
+func (r *KeyRing) Generate(ctx context.Context) (*Key, error) {
+	key, err := GenerateKey(r.cfg.Algorithm, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return key, r.Load(ctx)
+}
+
+// Rotate adds a key which signs once it was published for the publish delay. The keys it replaces
+// verify for the grace period after that.
+func (r *KeyRing) Rotate(ctx context.Context) (*Key, error) {
+	activeAt := time.Now().Add(r.cfg.PublishDelay)
+
+	key, err := GenerateKey(r.cfg.Algorithm, activeAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	err = r.cfg.Repo.ExpireKeys(ctx, key.CreatedAt, activeAt.Add(r.cfg.GracePeriod).UTC().Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	return key, r.Load(ctx)
+}
+
+// create stores key with its private key sealed.
+func (r *KeyRing) create(ctx context.Context, key *Key) error {
+	sealed, err := key.seal(r.aead)
+	if err != nil {
+		return err
+	}
+
+	return r.cfg.Repo.CreateKey(ctx, sealed)
+}
+
+// Run reloads the keys until ctx is done, rotating them when the newest key is older than the
+// rotation period and dropping expired ones.
+func (r *KeyRing) Run(ctx context.Context) {
+	ticker := time.NewTicker(defaultReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.Load(ctx); err != nil {
+			logger.Error("failed to load jwt keys", "error", err)
+			continue
+		}
+
+		if r.rotationDue() {
+			key, err := r.Rotate(ctx)
+			if err != nil {
+				logger.Error("failed to rotate jwt keys", "error", err)
+				continue
+			}
+
+			logger.Info("rotated jwt keys", "kid", key.ID, "activeAt", key.ActiveAt)
+		}
+
+		if err := r.cfg.Repo.DeleteExpiredKeys(ctx, time.Now().UTC().Unix()); err != nil {
+			logger.Error("failed to delete expired jwt keys", "error", err)
+		}
+	}
+}
+
+func (r *KeyRing) rotationDue() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.newest == nil {
+		return true
+	}
+
+	return time.Since(time.Unix(r.newest.CreatedAt, 0)) >= r.cfg.RotationPeriod
+}
+
+// Keys returns the keys which still verify, the private keys left out.
+func (r *KeyRing) Keys() []Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]Key, 0, len(r.keys))
+
+	for _, k := range r.keys {
+		key := *k.Key
+		key.PrivateKey = ""
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt < keys[j].CreatedAt })
+
+	return keys
+}
+
+// JWKS returns the public keys which still verify.
+func (r *KeyRing) JWKS() JWKS {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(r.keys))}
+
+	for _, k := range r.keys {
+		jwks.Keys = append(jwks.Keys, k.jwk())
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+
+	return jwks
+}
+
+func (r *KeyRing) signingKey() (*signingKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.signing == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return r.signing, nil
+}
+
+func (r *KeyRing) verifyingKey(kid string) (*signingKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	k, ok := r.keys[kid]
+	if !ok || k.Expired(time.Now().UTC().Unix()) {
+		return nil, ErrUnknownKey
+	}
+
+	return k, nil
+}
@@ -43,3 +43,10 @@ func WithRefreshTokenExpiry(expiry time.Duration) Option {
 		s.refreshTokenExpiry = expiry
 	}
 }
+
+// WithClaims sets where the roles and permissions of access tokens come from, tokens carry none without it.
+func WithClaims(fn ClaimsFunc) Option {
+	return func(s *jwtService) {
+		s.claims = fn
+	}
+}
@@ -0,0 +1,19 @@
+package jwt
+
+import "context"
+
+type KeyQuery interface {
+	FindKeys(ctx context.Context) ([]*Key, error)
+}
+
+type KeyCommand interface {
+	CreateKey(ctx context.Context, key *Key) error
+	// ExpireKeys sets the expiry of the keys created before createdBefore which have none
+	ExpireKeys(ctx context.Context, createdBefore, expiresAt int64) error
+	DeleteExpiredKeys(ctx context.Context, now int64) error
+}
+
+type KeyRepository interface {
+	KeyQuery
+	KeyCommand
+}
@@ -0,0 +1,116 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/heroticket/internal/service/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoRepository struct {
+	jwt.KeyQuery
+	jwt.KeyCommand
+	client *mongo.Client
+	dbname string
+}
+
+func New(ctx context.Context, client *mongo.Client, dbname string) (jwt.KeyRepository, error) {
+	cmd := NewMongoCommand(client, dbname)
+	repo := &mongoRepository{
+		KeyQuery:   NewMongoQuery(client, dbname),
+		KeyCommand: cmd,
+		client:     client,
+		dbname:     dbname,
+	}
+
+	_, err := cmd.collection().Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.M{"createdAt": 1},
+		},
+	)
+
+	return repo, err
+}
+
+type mongoQuery struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoQuery(client *mongo.Client, dbname string) jwt.KeyQuery {
+	return &mongoQuery{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (q *mongoQuery) FindKeys(ctx context.Context) ([]*jwt.Key, error) {
+	coll := q.collection()
+
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var keys []*jwt.Key
+
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (q *mongoQuery) collection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("keys")
+}
+
+type mongoCommand struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoCommand(client *mongo.Client, dbname string) *mongoCommand {
+	return &mongoCommand{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (c *mongoCommand) CreateKey(ctx context.Context, key *jwt.Key) error {
+	coll := c.collection()
+
+	_, err := coll.InsertOne(ctx, key)
+
+	return err
+}
+
+func (c *mongoCommand) ExpireKeys(ctx context.Context, createdBefore, expiresAt int64) error {
+	coll := c.collection()
+
+	filter := bson.M{
+		"createdAt": bson.M{"$lt": createdBefore},
+		"expiresAt": bson.M{"$exists": false},
+	}
+
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"expiresAt": expiresAt}})
+
+	return err
+}
+
+func (c *mongoCommand) DeleteExpiredKeys(ctx context.Context, now int64) error {
+	coll := c.collection()
+
+	filter := bson.M{"expiresAt": bson.M{"$exists": true, "$lte": now}}
+
+	_, err := coll.DeleteMany(ctx, filter)
+
+	return err
+}
+
+func (c *mongoCommand) collection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("keys")
+}
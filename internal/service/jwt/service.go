@@ -5,28 +5,42 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/heroticket/internal/cache"
 )
 
 type Service interface {
-	GenerateTokenPair(u JWTUser) (*TokenPair, error)
-	VerifyToken(token string, role TokenRole) (*JWTUser, error)
+	GenerateTokenPair(ctx context.Context, u JWTUser) (*TokenPair, error)
+	RefreshTokenPair(ctx context.Context, refreshToken string) (*TokenPair, error)
+	VerifyToken(ctx context.Context, token string, role TokenRole) (*JWTUser, error)
+	RevokeSession(ctx context.Context, u JWTUser) error
+	RevokeAllSessions(ctx context.Context, userID string) error
+	SuspendUser(ctx context.Context, userID string, until time.Time) error
+	ReinstateUser(ctx context.Context, userID string) error
+	JWKS() JWKS
 	NewContext(ctx context.Context, u JWTUser) context.Context
 	FromContext(ctx context.Context) (*JWTUser, error)
 }
 
 type jwtService struct {
+	// store keeps the sessions and the revoked tokens, it must not cache locally when several servers share it
+	store cache.Cache
+
+	// keys sign and verify every token, the token role is told apart by the "use" claim
+	keys *KeyRing
+
 	issuer             string
 	audience           string
-	accessTokenKey     string
-	refreshTokenKey    string
 	accessTokenExpiry  time.Duration
 	refreshTokenExpiry time.Duration
+
+	claims ClaimsFunc
 }
 
-func New(accessTokenKey, refreshTokenKey string, opts ...Option) Service {
+func New(store cache.Cache, keys *KeyRing, opts ...Option) Service {
 	svc := &jwtService{
-		accessTokenKey:  accessTokenKey,
-		refreshTokenKey: refreshTokenKey,
+		store: store,
+		keys:  keys,
 	}
 
 	WithDefaultOptions()(svc)
@@ -38,16 +52,60 @@ func New(accessTokenKey, refreshTokenKey string, opts ...Option) Service {
 	return svc
 }
 
-// GenerateTokenPair generates a pair of access and refresh tokens
-func (s *jwtService) GenerateTokenPair(u JWTUser) (*TokenPair, error) {
+// GenerateTokenPair starts a new session of the user and generates its first pair of access and refresh tokens
+func (s *jwtService) GenerateTokenPair(ctx context.Context, u JWTUser) (*TokenPair, error) {
+	if err := s.checkSuspended(ctx, u.ID); err != nil {
+		return nil, err
+	}
+
+	u.SessionID = uuid.NewString()
+	refreshID := uuid.NewString()
+
+	// save the session before handing out its refresh token
+	if err := s.saveSession(ctx, u.SessionID, session{UserID: u.ID, RefreshID: refreshID}); err != nil {
+		return nil, err
+	}
+
+	return s.generateTokenPair(ctx, u, refreshID)
+}
+
+// RefreshTokenPair rotates the refresh token of a session, the given token can't be used again
+func (s *jwtService) RefreshTokenPair(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	u, claims, err := s.verifyToken(ctx, refreshToken, TokenRoleRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshID, _ := claims["jti"].(string)
+	nextRefreshID := uuid.NewString()
+
+	if err := s.rotateSession(ctx, *u, refreshID, nextRefreshID); err != nil {
+		return nil, err
+	}
+
+	return s.generateTokenPair(ctx, *u, nextRefreshID)
+}
+
+// generateTokenPair generates a pair of access and refresh tokens for the session of the user,
+// the access token carrying the current roles and permissions of the user
+func (s *jwtService) generateTokenPair(ctx context.Context, u JWTUser, refreshID string) (*TokenPair, error) {
+	if s.claims != nil {
+		roles, perms, err := s.claims(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		u.Roles, u.Permissions = roles, perms
+	}
+
 	// generate access token
-	accessToken, err := s.generateToken(s.getAccessTokenClaims(u), s.accessTokenKey)
+	accessToken, err := s.generateToken(s.getAccessTokenClaims(u))
 	if err != nil {
 		return nil, err
 	}
 
 	// generate refresh token
-	refreshToken, err := s.generateToken(s.getRefreshTokenClaims(u), s.refreshTokenKey)
+	refreshToken, err := s.generateToken(s.getRefreshTokenClaims(u, refreshID))
 	if err != nil {
 		return nil, err
 	}
@@ -61,18 +119,51 @@ func (s *jwtService) GenerateTokenPair(u JWTUser) (*TokenPair, error) {
 	}, nil
 }
 
-// VerifyToken verifies a token and returns the user info if the token is valid
-func (s *jwtService) VerifyToken(tokenString string, role TokenRole) (*JWTUser, error) {
-	// get key function based on token role
-	fn, err := s.getKeyfunc(role)
+// VerifyToken verifies a token and returns the user info if the token is valid and not revoked
+func (s *jwtService) VerifyToken(ctx context.Context, tokenString string, role TokenRole) (*JWTUser, error) {
+	u, _, err := s.verifyToken(ctx, tokenString, role)
 	if err != nil {
 		return nil, err
 	}
 
+	return u, nil
+}
+
+// verifyToken verifies a token, checks it was not revoked nor its user suspended and returns the user info with the claims
+func (s *jwtService) verifyToken(ctx context.Context, tokenString string, role TokenRole) (*JWTUser, jwt.MapClaims, error) {
+	u, claims, err := s.parseToken(tokenString, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if err := s.checkRevoked(ctx, *u, iat.Unix()); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.checkSuspended(ctx, u.ID); err != nil {
+		return nil, nil, err
+	}
+
+	return u, claims, nil
+}
+
+// parseToken parses a token and returns the user info if the signature and claims are valid
+func (s *jwtService) parseToken(tokenString string, role TokenRole) (*JWTUser, jwt.MapClaims, error) {
+	// check token role
+	use := role.use()
+	if use == "" {
+		return nil, nil, ErrInvalidTokenRole
+	}
+
 	// parse token
-	token, err := jwt.Parse(tokenString, fn)
+	token, err := jwt.Parse(tokenString, s.keyfunc, jwt.WithValidMethods([]string{string(AlgorithmES256), string(AlgorithmEdDSA)}))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var u JWTUser
@@ -81,11 +172,15 @@ func (s *jwtService) VerifyToken(tokenString string, role TokenRole) (*JWTUser,
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		// check issuer and audience
 		if iss, ok := claims["iss"].(string); !ok || iss != s.issuer {
-			return nil, ErrInvalidToken
+			return nil, nil, ErrInvalidToken
 		}
 
 		if aud, ok := claims["aud"].(string); !ok || aud != s.audience {
-			return nil, ErrInvalidToken
+			return nil, nil, ErrInvalidToken
+		}
+
+		if v, ok := claims["use"].(string); !ok || v != use {
+			return nil, nil, ErrInvalidTokenRole
 		}
 
 		// check subject
@@ -93,12 +188,22 @@ func (s *jwtService) VerifyToken(tokenString string, role TokenRole) (*JWTUser,
 			u.ID = sub
 		}
 
+		// check session, tokens issued before sessions were tracked are not accepted
+		sid, ok := claims["sid"].(string)
+		if !ok || sid == "" {
+			return nil, nil, ErrInvalidToken
+		}
+
+		u.SessionID = sid
+		u.Roles = stringsClaim(claims, "roles")
+		u.Permissions = stringsClaim(claims, "perms")
+
 		// return jwt user
-		return &u, nil
+		return &u, claims, nil
 	}
 
 	// invalid token
-	return nil, ErrInvalidToken
+	return nil, nil, ErrInvalidToken
 }
 
 // NewContext returns a new context with the user info
@@ -116,13 +221,19 @@ func (s *jwtService) FromContext(ctx context.Context) (*JWTUser, error) {
 	return &u, nil
 }
 
-// generateToken generates a token
-func (s *jwtService) generateToken(claims jwt.MapClaims, key string) (string, error) {
+// generateToken generates a token signed by the active key of the key ring
+func (s *jwtService) generateToken(claims jwt.MapClaims) (string, error) {
+	key, err := s.keys.signingKey()
+	if err != nil {
+		return "", err
+	}
+
 	// create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
 
 	// sign token
-	signedToken, err := token.SignedString([]byte(key))
+	signedToken, err := token.SignedString(key.private)
 	if err != nil {
 		return "", err
 	}
@@ -131,52 +242,76 @@ func (s *jwtService) generateToken(claims jwt.MapClaims, key string) (string, er
 	return signedToken, nil
 }
 
-// getKeyfunc returns a key function based on token role
-func (s *jwtService) getKeyfunc(role TokenRole) (jwt.Keyfunc, error) {
-	var key string
+// keyfunc returns the public key named by the kid header of a token
+func (s *jwtService) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	key, err := s.keys.verifyingKey(kid)
+	if err != nil {
+		return nil, err
+	}
 
-	// get key based on token role
-	switch role {
-	case TokenRoleAccess:
-		key = s.accessTokenKey
-	case TokenRoleRefresh:
-		key = s.refreshTokenKey
-	default:
-		// invalid token role
-		return nil, ErrInvalidTokenRole
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidSigningMethod
 	}
 
-	// return key function
-	return func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSigningMethod
-		}
+	return key.public, nil
+}
 
-		return []byte(key), nil
-	}, nil
+// JWKS returns the public keys tokens can be verified with
+func (s *jwtService) JWKS() JWKS {
+	return s.keys.JWKS()
 }
 
 // getAccessTokenClaims returns the claims for access token
 func (s *jwtService) getAccessTokenClaims(u JWTUser) jwt.MapClaims {
 	// access token contains email claim
 	return jwt.MapClaims{
-		"iss": s.issuer,
-		"aud": s.audience,
-		"sub": u.ID,
-		"exp": time.Now().Add(s.accessTokenExpiry).UTC().Unix(),
-		"iat": time.Now().UTC().Unix(),
-		"typ": "JWT",
+		"iss":   s.issuer,
+		"aud":   s.audience,
+		"sub":   u.ID,
+		"sid":   u.SessionID,
+		"use":   TokenRoleAccess.use(),
+		"roles": u.Roles,
+		"perms": u.Permissions,
+		"exp":   time.Now().Add(s.accessTokenExpiry).UTC().Unix(),
+		"iat":   time.Now().UTC().Unix(),
+		"typ":   "JWT",
 	}
 }
 
 // getRefreshTokenClaims returns the claims for refresh token
-func (s *jwtService) getRefreshTokenClaims(u JWTUser) jwt.MapClaims {
-	// refresh token does not contain email claim
+func (s *jwtService) getRefreshTokenClaims(u JWTUser, refreshID string) jwt.MapClaims {
+	// refresh token does not contain email claim, its id is the one the session expects next
 	return jwt.MapClaims{
 		"iss": s.issuer,
 		"aud": s.audience,
 		"sub": u.ID,
+		"sid": u.SessionID,
+		"jti": refreshID,
+		"use": TokenRoleRefresh.use(),
 		"exp": time.Now().Add(s.refreshTokenExpiry).UTC().Unix(),
 		"iat": time.Now().UTC().Unix(),
 	}
 }
+
+// stringsClaim returns a claim holding a list of strings, nil when absent
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	values, ok := claims[name].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,413 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heroticket/internal/cache"
+)
+
+type memoryCache struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func (c *memoryCache) Exists(ctx context.Context, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.items[key]
+	return ok
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value interface{}, ttls ...time.Duration) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = b
+	return nil
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string, value interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.items[key]
+	if !ok {
+		return cache.ErrCacheMiss
+	}
+
+	return json.Unmarshal(b, value)
+}
+
+func (c *memoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+	return nil
+}
+
+type memoryKeyRepository struct {
+	mu   sync.Mutex
+	keys []*Key
+}
+
+func (r *memoryKeyRepository) FindKeys(ctx context.Context) ([]*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]*Key, len(r.keys))
+	for i, k := range r.keys {
+		key := *k
+		keys[i] = &key
+	}
+
+	return keys, nil
+}
+
+func (r *memoryKeyRepository) CreateKey(ctx context.Context, key *Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := *key
+	r.keys = append(r.keys, &k)
+	return nil
+}
+
+func (r *memoryKeyRepository) ExpireKeys(ctx context.Context, createdBefore, expiresAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.keys {
+		if k.CreatedAt < createdBefore && k.ExpiresAt == 0 {
+			k.ExpiresAt = expiresAt
+		}
+	}
+	return nil
+}
+
+func (r *memoryKeyRepository) DeleteExpiredKeys(ctx context.Context, now int64) error {
+	return nil
+}
+
+var testEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestKeyRing(t *testing.T, alg Algorithm, repo *memoryKeyRepository) *KeyRing {
+	t.Helper()
+
+	keys, err := NewKeyRing(context.Background(), KeyRingConfig{
+		Repo:          repo,
+		Algorithm:     alg,
+		EncryptionKey: testEncryptionKey,
+		PublishDelay:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keys.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func newTestService(t *testing.T) Service {
+	t.Helper()
+
+	keys := newTestKeyRing(t, AlgorithmES256, &memoryKeyRepository{})
+
+	return New(&memoryCache{items: make(map[string][]byte)}, keys)
+}
+
+func TestRefreshTokenPairRotates(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	pair, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := svc.RefreshTokenPair(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := svc.VerifyToken(ctx, next.AccessToken, TokenRoleAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.ID != "did:example:alice" || u.SessionID == "" {
+		t.Fatalf("unexpected user %+v", u)
+	}
+
+	if _, err := svc.RefreshTokenPair(ctx, next.RefreshToken); err != nil {
+		t.Fatalf("latest refresh token rejected: %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	pair, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := svc.RefreshTokenPair(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.RefreshTokenPair(ctx, pair.RefreshToken); err != ErrTokenReused {
+		t.Fatalf("want ErrTokenReused, got %v", err)
+	}
+
+	if _, err := svc.RefreshTokenPair(ctx, next.RefreshToken); err != ErrTokenRevoked {
+		t.Fatalf("want ErrTokenRevoked for the rotated token, got %v", err)
+	}
+
+	if _, err := svc.VerifyToken(ctx, next.AccessToken, TokenRoleAccess); err != ErrTokenRevoked {
+		t.Fatalf("want ErrTokenRevoked for the access token, got %v", err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	pair, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := svc.VerifyToken(ctx, pair.AccessToken, TokenRoleAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.RevokeSession(ctx, *u); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.VerifyToken(ctx, pair.AccessToken, TokenRoleAccess); err != ErrTokenRevoked {
+		t.Fatalf("want ErrTokenRevoked, got %v", err)
+	}
+
+	if _, err := svc.RefreshTokenPair(ctx, pair.RefreshToken); err != ErrTokenRevoked {
+		t.Fatalf("want ErrTokenRevoked, got %v", err)
+	}
+
+	if _, err := svc.VerifyToken(ctx, other.AccessToken, TokenRoleAccess); err != nil {
+		t.Fatalf("other session revoked: %v", err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	pair, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.RevokeAllSessions(ctx, "did:example:alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.VerifyToken(ctx, pair.AccessToken, TokenRoleAccess); err != ErrTokenRevoked {
+		t.Fatalf("want ErrTokenRevoked, got %v", err)
+	}
+
+	if _, err := svc.RefreshTokenPair(ctx, pair.RefreshToken); err != ErrTokenRevoked {
+		t.Fatalf("want ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestSuspendUser(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	pair, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.SuspendUser(ctx, "did:example:alice", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.VerifyToken(ctx, pair.AccessToken, TokenRoleAccess); err != ErrUserSuspended {
+		t.Fatalf("want ErrUserSuspended, got %v", err)
+	}
+
+	if _, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"}); err != ErrUserSuspended {
+		t.Fatalf("want ErrUserSuspended, got %v", err)
+	}
+
+	if err := svc.ReinstateUser(ctx, "did:example:alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.VerifyToken(ctx, pair.AccessToken, TokenRoleAccess); err != nil {
+		t.Fatalf("want reinstated token to verify, got %v", err)
+	}
+}
+
+func TestAccessAndRefreshTokensAreNotInterchangeable(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	pair, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.VerifyToken(ctx, pair.RefreshToken, TokenRoleAccess); err != ErrInvalidTokenRole {
+		t.Fatalf("want ErrInvalidTokenRole, got %v", err)
+	}
+
+	if _, err := svc.RefreshTokenPair(ctx, pair.AccessToken); err != ErrInvalidTokenRole {
+		t.Fatalf("want ErrInvalidTokenRole, got %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	for _, alg := range []Algorithm{AlgorithmES256, AlgorithmEdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			ctx := context.Background()
+			repo := &memoryKeyRepository{}
+			keys := newTestKeyRing(t, alg, repo)
+			svc := New(&memoryCache{items: make(map[string][]byte)}, keys)
+
+			before, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next, err := keys.Rotate(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the new key is published but the old one signs until the publish delay passed
+			if n := len(svc.JWKS().Keys); n != 2 {
+				t.Fatalf("want 2 published keys, got %d", n)
+			}
+
+			if k, _ := keys.signingKey(); k.ID == next.ID {
+				t.Fatal("pending key signs")
+			}
+
+			repo.keys[0].ActiveAt = time.Now().Unix() - 60
+			repo.keys[1].ActiveAt = time.Now().Unix()
+			if err := keys.Load(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if k, _ := keys.signingKey(); k.ID != next.ID {
+				t.Fatal("active key does not sign")
+			}
+
+			after, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// tokens of the replaced key verify during the grace period
+			if _, err := svc.VerifyToken(ctx, before.AccessToken, TokenRoleAccess); err != nil {
+				t.Fatalf("token of replaced key rejected: %v", err)
+			}
+
+			repo.keys[0].ExpiresAt = time.Now().Unix() - 1
+			if err := keys.Load(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := svc.VerifyToken(ctx, before.AccessToken, TokenRoleAccess); !errors.Is(err, ErrUnknownKey) {
+				t.Fatalf("want ErrUnknownKey, got %v", err)
+			}
+
+			if _, err := svc.VerifyToken(ctx, after.AccessToken, TokenRoleAccess); err != nil {
+				t.Fatal(err)
+			}
+
+			if n := len(svc.JWKS().Keys); n != 1 {
+				t.Fatalf("want 1 published key, got %d", n)
+			}
+		})
+	}
+}
+
+func TestKeysAreSealedAtRest(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryKeyRepository{}
+	keys := newTestKeyRing(t, AlgorithmES256, repo)
+	svc := New(&memoryCache{items: make(map[string][]byte)}, keys)
+
+	stored := repo.keys[0].PrivateKey
+	if !strings.HasPrefix(stored, sealedPrefix) || strings.Contains(stored, "PRIVATE KEY") {
+		t.Fatalf("private key stored in the clear: %q", stored)
+	}
+
+	pair, err := svc.GenerateTokenPair(ctx, JWTUser{ID: "did:example:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a ring with another encryption key can't open the key
+	other, err := NewKeyRing(ctx, KeyRingConfig{Repo: repo, EncryptionKey: []byte("fedcba9876543210fedcba9876543210")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(other.Keys()); n != 0 {
+		t.Fatalf("want no key opened with another encryption key, got %d", n)
+	}
+
+	// keys stored before sealing still load
+	plain, err := GenerateKey(AlgorithmES256, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.CreateKey(ctx, plain); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keys.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(keys.Keys()); n != 2 {
+		t.Fatalf("want 2 keys, got %d", n)
+	}
+
+	if _, err := svc.VerifyToken(ctx, pair.AccessToken, TokenRoleAccess); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeyRing(ctx, KeyRingConfig{Repo: repo, EncryptionKey: []byte("short")}); err != ErrInvalidEncryptionKey {
+		t.Fatalf("want ErrInvalidEncryptionKey, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroticket/internal/cache"
+)
+
+// A session is the family of refresh tokens issued for one login. Only the latest refresh token of a
+// session is valid, presenting an earlier one means it leaked and revokes the whole session.
+type session struct {
+	UserID    string `json:"userId"`
+	RefreshID string `json:"refreshId"`
+}
+
+func sessionKey(sessionID string) string {
+	return "jwt:session:" + sessionID
+}
+
+func revokedSessionKey(sessionID string) string {
+	return "jwt:revoked:session:" + sessionID
+}
+
+// revokedUserKey holds the time every token of the user issued up to was revoked at
+func revokedUserKey(userID string) string {
+	return "jwt:revoked:user:" + userID
+}
+
+// suspendedUserKey marks a suspended user for as long as tokens issued before the suspension live,
+// issuing new ones is refused by the ClaimsFunc
+func suspendedUserKey(userID string) string {
+	return "jwt:suspended:user:" + userID
+}
+
+// saveSession stores the refresh token of the session that is valid now, for as long as it lives.
+func (s *jwtService) saveSession(ctx context.Context, sessionID string, sess session) error {
+	return s.store.Set(ctx, sessionKey(sessionID), sess, s.refreshTokenExpiry)
+}
+
+// rotateSession replaces the refresh token of a session. Two rotations racing with the same token
+// both succeed, the pair of the loser is then reported as reused on its next refresh.
+func (s *jwtService) rotateSession(ctx context.Context, u JWTUser, refreshID, nextRefreshID string) error {
+	var sess session
+
+	if err := s.store.Get(ctx, sessionKey(u.SessionID), &sess); err != nil {
+		if err == cache.ErrCacheMiss {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+
+	if sess.UserID != u.ID {
+		return ErrInvalidToken
+	}
+
+	if sess.RefreshID != refreshID {
+		if err := s.RevokeSession(ctx, u); err != nil {
+			return err
+		}
+		return ErrTokenReused
+	}
+
+	sess.RefreshID = nextRefreshID
+
+	return s.saveSession(ctx, u.SessionID, sess)
+}
+
+// checkRevoked returns ErrTokenRevoked when the session of the token or every session of its user
+// was revoked after the token was issued.
+func (s *jwtService) checkRevoked(ctx context.Context, u JWTUser, issuedAt int64) error {
+	if s.store.Exists(ctx, revokedSessionKey(u.SessionID)) {
+		return ErrTokenRevoked
+	}
+
+	var revokedAt int64
+
+	err := s.store.Get(ctx, revokedUserKey(u.ID), &revokedAt)
+	if err != nil && err != cache.ErrCacheMiss {
+		return err
+	}
+
+	if err == nil && issuedAt <= revokedAt {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// RevokeSession logs out the session of u, its access and refresh tokens are rejected from now on.
+func (s *jwtService) RevokeSession(ctx context.Context, u JWTUser) error {
+	if u.SessionID == "" {
+		return ErrInvalidToken
+	}
+
+	if err := s.store.Set(ctx, revokedSessionKey(u.SessionID), true, s.refreshTokenExpiry); err != nil {
+		return err
+	}
+
+	return s.store.Delete(ctx, sessionKey(u.SessionID))
+}
+
+// RevokeAllSessions logs out every session of the user, tokens issued up to now are rejected.
+func (s *jwtService) RevokeAllSessions(ctx context.Context, userID string) error {
+	return s.store.Set(ctx, revokedUserKey(userID), time.Now().UTC().Unix(), s.refreshTokenExpiry)
+}
+
+// checkSuspended returns ErrUserSuspended while the user is suspended.
+func (s *jwtService) checkSuspended(ctx context.Context, userID string) error {
+	if s.store.Exists(ctx, suspendedUserKey(userID)) {
+		return ErrUserSuspended
+	}
+
+	return nil
+}
+
+// SuspendUser rejects every token of the user until the time, until a zero time for a ban. Once the
+// tokens issued before the suspension expired, the ClaimsFunc alone keeps the user out.
+func (s *jwtService) SuspendUser(ctx context.Context, userID string, until time.Time) error {
+	ttl := s.refreshTokenExpiry
+
+	if !until.IsZero() {
+		ttl = min(ttl, time.Until(until))
+	}
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.store.Set(ctx, suspendedUserKey(userID), until.Unix(), ttl)
+}
+
+// ReinstateUser lifts a suspension, tokens issued before it are accepted again while they live.
+func (s *jwtService) ReinstateUser(ctx context.Context, userID string) error {
+	return s.store.Delete(ctx, suspendedUserKey(userID))
+}
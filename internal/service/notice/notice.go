@@ -2,38 +2,165 @@ package notice
 
 import (
 	"errors"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/heroticket/internal/pagination"
 )
 
 var (
-	ErrNotFound        = errors.New("notice not found")
-	ErrNothingToUpdate = errors.New("nothing to update")
+	ErrNotFound         = errors.New("notice not found")
+	ErrNothingToUpdate  = errors.New("nothing to update")
+	ErrInvalidTitle     = errors.New("title must be 1 to 200 characters")
+	ErrInvalidContent   = errors.New("content must be 1 to 20000 characters")
+	ErrInvalidStatus    = errors.New("status must be draft or published")
+	ErrInvalidCategory  = errors.New("category must be up to 32 lowercase letters, digits or dashes")
+	ErrInvalidPublishAt = errors.New("invalid publish time")
+)
+
+const (
+	MaxTitleLength   = 200
+	MaxContentLength = 20000
 )
 
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusPublished Status = "published"
+)
+
+func (s Status) Valid() bool {
+	return s == StatusDraft || s == StatusPublished
+}
+
+var categoryPattern = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
 type Notice struct {
-	ID        int64  `json:"id" bson:"_id"`
-	Title     string `json:"title" bson:"title"`
-	Content   string `json:"content" bson:"content"`
+	ID      int64  `json:"id" bson:"_id"`
+	Title   string `json:"title" bson:"title"`
+	Content string `json:"content" bson:"content"`
+	// Status is empty for notices created before drafts, they are published
+	Status Status `json:"status" bson:"status,omitempty"`
+	// PublishAt is when a published notice becomes public, it is set to the publish time when not scheduled
+	PublishAt int64  `json:"publishAt" bson:"publishAt,omitempty"`
+	Pinned    bool   `json:"pinned" bson:"pinned"`
+	Category  string `json:"category,omitempty" bson:"category,omitempty"`
+	AuthorID  string `json:"authorId,omitempty" bson:"authorId,omitempty"`
 	CreatedAt int64  `json:"createdAt" bson:"createdAt"`
 	UpdatedAt int64  `json:"updatedAt" bson:"updatedAt"`
 }
 
+// Visible reports whether the notice is public at the unix time now.
+func (n *Notice) Visible(now int64) bool {
+	return n.Status != StatusDraft && n.PublishAt <= now
+}
+
+type CreateNoticeParams struct {
+	Title     string
+	Content   string
+	Status    Status
+	PublishAt int64
+	Pinned    bool
+	Category  string
+	AuthorID  string
+}
+
+func (p CreateNoticeParams) Validate() error {
+	if err := validateTitle(p.Title); err != nil {
+		return err
+	}
+
+	if err := validateContent(p.Content); err != nil {
+		return err
+	}
+
+	if !p.Status.Valid() {
+		return ErrInvalidStatus
+	}
+
+	if p.PublishAt < 0 {
+		return ErrInvalidPublishAt
+	}
+
+	return validateCategory(p.Category)
+}
+
+// NoticeUpdateParams changes the fields which are not nil. An empty Category removes the category.
 type NoticeUpdateParams struct {
-	ID      int64
-	Title   string
-	Content string
+	ID        int64
+	Title     *string
+	Content   *string
+	Status    *Status
+	PublishAt *int64
+	Pinned    *bool
+	Category  *string
 }
 
-type Pagination struct {
-	Total       int64 `json:"total"`
-	Pages       int64 `json:"pages"`
-	CurrentPage int64 `json:"currentPage"`
-	Limit       int64 `json:"limit"`
-	Start       int64 `json:"start"`
-	End         int64 `json:"end"`
-	HasNext     bool  `json:"hasNext"`
-	HasPrev     bool  `json:"hasPrev"`
+func (p *NoticeUpdateParams) Validate() error {
+	if p.Title == nil && p.Content == nil && p.Status == nil && p.PublishAt == nil && p.Pinned == nil && p.Category == nil {
+		return ErrNothingToUpdate
+	}
+
+	if p.Title != nil {
+		if err := validateTitle(*p.Title); err != nil {
+			return err
+		}
+	}
+
+	if p.Content != nil {
+		if err := validateContent(*p.Content); err != nil {
+			return err
+		}
+	}
+
+	if p.Status != nil && !p.Status.Valid() {
+		return ErrInvalidStatus
+	}
+
+	if p.PublishAt != nil && *p.PublishAt < 0 {
+		return ErrInvalidPublishAt
+	}
+
+	if p.Category != nil {
+		return validateCategory(*p.Category)
+	}
+
+	return nil
 }
 
+func validateTitle(title string) error {
+	if n := utf8.RuneCountInString(title); n == 0 || n > MaxTitleLength {
+		return ErrInvalidTitle
+	}
+	return nil
+}
+
+func validateContent(content string) error {
+	if n := utf8.RuneCountInString(content); n == 0 || n > MaxContentLength {
+		return ErrInvalidContent
+	}
+	return nil
+}
+
+func validateCategory(category string) error {
+	if category != "" && !categoryPattern.MatchString(category) {
+		return ErrInvalidCategory
+	}
+	return nil
+}
+
+type NoticeFilter struct {
+	Category string
+	Status   Status
+	// VisibleAt keeps only the notices public at the unix time, all when 0
+	VisibleAt int64
+	Page      int64
+	Limit     int64
+}
+
+type Pagination = pagination.Pagination
+
 type Notices struct {
 	Items      []*Notice   `json:"items"`
 	Pagination *Pagination `json:"pagination"`
@@ -9,8 +9,8 @@ type Command interface {
 }
 
 type Query interface {
-	GetNotice(ctx context.Context, id string) (*Notice, error)
-	GetNotices(ctx context.Context, page, limit int64) (*Notices, error)
+	GetNotice(ctx context.Context, id int64) (*Notice, error)
+	GetNotices(ctx context.Context, filter NoticeFilter) (*Notices, error)
 }
 
 type Repository interface {
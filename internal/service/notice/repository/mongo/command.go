@@ -4,41 +4,35 @@ import (
 	"context"
 	"time"
 
+	"github.com/heroticket/internal/db"
 	"github.com/heroticket/internal/service/notice"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoCommand struct {
 	client *mongo.Client
 	dbname string
+	seq    db.Sequence
 }
 
-func NewCommand(client *mongo.Client, dbname string) notice.Command {
+func NewCommand(client *mongo.Client, dbname string, seq db.Sequence) *mongoCommand {
 	return &mongoCommand{
 		client: client,
 		dbname: dbname,
+		seq:    seq,
 	}
 }
 
 func (c *mongoCommand) CreateNotice(ctx context.Context, n *notice.Notice) (*notice.Notice, error) {
 	coll := c.collection()
 
-	// find last inserted id
-	var last notice.Notice
-	opts := options.FindOne().SetSort(primitive.D{{Key: "_id", Value: -1}})
-	err := coll.FindOne(ctx, primitive.M{}, opts).Decode(&last)
-	if err != nil && err != mongo.ErrNoDocuments {
+	id, err := c.seq.Next(ctx, sequenceName)
+	if err != nil {
 		return nil, err
 	}
 
-	// set id
-	if err == mongo.ErrNoDocuments {
-		n.ID = 1
-	} else {
-		n.ID = last.ID + 1
-	}
+	n.ID = id
 
 	n.CreatedAt = time.Now().Unix()
 	n.UpdatedAt = time.Now().Unix()
@@ -73,27 +67,46 @@ func (c *mongoCommand) UpdateNotice(ctx context.Context, params *notice.NoticeUp
 
 	filter := primitive.M{"_id": params.ID}
 
-	value := primitive.D{}
+	set := primitive.D{}
+	unset := primitive.D{}
 
-	if params.Title == "" && params.Content == "" {
-		return notice.ErrNothingToUpdate
+	if params.Title != nil {
+		set = append(set, primitive.E{Key: "title", Value: *params.Title})
+	}
+
+	if params.Content != nil {
+		set = append(set, primitive.E{Key: "content", Value: *params.Content})
+	}
+
+	if params.Status != nil {
+		set = append(set, primitive.E{Key: "status", Value: *params.Status})
+	}
+
+	if params.PublishAt != nil {
+		set = append(set, primitive.E{Key: "publishAt", Value: *params.PublishAt})
 	}
 
-	if params.Title != "" {
-		value = append(value, primitive.E{Key: "title", Value: params.Title})
+	if params.Pinned != nil {
+		set = append(set, primitive.E{Key: "pinned", Value: *params.Pinned})
 	}
 
-	if params.Content != "" {
-		value = append(value, primitive.E{Key: "content", Value: params.Content})
+	if params.Category != nil {
+		if *params.Category == "" {
+			unset = append(unset, primitive.E{Key: "category", Value: ""})
+		} else {
+			set = append(set, primitive.E{Key: "category", Value: *params.Category})
+		}
+	}
+
+	if len(set) == 0 && len(unset) == 0 {
+		return notice.ErrNothingToUpdate
 	}
 
-	value = append(value, primitive.E{Key: "updated_at", Value: time.Now().Unix()})
+	set = append(set, primitive.E{Key: "updatedAt", Value: time.Now().Unix()})
 
-	update := primitive.D{
-		{
-			Key:   "$set",
-			Value: value,
-		},
+	update := primitive.D{{Key: "$set", Value: set}}
+	if len(unset) > 0 {
+		update = append(update, primitive.E{Key: "$unset", Value: unset})
 	}
 
 	result, err := coll.UpdateOne(ctx, filter, update)
@@ -101,7 +114,7 @@ func (c *mongoCommand) UpdateNotice(ctx context.Context, params *notice.NoticeUp
 		return err
 	}
 
-	if result.ModifiedCount == 0 {
+	if result.MatchedCount == 0 {
 		return notice.ErrNotFound
 	}
 
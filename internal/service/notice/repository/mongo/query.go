@@ -2,9 +2,8 @@ package mongo
 
 import (
 	"context"
-	"math"
-	"strconv"
 
+	"github.com/heroticket/internal/pagination"
 	"github.com/heroticket/internal/service/notice"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -23,35 +22,52 @@ func NewQuery(client *mongo.Client, dbname string) notice.Query {
 	}
 }
 
-func (q *mongoQuery) GetNotice(ctx context.Context, id string) (*notice.Notice, error) {
+func (q *mongoQuery) GetNotice(ctx context.Context, id int64) (*notice.Notice, error) {
 	coll := q.collection()
 
-	idInt, err := strconv.ParseInt(id, 10, 64)
-	if err != nil {
-		return nil, err
-	}
-
-	filter := primitive.M{"_id": idInt}
+	filter := primitive.M{"_id": id}
 
 	var n notice.Notice
 
-	err = coll.FindOne(ctx, filter).Decode(&n)
+	err := coll.FindOne(ctx, filter).Decode(&n)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notice.ErrNotFound
+		}
 		return nil, err
 	}
 
 	return &n, nil
 }
 
-func (q *mongoQuery) GetNotices(ctx context.Context, page, limit int64) (*notice.Notices, error) {
+func (q *mongoQuery) GetNotices(ctx context.Context, f notice.NoticeFilter) (*notice.Notices, error) {
 	coll := q.collection()
 
-	total, err := coll.CountDocuments(ctx, primitive.M{})
+	filter := primitive.M{}
+
+	if f.Category != "" {
+		filter["category"] = f.Category
+	}
+
+	// notices without a status predate drafts and are published
+	switch f.Status {
+	case notice.StatusDraft:
+		filter["status"] = notice.StatusDraft
+	case notice.StatusPublished:
+		filter["status"] = primitive.M{"$ne": notice.StatusDraft}
+	}
+
+	if f.VisibleAt > 0 {
+		filter["status"] = primitive.M{"$ne": notice.StatusDraft}
+		filter["publishAt"] = primitive.M{"$not": primitive.M{"$gt": f.VisibleAt}}
+	}
+
+	total, err := coll.CountDocuments(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	pagination := q.pagination(total, page, limit)
+	pagination := pagination.New(total, f.Page, f.Limit)
 
 	if total == 0 {
 		return &notice.Notices{
@@ -60,15 +76,19 @@ func (q *mongoQuery) GetNotices(ctx context.Context, page, limit int64) (*notice
 		}, nil
 	}
 
-	skip := (pagination.CurrentPage - 1) * pagination.Limit
+	skip := pagination.Skip()
 
 	findOptions := &options.FindOptions{
 		Skip:  &skip,
 		Limit: &pagination.Limit,
-		Sort:  primitive.D{{Key: "_id", Value: -1}},
+		Sort: primitive.D{
+			{Key: "pinned", Value: -1},
+			{Key: "publishAt", Value: -1},
+			{Key: "_id", Value: -1},
+		},
 	}
 
-	cursor, err := coll.Find(ctx, primitive.M{}, findOptions)
+	cursor, err := coll.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -95,45 +115,3 @@ func (q *mongoQuery) GetNotices(ctx context.Context, page, limit int64) (*notice
 func (q *mongoQuery) collection() *mongo.Collection {
 	return q.client.Database(q.dbname).Collection("notices")
 }
-
-func (q *mongoQuery) pagination(total, page, limit int64) *notice.Pagination {
-	if page < 1 {
-		page = 1
-	}
-
-	if limit < 1 {
-		limit = 1
-	}
-
-	if limit > 20 {
-		limit = 20
-	}
-
-	pages := int64(math.Ceil(float64(total) / float64(limit)))
-
-	if page > pages {
-		page = pages
-	}
-
-	// 2 pages before and after the current page
-	start := max(1, page-2)
-	end := min(pages, page+2)
-
-	if end < start {
-		end = start
-	}
-
-	hasNext := page < pages
-	hasPrev := page > 1
-
-	return &notice.Pagination{
-		Total:       total,
-		Pages:       pages,
-		CurrentPage: page,
-		Limit:       limit,
-		Start:       start,
-		End:         end,
-		HasNext:     hasNext,
-		HasPrev:     hasPrev,
-	}
-}
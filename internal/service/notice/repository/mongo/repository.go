@@ -1,10 +1,18 @@
 package mongo
 
 import (
+	"context"
+
+	dbmongo "github.com/heroticket/internal/db/mongo"
 	"github.com/heroticket/internal/service/notice"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// sequenceName names the notice id sequence in the counters collection
+const sequenceName = "notices"
+
 type mongoRepository struct {
 	notice.Command
 	notice.Query
@@ -12,11 +20,26 @@ type mongoRepository struct {
 	dbname string
 }
 
-func New(client *mongo.Client, dbname string) notice.Repository {
+func New(ctx context.Context, client *mongo.Client, dbname string) (notice.Repository, error) {
+	seq := dbmongo.NewSequence(client, dbname)
+	cmd := NewCommand(client, dbname, seq)
+
+	// continue the ids assigned before the sequence existed
+	var last notice.Notice
+	opts := options.FindOne().SetSort(primitive.D{{Key: "_id", Value: -1}})
+	err := cmd.collection().FindOne(ctx, primitive.M{}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	if err := seq.AtLeast(ctx, sequenceName, last.ID); err != nil {
+		return nil, err
+	}
+
 	return &mongoRepository{
-		Command: NewCommand(client, dbname),
+		Command: cmd,
 		Query:   NewQuery(client, dbname),
 		client:  client,
 		dbname:  dbname,
-	}
+	}, nil
 }
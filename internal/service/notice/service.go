@@ -1,12 +1,18 @@
 package notice
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Service interface {
-	CreateNotice(ctx context.Context, n *Notice) (*Notice, error)
-	GetNotice(ctx context.Context, id string) (*Notice, error)
-	GetNotices(ctx context.Context, page, limit int64) (*Notices, error)
-	UpdateNotice(ctx context.Context, params *NoticeUpdateParams) error
+	CreateNotice(ctx context.Context, params CreateNoticeParams) (*Notice, error)
+	// GetNotice returns a notice whatever its status, GetPublishedNotice only a public one
+	GetNotice(ctx context.Context, id int64) (*Notice, error)
+	GetPublishedNotice(ctx context.Context, id int64) (*Notice, error)
+	GetNotices(ctx context.Context, filter NoticeFilter) (*Notices, error)
+	GetPublishedNotices(ctx context.Context, category string, page, limit int64) (*Notices, error)
+	UpdateNotice(ctx context.Context, params *NoticeUpdateParams) (*Notice, error)
 	DeleteNotice(ctx context.Context, id int64) error
 }
 
@@ -18,7 +24,30 @@ func New(repo Repository) Service {
 	return &noticeService{repo: repo}
 }
 
-func (svc *noticeService) CreateNotice(ctx context.Context, n *Notice) (*Notice, error) {
+func (svc *noticeService) CreateNotice(ctx context.Context, params CreateNoticeParams) (*Notice, error) {
+	if params.Status == "" {
+		params.Status = StatusDraft
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := &Notice{
+		Title:     params.Title,
+		Content:   params.Content,
+		Status:    params.Status,
+		PublishAt: params.PublishAt,
+		Pinned:    params.Pinned,
+		Category:  params.Category,
+		AuthorID:  params.AuthorID,
+	}
+
+	// a notice published without a schedule is public from now on
+	if n.Status == StatusPublished && n.PublishAt == 0 {
+		n.PublishAt = time.Now().Unix()
+	}
+
 	return svc.repo.CreateNotice(ctx, n)
 }
 
@@ -26,14 +55,57 @@ func (svc *noticeService) DeleteNotice(ctx context.Context, id int64) error {
 	return svc.repo.DeleteNotice(ctx, id)
 }
 
-func (svc *noticeService) GetNotice(ctx context.Context, id string) (*Notice, error) {
+func (svc *noticeService) GetNotice(ctx context.Context, id int64) (*Notice, error) {
 	return svc.repo.GetNotice(ctx, id)
 }
 
-func (svc *noticeService) GetNotices(ctx context.Context, page int64, limit int64) (*Notices, error) {
-	return svc.repo.GetNotices(ctx, page, limit)
+func (svc *noticeService) GetPublishedNotice(ctx context.Context, id int64) (*Notice, error) {
+	n, err := svc.repo.GetNotice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !n.Visible(time.Now().Unix()) {
+		return nil, ErrNotFound
+	}
+
+	return n, nil
+}
+
+func (svc *noticeService) GetNotices(ctx context.Context, filter NoticeFilter) (*Notices, error) {
+	return svc.repo.GetNotices(ctx, filter)
+}
+
+func (svc *noticeService) GetPublishedNotices(ctx context.Context, category string, page, limit int64) (*Notices, error) {
+	return svc.repo.GetNotices(ctx, NoticeFilter{
+		Category:  category,
+		VisibleAt: time.Now().Unix(),
+		Page:      page,
+		Limit:     limit,
+	})
 }
 
-func (svc *noticeService) UpdateNotice(ctx context.Context, params *NoticeUpdateParams) error {
-	return svc.repo.UpdateNotice(ctx, params)
+func (svc *noticeService) UpdateNotice(ctx context.Context, params *NoticeUpdateParams) (*Notice, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	// publishing a draft without a schedule makes it public from now on
+	if params.Status != nil && *params.Status == StatusPublished && params.PublishAt == nil {
+		n, err := svc.repo.GetNotice(ctx, params.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.Status == StatusDraft && n.PublishAt == 0 {
+			now := time.Now().Unix()
+			params.PublishAt = &now
+		}
+	}
+
+	if err := svc.repo.UpdateNotice(ctx, params); err != nil {
+		return nil, err
+	}
+
+	return svc.repo.GetNotice(ctx, params.ID)
 }
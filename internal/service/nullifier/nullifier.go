@@ -0,0 +1,29 @@
+package nullifier
+
+import "errors"
+
+var (
+	ErrNullifierNotFound = errors.New("nullifier not found")
+	ErrNullifierUsed     = errors.New("nullifier already used by another account")
+	ErrEmptyValue        = errors.New("empty personhood value")
+)
+
+// Nullifier marks that a person authorized a purchase of a ticket collection. ID is a keyed hash of
+// the collection and the person's disclosed value, so records of different collections do not link.
+type Nullifier struct {
+	ID              string `json:"id" bson:"_id"`
+	ChainID         int64  `json:"chainId" bson:"chainId"`
+	ContractAddress string `json:"contractAddress" bson:"contractAddress"`
+	UserID          string `json:"userId" bson:"userId"`
+	AccountAddress  string `json:"accountAddress" bson:"accountAddress"`
+	CreatedAt       int64  `json:"createdAt" bson:"createdAt"`
+}
+
+type UseParams struct {
+	ChainID         int64
+	ContractAddress string
+	// Value is the personhood value the holder disclosed
+	Value          string
+	UserID         string
+	AccountAddress string
+}
@@ -0,0 +1,18 @@
+package nullifier
+
+import "context"
+
+type Query interface {
+	FindNullifierByID(ctx context.Context, id string) (*Nullifier, error)
+}
+
+type Command interface {
+	// CreateNullifier inserts n, returning ErrNullifierUsed when its id is taken
+	CreateNullifier(ctx context.Context, n *Nullifier) error
+	ReassignUser(ctx context.Context, fromUserID, toUserID string) error
+}
+
+type Repository interface {
+	Query
+	Command
+}
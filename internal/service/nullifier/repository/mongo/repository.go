@@ -0,0 +1,105 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/heroticket/internal/service/nullifier"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoRepository struct {
+	nullifier.Query
+	nullifier.Command
+	client *mongo.Client
+	dbname string
+}
+
+func New(ctx context.Context, client *mongo.Client, dbname string) (nullifier.Repository, error) {
+	cmd := NewMongoCommand(client, dbname)
+	repo := &mongoRepository{
+		Query:   NewMongoQuery(client, dbname),
+		Command: cmd,
+		client:  client,
+		dbname:  dbname,
+	}
+
+	_, err := cmd.collection().Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "chainId", Value: 1}, {Key: "contractAddress", Value: 1}},
+		},
+	)
+
+	return repo, err
+}
+
+type mongoQuery struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoQuery(client *mongo.Client, dbname string) nullifier.Query {
+	return &mongoQuery{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (q *mongoQuery) FindNullifierByID(ctx context.Context, id string) (*nullifier.Nullifier, error) {
+	coll := q.collection()
+
+	filter := bson.M{"_id": id}
+
+	var n nullifier.Nullifier
+
+	if err := coll.FindOne(ctx, filter).Decode(&n); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nullifier.ErrNullifierNotFound
+		}
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (q *mongoQuery) collection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("nullifiers")
+}
+
+type mongoCommand struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoCommand(client *mongo.Client, dbname string) *mongoCommand {
+	return &mongoCommand{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (c *mongoCommand) CreateNullifier(ctx context.Context, n *nullifier.Nullifier) error {
+	coll := c.collection()
+
+	if _, err := coll.InsertOne(ctx, n); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nullifier.ErrNullifierUsed
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (c *mongoCommand) ReassignUser(ctx context.Context, fromUserID, toUserID string) error {
+	coll := c.collection()
+
+	_, err := coll.UpdateMany(ctx, bson.M{"userId": fromUserID}, bson.M{"$set": bson.M{"userId": toUserID}})
+
+	return err
+}
+
+func (c *mongoCommand) collection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("nullifiers")
+}
@@ -0,0 +1,91 @@
+package nullifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Service interface {
+	Use(ctx context.Context, params UseParams) (*Nullifier, error)
+	ReassignUser(ctx context.Context, fromUserID, toUserID string) error
+}
+
+type NullifierServiceConfig struct {
+	Repo Repository
+	// Secret keys the nullifier hash, so stored nullifiers cannot be matched against guessed values
+	Secret string
+}
+
+type nullifierService struct {
+	repo   Repository
+	secret []byte
+}
+
+func New(cfg NullifierServiceConfig) Service {
+	return &nullifierService{
+		repo:   cfg.Repo,
+		secret: []byte(cfg.Secret),
+	}
+}
+
+// Use records the nullifier of a person for a collection, bound to the account being whitelisted.
+// The same account may use it again, so retried callbacks pass; any other account gets ErrNullifierUsed,
+// including other linked wallets of the same user.
+func (s *nullifierService) Use(ctx context.Context, params UseParams) (*Nullifier, error) {
+	if params.Value == "" {
+		return nil, ErrEmptyValue
+	}
+
+	n := &Nullifier{
+		ID:              s.derive(params.ChainID, params.ContractAddress, params.Value),
+		ChainID:         params.ChainID,
+		ContractAddress: strings.ToLower(params.ContractAddress),
+		UserID:          params.UserID,
+		AccountAddress:  strings.ToLower(params.AccountAddress),
+		CreatedAt:       time.Now().Unix(),
+	}
+
+	err := s.repo.CreateNullifier(ctx, n)
+	if err == nil {
+		return n, nil
+	}
+
+	if err != ErrNullifierUsed {
+		return nil, err
+	}
+
+	used, err := s.repo.FindNullifierByID(ctx, n.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if used.UserID != params.UserID || used.AccountAddress != n.AccountAddress {
+		return nil, ErrNullifierUsed
+	}
+
+	return used, nil
+}
+
+// ReassignUser moves the nullifiers of a user to the identity their wallet was relinked to, so the
+// wallet keeps passing Use.
+func (s *nullifierService) ReassignUser(ctx context.Context, fromUserID, toUserID string) error {
+	return s.repo.ReassignUser(ctx, fromUserID, toUserID)
+}
+
+// derive returns HMAC-SHA256(secret, chainId | contract | value) in hex. The collection acts as
+// the nullifier session, so one value yields unrelated nullifiers for different collections.
+func (s *nullifierService) derive(chainID int64, contractAddress, value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strconv.FormatInt(chainID, 10)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strings.ToLower(contractAddress)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,20 @@
+package staff
+
+import "context"
+
+type Query interface {
+	FindDelegationByID(ctx context.Context, id string) (*Delegation, error)
+	FindDelegations(ctx context.Context, filter DelegationFilter) (*Delegations, error)
+	FindActiveDelegation(ctx context.Context, params AuthorizeParams, now int64) (*Delegation, error)
+}
+
+type Command interface {
+	CreateDelegation(ctx context.Context, d *Delegation) error
+	RevokeDelegation(ctx context.Context, id string, issuerAddresses []string, at int64) error
+	ReassignStaff(ctx context.Context, fromStaffID, toStaffID string) error
+}
+
+type Repository interface {
+	Query
+	Command
+}
@@ -0,0 +1,217 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/heroticket/internal/pagination"
+	"github.com/heroticket/internal/service/staff"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRepository struct {
+	staff.Query
+	staff.Command
+	client *mongo.Client
+	dbname string
+}
+
+func New(ctx context.Context, client *mongo.Client, dbname string) (staff.Repository, error) {
+	cmd := NewMongoCommand(client, dbname)
+	repo := &mongoRepository{
+		Query:   NewMongoQuery(client, dbname),
+		Command: cmd,
+		client:  client,
+		dbname:  dbname,
+	}
+
+	_, err := cmd.collection().Indexes().CreateMany(
+		ctx,
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "staffId", Value: 1}, {Key: "chainId", Value: 1}, {Key: "collections", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "issuerAddress", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+		},
+	)
+
+	return repo, err
+}
+
+type mongoQuery struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoQuery(client *mongo.Client, dbname string) staff.Query {
+	return &mongoQuery{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (q *mongoQuery) FindDelegationByID(ctx context.Context, id string) (*staff.Delegation, error) {
+	coll := q.collection()
+
+	filter := bson.M{"_id": id}
+
+	var d staff.Delegation
+
+	if err := coll.FindOne(ctx, filter).Decode(&d); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, staff.ErrDelegationNotFound
+		}
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (q *mongoQuery) FindDelegations(ctx context.Context, filter staff.DelegationFilter) (*staff.Delegations, error) {
+	coll := q.collection()
+
+	f := bson.M{}
+
+	if filter.ChainID != 0 {
+		f["chainId"] = filter.ChainID
+	}
+
+	if len(filter.IssuerAddresses) > 0 {
+		f["issuerAddress"] = bson.M{"$in": filter.IssuerAddresses}
+	}
+
+	if filter.StaffID != "" {
+		f["staffId"] = filter.StaffID
+	}
+
+	if filter.ActiveAt != 0 {
+		activeFilter(f, filter.ActiveAt)
+	}
+
+	total, err := coll.CountDocuments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := pagination.New(total, filter.Page, filter.Limit)
+
+	opts := options.Find().
+		SetSort(bson.M{"createdAt": -1}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cursor, err := coll.Find(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]*staff.Delegation, 0)
+
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return &staff.Delegations{
+		Items:      items,
+		Pagination: p,
+	}, nil
+}
+
+func (q *mongoQuery) FindActiveDelegation(ctx context.Context, params staff.AuthorizeParams, now int64) (*staff.Delegation, error) {
+	coll := q.collection()
+
+	filter := bson.M{
+		"chainId":       params.ChainID,
+		"issuerAddress": params.IssuerAddress,
+		"staffId":       params.StaffID,
+		"role":          params.Role,
+		"collections":   params.ContractAddress,
+	}
+
+	activeFilter(filter, now)
+
+	var d staff.Delegation
+
+	if err := coll.FindOne(ctx, filter).Decode(&d); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, staff.ErrDelegationNotFound
+		}
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (q *mongoQuery) collection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("delegations")
+}
+
+// activeFilter narrows filter to delegations that are not revoked and not expired at now.
+func activeFilter(filter bson.M, now int64) {
+	filter["revokedAt"] = 0
+	filter["$or"] = bson.A{
+		bson.M{"expiresAt": 0},
+		bson.M{"expiresAt": bson.M{"$gt": now}},
+	}
+}
+
+type mongoCommand struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoCommand(client *mongo.Client, dbname string) *mongoCommand {
+	return &mongoCommand{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (c *mongoCommand) CreateDelegation(ctx context.Context, d *staff.Delegation) error {
+	coll := c.collection()
+
+	_, err := coll.InsertOne(ctx, d)
+
+	return err
+}
+
+func (c *mongoCommand) RevokeDelegation(ctx context.Context, id string, issuerAddresses []string, at int64) error {
+	coll := c.collection()
+
+	filter := bson.M{
+		"_id":           id,
+		"issuerAddress": bson.M{"$in": issuerAddresses},
+		"revokedAt":     0,
+	}
+
+	update := bson.M{
+		"$set": bson.M{"revokedAt": at},
+	}
+
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return staff.ErrDelegationNotFound
+	}
+
+	return nil
+}
+
+func (c *mongoCommand) ReassignStaff(ctx context.Context, fromStaffID, toStaffID string) error {
+	coll := c.collection()
+
+	_, err := coll.UpdateMany(ctx, bson.M{"staffId": fromStaffID}, bson.M{"$set": bson.M{"staffId": toStaffID}})
+
+	return err
+}
+
+func (c *mongoCommand) collection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("delegations")
+}
@@ -0,0 +1,135 @@
+package staff
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Invite(ctx context.Context, params InviteParams) (*Delegation, error)
+	Revoke(ctx context.Context, id string, issuerAddresses []string) error
+	Authorize(ctx context.Context, params AuthorizeParams) (*Delegation, error)
+	FindDelegationByID(ctx context.Context, id string) (*Delegation, error)
+	FindDelegations(ctx context.Context, filter DelegationFilter) (*Delegations, error)
+	ReassignStaff(ctx context.Context, fromStaffID, toStaffID string) error
+}
+
+type staffService struct {
+	repo Repository
+}
+
+func New(repo Repository) Service {
+	return &staffService{repo: repo}
+}
+
+func (s *staffService) Invite(ctx context.Context, params InviteParams) (*Delegation, error) {
+	if params.Role == "" {
+		params.Role = RoleScanner
+	}
+
+	if params.Role != RoleScanner {
+		return nil, ErrInvalidRole
+	}
+
+	if len(params.Collections) == 0 {
+		return nil, ErrNoCollections
+	}
+
+	now := time.Now().Unix()
+
+	if params.ExpiresAt != 0 && params.ExpiresAt <= now {
+		return nil, ErrInvalidExpiry
+	}
+
+	issuerAddress := strings.ToLower(params.IssuerAddress)
+	staffAddress := strings.ToLower(params.StaffAddress)
+
+	if issuerAddress == staffAddress {
+		return nil, ErrSelfDelegation
+	}
+
+	collections := make([]string, 0, len(params.Collections))
+	seen := make(map[string]bool, len(params.Collections))
+
+	for _, c := range params.Collections {
+		c = strings.ToLower(c)
+		if seen[c] {
+			continue
+		}
+
+		seen[c] = true
+		collections = append(collections, c)
+	}
+
+	d := &Delegation{
+		ID:            uuid.New().String(),
+		ChainID:       params.ChainID,
+		IssuerAddress: issuerAddress,
+		StaffID:       params.StaffID,
+		StaffAddress:  staffAddress,
+		Role:          params.Role,
+		Collections:   collections,
+		ExpiresAt:     params.ExpiresAt,
+		CreatedAt:     now,
+	}
+
+	if err := s.repo.CreateDelegation(ctx, d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Revoke revokes a delegation issued by any of the wallets of the issuer.
+func (s *staffService) Revoke(ctx context.Context, id string, issuerAddresses []string) error {
+	return s.repo.RevokeDelegation(ctx, id, lower(issuerAddresses), time.Now().Unix())
+}
+
+// Authorize returns the delegation letting the staff act on the collection, ErrNotDelegated when
+// there is none in force.
+func (s *staffService) Authorize(ctx context.Context, params AuthorizeParams) (*Delegation, error) {
+	if params.Role == "" {
+		params.Role = RoleScanner
+	}
+
+	params.ContractAddress = strings.ToLower(params.ContractAddress)
+	params.IssuerAddress = strings.ToLower(params.IssuerAddress)
+
+	d, err := s.repo.FindActiveDelegation(ctx, params, time.Now().Unix())
+	if err != nil {
+		if err == ErrDelegationNotFound {
+			return nil, ErrNotDelegated
+		}
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (s *staffService) FindDelegationByID(ctx context.Context, id string) (*Delegation, error) {
+	return s.repo.FindDelegationByID(ctx, id)
+}
+
+func (s *staffService) FindDelegations(ctx context.Context, filter DelegationFilter) (*Delegations, error) {
+	filter.IssuerAddresses = lower(filter.IssuerAddresses)
+
+	return s.repo.FindDelegations(ctx, filter)
+}
+
+// ReassignStaff moves the delegations of a staff to the identity their wallet was relinked to.
+func (s *staffService) ReassignStaff(ctx context.Context, fromStaffID, toStaffID string) error {
+	return s.repo.ReassignStaff(ctx, fromStaffID, toStaffID)
+}
+
+func lower(addresses []string) []string {
+	lowered := make([]string, len(addresses))
+
+	for i, a := range addresses {
+		lowered[i] = strings.ToLower(a)
+	}
+
+	return lowered
+}
@@ -0,0 +1,76 @@
+package staff
+
+import (
+	"errors"
+
+	"github.com/heroticket/internal/pagination"
+)
+
+var (
+	ErrDelegationNotFound = errors.New("delegation not found")
+	ErrNotDelegated       = errors.New("user is not delegated for the ticket collection")
+	ErrInvalidRole        = errors.New("invalid staff role")
+	ErrInvalidExpiry      = errors.New("delegation expiry is in the past")
+	ErrNoCollections      = errors.New("delegation needs at least one ticket collection")
+	ErrSelfDelegation     = errors.New("issuers cannot delegate to themselves")
+)
+
+const (
+	// RoleScanner lets staff verify and check in tickets of the delegated collections
+	RoleScanner = "scanner"
+)
+
+// Delegation grants a staff account a role over some ticket collections of an issuer on one chain.
+type Delegation struct {
+	ID            string   `json:"id" bson:"_id"`
+	ChainID       int64    `json:"chainId" bson:"chainId"`
+	IssuerAddress string   `json:"issuerAddress" bson:"issuerAddress"`
+	StaffID       string   `json:"staffId" bson:"staffId"`
+	StaffAddress  string   `json:"staffAddress" bson:"staffAddress"`
+	Role          string   `json:"role" bson:"role"`
+	Collections   []string `json:"collections" bson:"collections"`
+	// ExpiresAt is the unix time the delegation lapses at, 0 when it does not expire
+	ExpiresAt int64 `json:"expiresAt" bson:"expiresAt"`
+	// RevokedAt is the unix time the issuer revoked the delegation at, 0 while active
+	RevokedAt int64 `json:"revokedAt" bson:"revokedAt"`
+	CreatedAt int64 `json:"createdAt" bson:"createdAt"`
+}
+
+// Active reports whether the delegation is in force at unix time now.
+func (d *Delegation) Active(now int64) bool {
+	return d.RevokedAt == 0 && (d.ExpiresAt == 0 || d.ExpiresAt > now)
+}
+
+type Delegations struct {
+	Items      []*Delegation          `json:"items"`
+	Pagination *pagination.Pagination `json:"pagination"`
+}
+
+type InviteParams struct {
+	ChainID       int64
+	IssuerAddress string
+	StaffID       string
+	StaffAddress  string
+	Role          string
+	Collections   []string
+	ExpiresAt     int64
+}
+
+type AuthorizeParams struct {
+	ChainID         int64
+	ContractAddress string
+	IssuerAddress   string
+	StaffID         string
+	Role            string
+}
+
+type DelegationFilter struct {
+	ChainID int64
+	// IssuerAddresses keeps delegations issued by any of the wallets, all when empty
+	IssuerAddresses []string
+	StaffID         string
+	// ActiveAt keeps only delegations in force at the unix time, all when 0
+	ActiveAt int64
+	Page     int64
+	Limit    int64
+}
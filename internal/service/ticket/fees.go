@@ -0,0 +1,82 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/heroticket/internal/web3"
+)
+
+var ErrNoBaseFee = errors.New("chain does not report a base fee")
+
+// DefaultGasLimitMultiplier pads estimated gas limits so state changes between estimation and inclusion don't run out of gas.
+var DefaultGasLimitMultiplier = 1.2
+
+// setFees fills the dynamic fee fields of auth from the suggested tip and the latest base fee.
+// The fee cap leaves room for the base fee to double, but never goes above the configured ceiling;
+// when even the current base fee plus tip is above the ceiling, web3.ErrMaxFeeExceeded is returned
+// instead of sending a transaction that could not be mined.
+func (s *TicketService) setFees(ctx context.Context, auth *bind.TransactOpts) error {
+	tip, err := s.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return err
+	}
+
+	head, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if head.BaseFee == nil {
+		return ErrNoBaseFee
+	}
+
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+
+	if s.maxFeePerGas != nil {
+		required := new(big.Int).Add(head.BaseFee, tip)
+
+		if required.Cmp(s.maxFeePerGas) > 0 {
+			return fmt.Errorf("%w: base fee %s plus tip %s is above ceiling %s", web3.ErrMaxFeeExceeded, head.BaseFee, tip, s.maxFeePerGas)
+		}
+
+		if feeCap.Cmp(s.maxFeePerGas) > 0 {
+			feeCap = new(big.Int).Set(s.maxFeePerGas)
+		}
+	}
+
+	auth.GasTipCap = tip
+	auth.GasFeeCap = feeCap
+	auth.GasPrice = nil
+
+	return nil
+}
+
+// scaleGasLimit re-signs tx with its estimated gas limit multiplied by the configured multiplier.
+func (s *TicketService) scaleGasLimit(auth *bind.TransactOpts, tx *types.Transaction) (*types.Transaction, error) {
+	gas := s.gasLimit(tx.Gas())
+
+	if gas == tx.Gas() {
+		return tx, nil
+	}
+
+	return auth.Signer(auth.From, types.NewTx(&types.DynamicFeeTx{
+		ChainID:    tx.ChainId(),
+		Nonce:      tx.Nonce(),
+		GasTipCap:  tx.GasTipCap(),
+		GasFeeCap:  tx.GasFeeCap(),
+		Gas:        gas,
+		To:         tx.To(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}))
+}
+
+func (s *TicketService) gasLimit(estimated uint64) uint64 {
+	return uint64(float64(estimated) * s.gasLimitMultiplier)
+}
@@ -0,0 +1,67 @@
+package ticket
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// Networks holds the ticket service of every configured network, keyed by chain id.
+type Networks struct {
+	services       map[int64]Service
+	defaultChainID int64
+}
+
+// NewNetworks returns the networks of services. defaultChainID must be the chain id of one of them.
+func NewNetworks(defaultChainID int64, services ...Service) (*Networks, error) {
+	n := &Networks{
+		services:       make(map[int64]Service, len(services)),
+		defaultChainID: defaultChainID,
+	}
+
+	for _, svc := range services {
+		if _, ok := n.services[svc.ChainID()]; ok {
+			return nil, errors.New("duplicate network")
+		}
+
+		n.services[svc.ChainID()] = svc
+	}
+
+	if _, ok := n.services[defaultChainID]; !ok {
+		return nil, ErrUnknownNetwork
+	}
+
+	return n, nil
+}
+
+// Get returns the ticket service of chainID, or of the default network when chainID is 0.
+func (n *Networks) Get(chainID int64) (Service, error) {
+	if chainID == 0 {
+		return n.Default(), nil
+	}
+
+	svc, ok := n.services[chainID]
+	if !ok {
+		return nil, ErrUnknownNetwork
+	}
+
+	return svc, nil
+}
+
+func (n *Networks) Default() Service {
+	return n.services[n.defaultChainID]
+}
+
+// ChainIDs returns the chain ids of every network in ascending order.
+func (n *Networks) ChainIDs() []int64 {
+	ids := make([]int64, 0, len(n.services))
+
+	for id := range n.services {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
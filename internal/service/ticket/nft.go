@@ -0,0 +1,193 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/heroticket/pkg/contracts/heroticket"
+)
+
+const (
+	OwnedNFTProviderLocal   = "local"
+	OwnedNFTProviderMoralis = "moralis"
+)
+
+var (
+	DefaultMoralisUrl     = "https://deep-index.moralis.io/api/v2.2"
+	DefaultMoralisTimeout = 10 * time.Second
+)
+
+// OwnedNFTProvider lists the NFTs held by an address, usually a tba.
+type OwnedNFTProvider interface {
+	OwnedNFT(ctx context.Context, owner common.Address) (OwnedNFT, error)
+}
+
+type MoralisProviderConfig struct {
+	ApiKey string
+	Url    string
+	// Chain is the Moralis chain name, the hex ChainID when empty
+	Chain   string
+	ChainID int64
+	Timeout time.Duration
+}
+
+// MoralisProvider reads owned NFTs from the Moralis deep index API.
+type MoralisProvider struct {
+	apiKey string
+	url    string
+	chain  string
+	client *http.Client
+}
+
+func NewMoralisProvider(cfg MoralisProviderConfig) *MoralisProvider {
+	p := &MoralisProvider{
+		apiKey: cfg.ApiKey,
+		url:    DefaultMoralisUrl,
+		chain:  fmt.Sprintf("0x%x", cfg.ChainID),
+		client: &http.Client{Timeout: DefaultMoralisTimeout},
+	}
+
+	if cfg.Url != "" {
+		p.url = strings.TrimSuffix(cfg.Url, "/")
+	}
+
+	if cfg.Chain != "" {
+		p.chain = cfg.Chain
+	}
+
+	if cfg.Timeout > 0 {
+		p.client.Timeout = cfg.Timeout
+	}
+
+	return p
+}
+
+func (p *MoralisProvider) OwnedNFT(ctx context.Context, owner common.Address) (OwnedNFT, error) {
+	query := url.Values{}
+	query.Set("chain", p.chain)
+	query.Set("format", "decimal")
+	query.Set("media_items", "false")
+
+	reqUrl := fmt.Sprintf("%s/%s/nft?%s", p.url, owner.Hex(), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return OwnedNFT{}, err
+	}
+
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-API-Key", p.apiKey)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return OwnedNFT{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return OwnedNFT{}, fmt.Errorf("failed to get owned nft, status code: %d", res.StatusCode)
+	}
+
+	var result OwnedNFT
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return OwnedNFT{}, err
+	}
+
+	return result, nil
+}
+
+// LocalProvider builds owned NFTs from tickets indexed by the subscriber, cross-checked with
+// the collections the contract lists for the owner.
+type LocalProvider struct {
+	repo Query
+	hero *heroticket.Heroticket
+}
+
+func NewLocalProvider(repo Query, hero *heroticket.Heroticket) *LocalProvider {
+	return &LocalProvider{
+		repo: repo,
+		hero: hero,
+	}
+}
+
+func (p *LocalProvider) OwnedNFT(ctx context.Context, owner common.Address) (OwnedNFT, error) {
+	ownerAddress := strings.ToLower(owner.Hex())
+
+	// 1. collections the contract knows the owner holds
+	contractAddresses, err := p.hero.TicketsByOwner(&bind.CallOpts{Context: ctx}, owner)
+	if err != nil {
+		return OwnedNFT{}, err
+	}
+
+	held := make(map[string]bool, len(contractAddresses))
+
+	for _, contractAddress := range contractAddresses {
+		held[strings.ToLower(contractAddress.Hex())] = false
+	}
+
+	// 2. indexed tickets, which carry token ids
+	tickets, err := p.repo.FindTicketByOwnerAddress(ctx, ownerAddress)
+	if err != nil {
+		return OwnedNFT{}, err
+	}
+
+	nfts := make([]NFT, 0, len(contractAddresses))
+
+	for _, t := range tickets {
+		if _, ok := held[t.Address]; !ok {
+			// transferred away and the transfer is not indexed yet
+			continue
+		}
+
+		held[t.Address] = true
+
+		nfts = append(nfts, NFT{
+			TokenId:      strconv.FormatUint(t.TokenID, 10),
+			TokenAddress: t.Address,
+			Name:         t.Name,
+			Symbol:       t.Symbol,
+			TokenUri:     t.Image,
+		})
+	}
+
+	// 3. tickets not indexed yet fall back to their collection, without a token id
+	for _, contractAddress := range contractAddresses {
+		address := strings.ToLower(contractAddress.Hex())
+		if held[address] {
+			continue
+		}
+
+		held[address] = true
+
+		nft := NFT{TokenAddress: address}
+
+		tc, err := p.repo.FindTicketCollectionByContractAddress(ctx, address)
+		if err != nil && err != ErrTicketCollectionNotFound {
+			return OwnedNFT{}, err
+		}
+
+		if tc != nil {
+			nft.Name = tc.Name
+			nft.Symbol = tc.Symbol
+			nft.TokenUri = tc.TicketUrl
+		}
+
+		nfts = append(nfts, nft)
+	}
+
+	return OwnedNFT{
+		Status:   "SYNCED",
+		Total:    len(nfts),
+		Page:     1,
+		PageSize: len(nfts),
+		NFTs:     nfts,
+	}, nil
+}
@@ -0,0 +1,359 @@
+package ticket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/iden3/go-circuits/v2"
+	"github.com/iden3/iden3comm/v2/protocol"
+)
+
+var (
+	ErrInvalidProofPolicy     = errors.New("invalid proof policy")
+	ErrPersonhoodNotProven    = errors.New("personhood proof is missing")
+	ErrPersonhoodNotDisclosed = errors.New("personhood value is not disclosed")
+)
+
+const (
+	// OwnershipContext is the JSON-LD context of the Ownership credentials the platform issues
+	OwnershipContext = "ipfs://QmfNkUAwq73r1HmMmzYDZ9REBqLrqdXmQm8xBdq7QbQvHz"
+	OwnershipType    = "Ownership"
+
+	// MaxProofRequirements bounds the proofs one flow may ask a holder for
+	MaxProofRequirements = 5
+	// MaxProofValues bounds the values of an $in or $nin requirement
+	MaxProofValues = 20
+
+	// PersonhoodProofID is the proof request id of the personhood scope, so callbacks can find it
+	PersonhoodProofID uint32 = 1
+)
+
+var (
+	proofCircuits = map[string]bool{
+		string(circuits.AtomicQuerySigV2CircuitID): true,
+		string(circuits.AtomicQueryMTPV2CircuitID): true,
+	}
+
+	proofOperators = map[string]bool{
+		"$eq":  true,
+		"$ne":  true,
+		"$lt":  true,
+		"$gt":  true,
+		"$in":  true,
+		"$nin": true,
+	}
+
+	proofField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// ProofPolicy is the zero-knowledge proofs a collection asks holders for, on top of the platform's own.
+type ProofPolicy struct {
+	// Whitelist is proven before a purchase is authorized
+	Whitelist []ProofRequirement `json:"whitelist,omitempty" bson:"whitelist,omitempty"`
+	// Verify is proven at the door, next to ownership of the collection
+	Verify []ProofRequirement `json:"verify,omitempty" bson:"verify,omitempty"`
+	// Personhood, when set, limits whitelisting to one authorization per person
+	Personhood *PersonhoodRequirement `json:"personhood,omitempty" bson:"personhood,omitempty"`
+}
+
+// PersonhoodRequirement asks the holder to disclose Field of a proof-of-personhood credential,
+// a value unique to the person such as a document hash. The server never stores the value, only a
+// keyed per-collection nullifier of it, see the nullifier service.
+//
+// Circuit-level nullifiers (credentialAtomicQueryV3 with a nullifierSessionId) would keep the value
+// from the server entirely; they need a newer iden3 verifier than the one this module pins.
+type PersonhoodRequirement struct {
+	// CircuitID is the query circuit, credentialAtomicQuerySigV2 when empty
+	CircuitID string `json:"circuitId,omitempty" bson:"circuitId,omitempty"`
+	Type      string `json:"type" bson:"type"`
+	Context   string `json:"context" bson:"context"`
+	// AllowedIssuers are the DIDs of the trusted personhood issuers. Any issuer or the platform's own
+	// would let holders mint themselves fresh values, so the list is required and "*" is refused.
+	AllowedIssuers []string `json:"allowedIssuers" bson:"allowedIssuers"`
+	Field          string   `json:"field" bson:"field"`
+}
+
+// ProofRequirement asks for one credential whose Field satisfies Operator against Value, e.g.
+// a KYCAgeCredential with birthday $lt 20050101.
+type ProofRequirement struct {
+	// CircuitID is the query circuit, credentialAtomicQuerySigV2 when empty
+	CircuitID string `json:"circuitId,omitempty" bson:"circuitId,omitempty"`
+	Type      string `json:"type" bson:"type"`
+	Context   string `json:"context" bson:"context"`
+	// AllowedIssuers are the issuer DIDs or "*", the platform issuer when empty
+	AllowedIssuers []string `json:"allowedIssuers,omitempty" bson:"allowedIssuers,omitempty"`
+	Field          string   `json:"field" bson:"field"`
+	Operator       string   `json:"operator" bson:"operator"`
+	Value          any      `json:"value" bson:"value"`
+}
+
+func (p *ProofPolicy) Validate() error {
+	for flow, reqs := range map[string][]ProofRequirement{"whitelist": p.Whitelist, "verify": p.Verify} {
+		if len(reqs) > MaxProofRequirements {
+			return fmt.Errorf("%w: %s allows at most %d requirements", ErrInvalidProofPolicy, flow, MaxProofRequirements)
+		}
+
+		for i, req := range reqs {
+			if err := req.validate(); err != nil {
+				return fmt.Errorf("%w: %s[%d]: %s", ErrInvalidProofPolicy, flow, i, err)
+			}
+		}
+	}
+
+	if p.Personhood != nil {
+		if err := p.Personhood.validate(); err != nil {
+			return fmt.Errorf("%w: personhood: %s", ErrInvalidProofPolicy, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *PersonhoodRequirement) validate() error {
+	if len(r.AllowedIssuers) == 0 {
+		return errors.New("allowed issuers are required")
+	}
+
+	for _, issuer := range r.AllowedIssuers {
+		if issuer == "*" {
+			return errors.New("allowed issuers must be trusted issuer dids, not *")
+		}
+	}
+
+	return validateCredentialQuery(r.CircuitID, r.Type, r.Context, r.AllowedIssuers, r.Field)
+}
+
+func validateCredentialQuery(circuitID, credentialType, context string, allowedIssuers []string, field string) error {
+	if circuitID != "" && !proofCircuits[circuitID] {
+		return fmt.Errorf("unsupported circuit %q", circuitID)
+	}
+
+	if credentialType == "" {
+		return errors.New("type is required")
+	}
+
+	if !strings.HasPrefix(context, "ipfs://") && !strings.HasPrefix(context, "https://") {
+		return errors.New("context must be an ipfs:// or https:// url")
+	}
+
+	for _, issuer := range allowedIssuers {
+		if issuer != "*" && !strings.HasPrefix(issuer, "did:") {
+			return fmt.Errorf("allowed issuer %q is not a did", issuer)
+		}
+	}
+
+	if !proofField.MatchString(field) {
+		return fmt.Errorf("invalid field %q", field)
+	}
+
+	return nil
+}
+
+// Request compiles the requirement into a selective disclosure request of Field.
+func (r *PersonhoodRequirement) Request(defaultIssuer string) protocol.ZeroKnowledgeProofRequest {
+	return credentialRequest(PersonhoodProofID, r.CircuitID, r.Type, r.Context, r.AllowedIssuers, defaultIssuer,
+		map[string]interface{}{r.Field: map[string]interface{}{}})
+}
+
+func (r *ProofRequirement) validate() error {
+	if err := validateCredentialQuery(r.CircuitID, r.Type, r.Context, r.AllowedIssuers, r.Field); err != nil {
+		return err
+	}
+
+	if !proofOperators[r.Operator] {
+		return fmt.Errorf("unsupported operator %q", r.Operator)
+	}
+
+	switch r.Operator {
+	case "$in", "$nin":
+		values, ok := r.Value.([]any)
+		if !ok || len(values) == 0 || len(values) > MaxProofValues {
+			return fmt.Errorf("%s needs 1 to %d values", r.Operator, MaxProofValues)
+		}
+
+		for _, v := range values {
+			if !proofScalar(v) {
+				return fmt.Errorf("%s values must be strings, integers or booleans", r.Operator)
+			}
+		}
+	case "$lt", "$gt":
+		if !proofInteger(r.Value) {
+			return fmt.Errorf("%s needs an integer value", r.Operator)
+		}
+	default:
+		if !proofScalar(r.Value) {
+			return fmt.Errorf("%s needs a string, integer or boolean value", r.Operator)
+		}
+	}
+
+	return nil
+}
+
+// Request compiles the requirement into a proof request with the given id, defaulting allowed
+// issuers to defaultIssuer.
+func (r *ProofRequirement) Request(id uint32, defaultIssuer string) protocol.ZeroKnowledgeProofRequest {
+	return credentialRequest(id, r.CircuitID, r.Type, r.Context, r.AllowedIssuers, defaultIssuer,
+		map[string]interface{}{r.Field: map[string]interface{}{r.Operator: r.Value}})
+}
+
+func credentialRequest(id uint32, circuitID, credentialType, context string, allowedIssuers []string, defaultIssuer string, subject map[string]interface{}) protocol.ZeroKnowledgeProofRequest {
+	if circuitID == "" {
+		circuitID = string(circuits.AtomicQuerySigV2CircuitID)
+	}
+
+	if len(allowedIssuers) == 0 {
+		allowedIssuers = []string{defaultIssuer}
+	}
+
+	return protocol.ZeroKnowledgeProofRequest{
+		ID:        id,
+		CircuitID: circuitID,
+		Query: map[string]interface{}{
+			"allowedIssuers":    allowedIssuers,
+			"credentialSubject": subject,
+			"context":           context,
+			"type":              credentialType,
+		},
+	}
+}
+
+// OwnershipRequirement asks for the Ownership credential of a collection.
+func OwnershipRequirement(contractAddress string) ProofRequirement {
+	return ProofRequirement{
+		Type:     OwnershipType,
+		Context:  OwnershipContext,
+		Field:    "ticket_address",
+		Operator: "$eq",
+		Value:    strings.ToLower(contractAddress),
+	}
+}
+
+// WhitelistScope compiles the proofs asked before a purchase, ids counting up from baseID.
+func (tc *TicketCollection) WhitelistScope(baseID uint32, defaultIssuer string) []protocol.ZeroKnowledgeProofRequest {
+	var reqs []ProofRequirement
+
+	if tc.ProofPolicy != nil {
+		reqs = tc.ProofPolicy.Whitelist
+	}
+
+	scope := proofScope(reqs, baseID, defaultIssuer)
+
+	if tc.ProofPolicy != nil && tc.ProofPolicy.Personhood != nil {
+		scope = append(scope, tc.ProofPolicy.Personhood.Request(defaultIssuer))
+	}
+
+	return scope
+}
+
+// VerifyScope compiles the proofs asked at the door, ownership of the collection first and ids
+// counting up from baseID.
+func (tc *TicketCollection) VerifyScope(baseID uint32, defaultIssuer string) []protocol.ZeroKnowledgeProofRequest {
+	reqs := []ProofRequirement{OwnershipRequirement(tc.ContractAddress)}
+
+	if tc.ProofPolicy != nil {
+		reqs = append(reqs, tc.ProofPolicy.Verify...)
+	}
+
+	return proofScope(reqs, baseID, defaultIssuer)
+}
+
+func proofScope(reqs []ProofRequirement, baseID uint32, defaultIssuer string) []protocol.ZeroKnowledgeProofRequest {
+	scope := make([]protocol.ZeroKnowledgeProofRequest, 0, len(reqs)+1)
+
+	for i := range reqs {
+		id := baseID + uint32(i)
+		if id == PersonhoodProofID {
+			// keep the personhood id unambiguous, the ids only need to be unique within a request
+			id = ^PersonhoodProofID - uint32(i)
+		}
+
+		scope = append(scope, reqs[i].Request(id, defaultIssuer))
+	}
+
+	return scope
+}
+
+// DisclosedPersonhood returns the personhood value the holder disclosed in resp, ok false when
+// request did not ask for one. The response must already be verified against request.
+func DisclosedPersonhood(request protocol.AuthorizationRequestMessage, resp *protocol.AuthorizationResponseMessage) (value string, ok bool, err error) {
+	var field string
+
+	for _, scope := range request.Body.Scope {
+		if scope.ID != PersonhoodProofID {
+			continue
+		}
+
+		subject, _ := scope.Query["credentialSubject"].(map[string]interface{})
+		for key := range subject {
+			field = key
+		}
+	}
+
+	if field == "" {
+		return "", false, nil
+	}
+
+	for _, proof := range resp.Body.Scope {
+		if proof.ID != PersonhoodProofID {
+			continue
+		}
+
+		var vp struct {
+			VerifiableCredential struct {
+				CredentialSubject map[string]json.RawMessage `json:"credentialSubject"`
+			} `json:"verifiableCredential"`
+		}
+
+		if err := json.Unmarshal(proof.VerifiablePresentation, &vp); err != nil {
+			return "", true, ErrPersonhoodNotDisclosed
+		}
+
+		raw, found := vp.VerifiableCredential.CredentialSubject[field]
+		if !found {
+			return "", true, ErrPersonhoodNotDisclosed
+		}
+
+		var disclosed interface{}
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+
+		if err := dec.Decode(&disclosed); err != nil {
+			return "", true, ErrPersonhoodNotDisclosed
+		}
+
+		value := fmt.Sprint(disclosed)
+		if value == "" {
+			return "", true, ErrPersonhoodNotDisclosed
+		}
+
+		return value, true, nil
+	}
+
+	return "", true, ErrPersonhoodNotProven
+}
+
+func proofScalar(v any) bool {
+	switch v.(type) {
+	case string, bool:
+		return true
+	default:
+		return proofInteger(v)
+	}
+}
+
+func proofInteger(v any) bool {
+	switch n := v.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return n == math.Trunc(n) && math.Abs(n) < 1<<53
+	default:
+		return false
+	}
+}
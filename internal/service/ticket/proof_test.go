@@ -0,0 +1,111 @@
+package ticket
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/iden3/iden3comm/v2/protocol"
+)
+
+func TestProofPolicyValidate(t *testing.T) {
+	personhood := func(issuers ...string) *ProofPolicy {
+		return &ProofPolicy{Personhood: &PersonhoodRequirement{
+			Type:           "PersonhoodCredential",
+			Context:        "ipfs://personhood",
+			AllowedIssuers: issuers,
+			Field:          "documentHash",
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		policy *ProofPolicy
+		valid  bool
+	}{
+		{"empty", &ProofPolicy{}, true},
+		{"personhood with trusted issuers", personhood("did:iden3:issuer1", "did:iden3:issuer2"), true},
+		{"personhood without issuers", personhood(), false},
+		{"personhood from any issuer", personhood("*"), false},
+		{"personhood with any issuer among trusted ones", personhood("did:iden3:issuer1", "*"), false},
+		{"personhood issuer not a did", personhood("issuer1"), false},
+		{"requirement from any issuer", &ProofPolicy{Whitelist: []ProofRequirement{{
+			Type: "KYCAgeCredential", Context: "https://example.com/kyc", AllowedIssuers: []string{"*"},
+			Field: "birthday", Operator: "$lt", Value: float64(20050101),
+		}}}, true},
+		{"requirement with fractional $lt", &ProofPolicy{Verify: []ProofRequirement{{
+			Type: "KYCAgeCredential", Context: "https://example.com/kyc",
+			Field: "birthday", Operator: "$lt", Value: 1.5,
+		}}}, false},
+		{"requirement with unknown operator", &ProofPolicy{Verify: []ProofRequirement{{
+			Type: "KYCAgeCredential", Context: "https://example.com/kyc",
+			Field: "birthday", Operator: "$regex", Value: "x",
+		}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.valid && err != nil {
+				t.Fatalf("want valid, got %v", err)
+			}
+
+			if !tt.valid && !errors.Is(err, ErrInvalidProofPolicy) {
+				t.Fatalf("want ErrInvalidProofPolicy, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDisclosedPersonhood(t *testing.T) {
+	tc := &TicketCollection{ProofPolicy: &ProofPolicy{Personhood: &PersonhoodRequirement{
+		Type:           "PersonhoodCredential",
+		Context:        "ipfs://personhood",
+		AllowedIssuers: []string{"did:iden3:issuer1"},
+		Field:          "documentHash",
+	}}}
+
+	withPersonhood := protocol.AuthorizationRequestMessage{}
+	withPersonhood.Body.Scope = tc.WhitelistScope(2, "did:iden3:platform")
+
+	withoutPersonhood := protocol.AuthorizationRequestMessage{}
+	withoutPersonhood.Body.Scope = (&TicketCollection{}).WhitelistScope(2, "did:iden3:platform")
+
+	response := func(id uint32, vp string) *protocol.AuthorizationResponseMessage {
+		resp := &protocol.AuthorizationResponseMessage{}
+		resp.Body.Scope = []protocol.ZeroKnowledgeProofResponse{{ID: id, VerifiablePresentation: json.RawMessage(vp)}}
+		return resp
+	}
+
+	disclosed := `{"verifiableCredential":{"credentialSubject":{"documentHash":12345678901234567890}}}`
+
+	tests := []struct {
+		name    string
+		request protocol.AuthorizationRequestMessage
+		resp    *protocol.AuthorizationResponseMessage
+		value   string
+		ok      bool
+		err     error
+	}{
+		{"not asked", withoutPersonhood, response(PersonhoodProofID, disclosed), "", false, nil},
+		{"disclosed", withPersonhood, response(PersonhoodProofID, disclosed), "12345678901234567890", true, nil},
+		{"string value", withPersonhood, response(PersonhoodProofID, `{"verifiableCredential":{"credentialSubject":{"documentHash":"abc"}}}`), "abc", true, nil},
+		{"proof missing", withPersonhood, response(2, disclosed), "", true, ErrPersonhoodNotProven},
+		{"field missing", withPersonhood, response(PersonhoodProofID, `{"verifiableCredential":{"credentialSubject":{"other":1}}}`), "", true, ErrPersonhoodNotDisclosed},
+		{"empty value", withPersonhood, response(PersonhoodProofID, `{"verifiableCredential":{"credentialSubject":{"documentHash":""}}}`), "", true, ErrPersonhoodNotDisclosed},
+		{"invalid presentation", withPersonhood, response(PersonhoodProofID, `[]`), "", true, ErrPersonhoodNotDisclosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok, err := DisclosedPersonhood(tt.request, tt.resp)
+			if err != tt.err {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+
+			if value != tt.value || ok != tt.ok {
+				t.Fatalf("want (%q, %v), got (%q, %v)", tt.value, tt.ok, value, ok)
+			}
+		})
+	}
+}
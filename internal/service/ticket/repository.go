@@ -7,14 +7,22 @@ import (
 type Query interface {
 	FindTicketByAddress(ctx context.Context, address string) (*Ticket, error)
 	FindTicketByOwnerAddress(ctx context.Context, ownerAddress string) ([]*Ticket, error)
+	// FindTicketsOfCollection finds the tickets of a collection held by an owner, by token id
+	FindTicketsOfCollection(ctx context.Context, address, ownerAddress string) ([]*Ticket, error)
 	FindTicketCollectionByContractAddress(ctx context.Context, contractAddress string) (*TicketCollection, error)
-	FindTicketCollections(ctx context.Context, filter TicketCollectionFilter) ([]*TicketCollection, error)
+	FindTicketCollections(ctx context.Context, filter TicketCollectionFilter) (*TicketCollections, error)
+	SearchTicketCollections(ctx context.Context, search TicketCollectionSearch) (*TicketCollectionSearchResults, error)
+	FindTBAByAddress(ctx context.Context, tbaAddress string) (*TBA, error)
 }
 
 type Command interface {
 	CreateTicket(ctx context.Context, params Ticket) (*Ticket, error)
 	DeleteTicket(ctx context.Context, id string) error
+	UpdateTicketOwner(ctx context.Context, id, ownerAddress string) error
 	CreateTicketCollection(ctx context.Context, params CreateTicketCollectionParams) (*TicketCollection, error)
+	UpdateTicketCollection(ctx context.Context, params UpdateTicketCollectionParams) error
+	UpdateProofPolicy(ctx context.Context, contractAddress string, policy *ProofPolicy) error
+	SaveTBA(ctx context.Context, params SaveTBAParams) (*TBA, error)
 }
 
 type Repository interface {
@@ -7,6 +7,7 @@ import (
 	"github.com/heroticket/internal/service/ticket"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoCommand struct {
@@ -22,7 +23,7 @@ func NewMongoCommand(client *mongo.Client, dbname string) *MongoCommand {
 }
 
 func (c *MongoCommand) CreateTicket(ctx context.Context, params ticket.Ticket) (*ticket.Ticket, error) {
-	coll := c.collection()
+	coll := c.ticketCollection()
 
 	var t ticket.Ticket
 
@@ -37,6 +38,9 @@ func (c *MongoCommand) CreateTicket(ctx context.Context, params ticket.Ticket) (
 
 	_, err := coll.InsertOne(ctx, t)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ticket.ErrTicketAlreadyExists
+		}
 		return nil, err
 	}
 
@@ -44,7 +48,7 @@ func (c *MongoCommand) CreateTicket(ctx context.Context, params ticket.Ticket) (
 }
 
 func (c *MongoCommand) DeleteTicket(ctx context.Context, id string) error {
-	coll := c.collection()
+	coll := c.ticketCollection()
 
 	filter := bson.M{"_id": id}
 
@@ -56,38 +60,191 @@ func (c *MongoCommand) DeleteTicket(ctx context.Context, id string) error {
 	return nil
 }
 
+func (c *MongoCommand) UpdateTicketOwner(ctx context.Context, id, ownerAddress string) error {
+	coll := c.ticketCollection()
+
+	filter := bson.M{"_id": id}
+
+	update := bson.M{"$set": bson.M{"ownerAddress": ownerAddress}}
+
+	res, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ticket.ErrTicketNotFound
+	}
+
+	return nil
+}
+
+// CreateTicketCollection upserts a ticket collection keyed by contract address.
+// Only non-empty fields are written, so a collection indexed from on-chain data
+// can later be enriched with off-chain metadata (and vice versa) without losing fields.
 func (c *MongoCommand) CreateTicketCollection(ctx context.Context, params ticket.CreateTicketCollectionParams) (*ticket.TicketCollection, error) {
 	coll := c.collection()
 
-	var tc ticket.TicketCollection
+	filter := bson.M{"contractAddress": params.ContractAddress}
+
+	value := bson.D{}
+
+	fields := []bson.E{
+		{Key: "issuerAddress", Value: params.IssuerAddress},
+		{Key: "name", Value: params.Name},
+		{Key: "symbol", Value: params.Symbol},
+		{Key: "description", Value: params.Description},
+		{Key: "organizer", Value: params.Organizer},
+		{Key: "location", Value: params.Location},
+		{Key: "date", Value: params.Date},
+		{Key: "bannerUrl", Value: params.BannerUrl},
+		{Key: "ticketUrl", Value: params.TicketUrl},
+		{Key: "ethPrice", Value: params.EthPrice},
+		{Key: "tokenPrice", Value: params.TokenPrice},
+		{Key: "totalSupply", Value: params.TotalSupply},
+		{Key: "remaining", Value: params.Remaining},
+	}
+
+	for _, field := range fields {
+		if field.Value != "" {
+			value = append(value, field)
+		}
+	}
+
+	if params.ChainID != 0 {
+		value = append(value, bson.E{Key: "chainId", Value: params.ChainID})
+	}
+
+	if params.SaleStartAt != 0 {
+		value = append(value, bson.E{Key: "saleStartAt", Value: params.SaleStartAt})
+	}
 
-	tc.IssuerAddress = params.IssuerAddress
-	tc.ContractAddress = params.ContractAddress
-	tc.Name = params.Name
-	tc.Symbol = params.Symbol
-	tc.Description = params.Description
-	tc.Organizer = params.Organizer
-	tc.Location = params.Location
-	tc.Date = params.Date
-	tc.BannerUrl = params.BannerUrl
-	tc.TicketUrl = params.TicketUrl
-	tc.EthPrice = params.EthPrice
-	tc.TokenPrice = params.TokenPrice
-	tc.TotalSupply = params.TotalSupply
-	tc.Remaining = params.Remaining
-	tc.SaleStartAt = params.SaleStartAt
-	tc.SaleEndAt = params.SaleEndAt
-	tc.CreatedAt = time.Now().Unix()
-	tc.UpdatedAt = time.Now().Unix()
-
-	_, err := coll.InsertOne(ctx, tc)
+	if params.SaleEndAt != 0 {
+		value = append(value, bson.E{Key: "saleEndAt", Value: params.SaleEndAt})
+	}
+
+	if params.ProofPolicy != nil {
+		value = append(value, bson.E{Key: "proofPolicy", Value: params.ProofPolicy})
+	}
+
+	prices, err := priceFields(params.EthPrice, params.TokenPrice)
 	if err != nil {
 		return nil, err
 	}
 
+	value = append(value, prices...)
+	value = append(value, bson.E{Key: "updatedAt", Value: time.Now().Unix()})
+
+	update := bson.D{
+		{Key: "$set", Value: value},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "createdAt", Value: time.Now().Unix()}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var tc ticket.TicketCollection
+
+	if err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&tc); err != nil {
+		return nil, err
+	}
+
 	return &tc, nil
 }
 
+func (c *MongoCommand) UpdateTicketCollection(ctx context.Context, params ticket.UpdateTicketCollectionParams) error {
+	coll := c.collection()
+
+	if params.ContractAddress == "" {
+		return ticket.ErrTicketCollectionNotFound
+	}
+
+	filter := bson.M{"contractAddress": params.ContractAddress}
+
+	value := bson.D{}
+
+	if params.EthPrice == "" && params.TokenPrice == "" && params.Remaining == "" &&
+		params.SaleStartAt == 0 && params.SaleEndAt == 0 {
+		return ticket.ErrNothingToUpdate
+	}
+
+	if params.EthPrice != "" {
+		value = append(value, bson.E{Key: "ethPrice", Value: params.EthPrice})
+	}
+
+	if params.TokenPrice != "" {
+		value = append(value, bson.E{Key: "tokenPrice", Value: params.TokenPrice})
+	}
+
+	if params.Remaining != "" {
+		value = append(value, bson.E{Key: "remaining", Value: params.Remaining})
+	}
+
+	if params.SaleStartAt != 0 {
+		value = append(value, bson.E{Key: "saleStartAt", Value: params.SaleStartAt})
+	}
+
+	if params.SaleEndAt != 0 {
+		value = append(value, bson.E{Key: "saleEndAt", Value: params.SaleEndAt})
+	}
+
+	prices, err := priceFields(params.EthPrice, params.TokenPrice)
+	if err != nil {
+		return err
+	}
+
+	value = append(value, prices...)
+	value = append(value, bson.E{Key: "updatedAt", Value: time.Now().Unix()})
+
+	update := bson.D{
+		{
+			Key:   "$set",
+			Value: value,
+		},
+	}
+
+	res, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ticket.ErrTicketCollectionNotFound
+	}
+
+	return nil
+}
+
+func (c *MongoCommand) UpdateProofPolicy(ctx context.Context, contractAddress string, policy *ticket.ProofPolicy) error {
+	coll := c.collection()
+
+	filter := bson.M{"contractAddress": contractAddress}
+
+	update := bson.M{
+		"$set": bson.M{
+			"proofPolicy": policy,
+			"updatedAt":   time.Now().Unix(),
+		},
+	}
+
+	if policy == nil {
+		update = bson.M{
+			"$unset": bson.M{"proofPolicy": ""},
+			"$set":   bson.M{"updatedAt": time.Now().Unix()},
+		}
+	}
+
+	res, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ticket.ErrTicketCollectionNotFound
+	}
+
+	return nil
+}
+
 func (c *MongoCommand) DeleteTicketCollection(ctx context.Context, id string) error {
 	coll := c.collection()
 
@@ -101,25 +258,45 @@ func (c *MongoCommand) DeleteTicketCollection(ctx context.Context, id string) er
 	return nil
 }
 
-// func (c *MongoCommand) CreateTBA(ctx context.Context, params ticket.TbaAddresses) (*ticket.TbaAddresses, error) {
-// 	coll := c.collection()
+func (c *MongoCommand) SaveTBA(ctx context.Context, params ticket.SaveTBAParams) (*ticket.TBA, error) {
+	coll := c.tbaCollection()
+
+	filter := bson.M{"_id": params.TbaAddress}
 
-// 	var tba ticket.TbaAddresses
+	update := bson.D{
+		{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "ownerAddress", Value: params.OwnerAddress},
+				{Key: "tokenId", Value: params.TokenID},
+				{Key: "blockNumber", Value: params.BlockNumber},
+			},
+		},
+		{
+			Key:   "$setOnInsert",
+			Value: bson.D{{Key: "createdAt", Value: time.Now().Unix()}},
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
 
-// 	tba.ID = params.ID
-// 	tba.OwnerAddress = params.OwnerAddress
-// 	tba.TbaAddress = params.TbaAddress
-// 	tba.TokenID = params.TokenID
-// 	tba.Image = params.Image
+	var tba ticket.TBA
 
-// 	_, err := coll.InsertOne(ctx, tba)
-// 	if err != nil {
-// 		return nil, err
-// 	}
+	if err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&tba); err != nil {
+		return nil, err
+	}
 
-// 	return &tba, nil
-// }
+	return &tba, nil
+}
 
 func (c *MongoCommand) collection() *mongo.Collection {
 	return c.client.Database(c.dbname).Collection("tickets")
 }
+
+func (c *MongoCommand) ticketCollection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("ticket_tokens")
+}
+
+func (c *MongoCommand) tbaCollection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("tbas")
+}
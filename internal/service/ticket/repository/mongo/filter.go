@@ -0,0 +1,155 @@
+package mongo
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/heroticket/internal/service/ticket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// prices are stored as decimal strings, so numeric copies are kept for range queries and sorting
+const (
+	ethPriceNumField   = "ethPriceNum"
+	tokenPriceNumField = "tokenPriceNum"
+)
+
+// collectionQuery builds the mongo query for a ticket collection filter at the given unix time.
+func collectionQuery(filter ticket.TicketCollectionFilter, now int64) (bson.M, error) {
+	query := bson.M{}
+
+	if filter.IssuerAddress != "" {
+		query["issuerAddress"] = filter.IssuerAddress
+	}
+
+	if filter.Organizer != "" {
+		query["organizer"] = containsRegex(filter.Organizer)
+	}
+
+	if filter.Location != "" {
+		query["location"] = containsRegex(filter.Location)
+	}
+
+	switch filter.Status {
+	case ticket.SaleStatusOnSale:
+		query["saleStartAt"] = bson.M{"$lte": now}
+		query["saleEndAt"] = bson.M{"$gt": now}
+		query["remaining"] = bson.M{"$ne": "0"}
+	case ticket.SaleStatusSoldOut:
+		query["remaining"] = "0"
+	case ticket.SaleStatusUpcoming:
+		query["saleStartAt"] = bson.M{"$gt": now}
+	}
+
+	ethPrice, err := decimalRange(filter.MinEthPrice, filter.MaxEthPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	if ethPrice != nil {
+		query[ethPriceNumField] = ethPrice
+	}
+
+	tokenPrice, err := decimalRange(filter.MinTokenPrice, filter.MaxTokenPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenPrice != nil {
+		query[tokenPriceNumField] = tokenPrice
+	}
+
+	// sale period overlaps [SaleFrom, SaleTo]
+	var and []bson.M
+
+	if filter.SaleFrom > 0 {
+		and = append(and, bson.M{"saleEndAt": bson.M{"$gte": filter.SaleFrom}})
+	}
+
+	if filter.SaleTo > 0 {
+		and = append(and, bson.M{"saleStartAt": bson.M{"$lte": filter.SaleTo}})
+	}
+
+	if len(and) > 0 {
+		query["$and"] = and
+	}
+
+	return query, nil
+}
+
+// collectionSort returns the sort document for a ticket collection filter, newest first by default.
+func collectionSort(filter ticket.TicketCollectionFilter) bson.D {
+	field := "createdAt"
+
+	switch filter.SortBy {
+	case ticket.SortBySaleEndAt:
+		field = "saleEndAt"
+	case ticket.SortByEthPrice:
+		field = ethPriceNumField
+	case ticket.SortByTokenPrice:
+		field = tokenPriceNumField
+	}
+
+	order := -1
+
+	if filter.SortOrder == ticket.SortAsc {
+		order = 1
+	}
+
+	// _id as tie breaker keeps pages stable
+	return bson.D{{Key: field, Value: order}, {Key: "_id", Value: order}}
+}
+
+// priceFields returns the numeric copies of the given price strings to be stored alongside them.
+func priceFields(ethPrice, tokenPrice string) (bson.D, error) {
+	var fields bson.D
+
+	if ethPrice != "" {
+		d, err := primitive.ParseDecimal128(ethPrice)
+		if err != nil {
+			return nil, fmt.Errorf("invalid eth price %q: %w", ethPrice, err)
+		}
+		fields = append(fields, bson.E{Key: ethPriceNumField, Value: d})
+	}
+
+	if tokenPrice != "" {
+		d, err := primitive.ParseDecimal128(tokenPrice)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token price %q: %w", tokenPrice, err)
+		}
+		fields = append(fields, bson.E{Key: tokenPriceNumField, Value: d})
+	}
+
+	return fields, nil
+}
+
+func decimalRange(lower, upper string) (bson.M, error) {
+	if lower == "" && upper == "" {
+		return nil, nil
+	}
+
+	r := bson.M{}
+
+	if lower != "" {
+		d, err := primitive.ParseDecimal128(lower)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min price %q: %w", lower, err)
+		}
+		r["$gte"] = d
+	}
+
+	if upper != "" {
+		d, err := primitive.ParseDecimal128(upper)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max price %q: %w", upper, err)
+		}
+		r["$lte"] = d
+	}
+
+	return r, nil
+}
+
+func containsRegex(s string) primitive.Regex {
+	return primitive.Regex{Pattern: regexp.QuoteMeta(s), Options: "i"}
+}
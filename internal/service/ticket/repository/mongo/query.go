@@ -2,7 +2,9 @@ package mongo
 
 import (
 	"context"
+	"time"
 
+	"github.com/heroticket/internal/pagination"
 	"github.com/heroticket/internal/service/ticket"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -22,7 +24,7 @@ func NewMongoQuery(client *mongo.Client, dbname string) *MongoQuery {
 }
 
 func (q *MongoQuery) FindTicketByAddress(ctx context.Context, address string) (*ticket.Ticket, error) {
-	coll := q.collection()
+	coll := q.ticketCollection()
 
 	filter := bson.M{"address": address}
 
@@ -39,7 +41,7 @@ func (q *MongoQuery) FindTicketByAddress(ctx context.Context, address string) (*
 }
 
 func (q *MongoQuery) FindTicketByOwnerAddress(ctx context.Context, ownerAddress string) ([]*ticket.Ticket, error) {
-	coll := q.collection()
+	coll := q.ticketCollection()
 
 	filter := bson.M{"ownerAddress": ownerAddress}
 
@@ -63,6 +65,26 @@ func (q *MongoQuery) FindTicketByOwnerAddress(ctx context.Context, ownerAddress
 	return tickets, nil
 }
 
+func (q *MongoQuery) FindTicketsOfCollection(ctx context.Context, address, ownerAddress string) ([]*ticket.Ticket, error) {
+	coll := q.ticketCollection()
+
+	filter := bson.M{"address": address, "ownerAddress": ownerAddress}
+
+	cursor, err := coll.Find(ctx, filter, options.Find().SetSort(bson.M{"tokenId": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tickets := make([]*ticket.Ticket, 0)
+
+	if err := cursor.All(ctx, &tickets); err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
 func (q *MongoQuery) FindTicketCollectionByContractAddress(ctx context.Context, contractAddress string) (*ticket.TicketCollection, error) {
 	coll := q.collection()
 
@@ -80,27 +102,43 @@ func (q *MongoQuery) FindTicketCollectionByContractAddress(ctx context.Context,
 	return &tc, nil
 }
 
-func (q *MongoQuery) FindTicketCollections(ctx context.Context, filter ticket.TicketCollectionFilter) ([]*ticket.TicketCollection, error) {
+func (q *MongoQuery) FindTicketCollections(ctx context.Context, filter ticket.TicketCollectionFilter) (*ticket.TicketCollections, error) {
 	coll := q.collection()
 
-	var query bson.M
-
-	// TODO: add filter
-	if filter.IssuerAddress != "" {
-		query = bson.M{"issuerAddress": filter.IssuerAddress}
+	query, err := collectionQuery(filter, time.Now().Unix())
+	if err != nil {
+		return nil, err
 	}
 
-	// decreasing order
-	opts := &options.FindOptions{
-		Sort: bson.M{"createdAt": -1},
+	opts := options.Find().SetSort(collectionSort(filter))
+
+	var p *pagination.Pagination
+
+	if filter.Limit > 0 {
+		total, err := coll.CountDocuments(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		p = pagination.New(total, filter.Page, filter.Limit)
+
+		if total == 0 {
+			return &ticket.TicketCollections{
+				Items:      []*ticket.TicketCollection{},
+				Pagination: p,
+			}, nil
+		}
+
+		opts.SetSkip(p.Skip()).SetLimit(p.Limit)
 	}
 
 	cur, err := coll.Find(ctx, query, opts)
 	if err != nil {
 		return nil, err
 	}
+	defer cur.Close(ctx)
 
-	var tcs []*ticket.TicketCollection
+	tcs := []*ticket.TicketCollection{}
 
 	for cur.Next(ctx) {
 		var tc ticket.TicketCollection
@@ -112,9 +150,88 @@ func (q *MongoQuery) FindTicketCollections(ctx context.Context, filter ticket.Ti
 		tcs = append(tcs, &tc)
 	}
 
-	return tcs, nil
+	return &ticket.TicketCollections{
+		Items:      tcs,
+		Pagination: p,
+	}, nil
+}
+
+func (q *MongoQuery) SearchTicketCollections(ctx context.Context, search ticket.TicketCollectionSearch) (*ticket.TicketCollectionSearchResults, error) {
+	coll := q.collection()
+
+	query := bson.M{"$text": bson.M{"$search": search.Query}}
+
+	total, err := coll.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := pagination.New(total, search.Page, search.Limit)
+
+	if total == 0 {
+		return &ticket.TicketCollectionSearchResults{
+			Items:      []*ticket.TicketCollectionSearchResult{},
+			Pagination: p,
+		}, nil
+	}
+
+	score := bson.M{"$meta": "textScore"}
+
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cur, err := coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	results := []*ticket.TicketCollectionSearchResult{}
+
+	for cur.Next(ctx) {
+		var result ticket.TicketCollectionSearchResult
+
+		if err := cur.Decode(&result); err != nil {
+			return nil, err
+		}
+
+		results = append(results, &result)
+	}
+
+	return &ticket.TicketCollectionSearchResults{
+		Items:      results,
+		Pagination: p,
+	}, nil
+}
+
+func (q *MongoQuery) FindTBAByAddress(ctx context.Context, tbaAddress string) (*ticket.TBA, error) {
+	coll := q.tbaCollection()
+
+	filter := bson.M{"_id": tbaAddress}
+
+	var tba ticket.TBA
+
+	if err := coll.FindOne(ctx, filter).Decode(&tba); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ticket.ErrTBANotFound
+		}
+		return nil, err
+	}
+
+	return &tba, nil
 }
 
 func (q *MongoQuery) collection() *mongo.Collection {
 	return q.client.Database(q.dbname).Collection("tickets")
 }
+
+func (q *MongoQuery) ticketCollection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("ticket_tokens")
+}
+
+func (q *MongoQuery) tbaCollection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("tbas")
+}
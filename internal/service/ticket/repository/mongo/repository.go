@@ -4,7 +4,9 @@ import (
 	"context"
 
 	"github.com/heroticket/internal/service/ticket"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoRepository struct {
@@ -24,5 +26,72 @@ func New(ctx context.Context, client *mongo.Client, dbname string) (ticket.Repos
 		dbname:  dbname,
 	}
 
-	return repo, nil
+	_, err := cmd.collection().Indexes().CreateMany(
+		ctx,
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.M{"contractAddress": 1},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.M{"issuerAddress": 1},
+			},
+			{
+				Keys: bson.D{{Key: "saleEndAt", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: ethPriceNumField, Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: tokenPriceNumField, Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{
+					{Key: "name", Value: "text"},
+					{Key: "symbol", Value: "text"},
+					{Key: "description", Value: "text"},
+					{Key: "organizer", Value: "text"},
+					{Key: "location", Value: "text"},
+				},
+				// name and symbol matches rank above matches buried in the description
+				Options: options.Index().SetName("collection_text").SetWeights(bson.D{
+					{Key: "name", Value: 10},
+					{Key: "symbol", Value: 5},
+					{Key: "organizer", Value: 3},
+					{Key: "location", Value: 3},
+					{Key: "description", Value: 1},
+				}),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// collections stored before numeric prices were introduced
+	_, err = cmd.collection().UpdateMany(
+		ctx,
+		bson.M{ethPriceNumField: bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.D{
+				{Key: ethPriceNumField, Value: bson.M{"$toDecimal": "$ethPrice"}},
+				{Key: tokenPriceNumField, Value: bson.M{"$toDecimal": "$tokenPrice"}},
+			}}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = cmd.ticketCollection().Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.M{"ownerAddress": 1},
+		},
+	)
+
+	return repo, err
 }
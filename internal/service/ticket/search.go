@@ -0,0 +1,77 @@
+package ticket
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchFields are the ticket collection fields covered by full-text search, in highlight order.
+var SearchFields = []string{"name", "symbol", "description", "organizer", "location"}
+
+// MatchedFields returns the search fields of tc that contain any term of query.
+// Terms are compared case-insensitively, and a trailing plural suffix is ignored so that
+// stemmed text index matches (e.g. "concerts" for "concert") are still highlighted.
+func MatchedFields(tc *TicketCollection, query string) []string {
+	terms := searchTerms(query)
+
+	values := map[string]string{
+		"name":        tc.Name,
+		"symbol":      tc.Symbol,
+		"description": tc.Description,
+		"organizer":   tc.Organizer,
+		"location":    tc.Location,
+	}
+
+	matches := []string{}
+
+	for _, field := range SearchFields {
+		value := strings.ToLower(values[field])
+		if value == "" {
+			continue
+		}
+
+		for _, term := range terms {
+			if strings.Contains(value, term) {
+				matches = append(matches, field)
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+// searchTerms splits query into lowercase terms, dropping text index operators
+// such as quotes and negation.
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+
+	for _, word := range words {
+		terms = append(terms, singular(word))
+	}
+
+	return terms
+}
+
+// singular strips an English plural suffix: "es" after ss, x, z, ch and sh ("classes", "boxes"), a
+// lone "s" otherwise ("cases", "concerts"). Short words and words ending in "ss" are kept.
+func singular(word string) string {
+	if len(word) <= 3 || !strings.HasSuffix(word, "s") || strings.HasSuffix(word, "ss") {
+		return word
+	}
+
+	stem := strings.TrimSuffix(word, "es")
+	if stem != word && len(stem) > 2 {
+		for _, suffix := range []string{"ss", "x", "z", "ch", "sh"} {
+			if strings.HasSuffix(stem, suffix) {
+				return stem
+			}
+		}
+	}
+
+	return strings.TrimSuffix(word, "s")
+}
@@ -0,0 +1,64 @@
+package ticket
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"concerts", []string{"concert"}},
+		{"cases", []string{"case"}},
+		{"stages", []string{"stage"}},
+		{"classes", []string{"class"}},
+		{"boxes", []string{"box"}},
+		{"waltzes", []string{"waltz"}},
+		{"churches", []string{"church"}},
+		{"wishes", []string{"wish"}},
+		{"class", []string{"class"}},
+		{"bus", []string{"bus"}},
+		{"yes", []string{"yes"}},
+		{"Jazz Nights", []string{"jazz", "night"}},
+		{`"rock festivals" -tickets`, []string{"rock", "festival", "ticket"}},
+		{"2024 ", []string{"2024"}},
+		{"  ", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := searchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMatchedFields(t *testing.T) {
+	tc := &TicketCollection{
+		Name:        "Summer Concert",
+		Symbol:      "SUMR",
+		Description: "Two stages of live music",
+		Organizer:   "Seoul Classes",
+		Location:    "Olympic Park",
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"concerts", []string{"name"}},
+		{"STAGES", []string{"description"}},
+		{"class", []string{"organizer"}},
+		{"summer park", []string{"name", "location"}},
+		{"sumr", []string{"symbol"}},
+		{"casual", []string{}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := MatchedFields(tc, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MatchedFields(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -2,21 +2,24 @@ package ticket
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"encoding/json"
 	"fmt"
 	"math/big"
-	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 
+	"github.com/heroticket/internal/web3"
 	"github.com/heroticket/pkg/contracts/heroticket"
 )
 
 type Service interface {
+	ChainID() int64
 	TbaByAddress(ctx context.Context, owner common.Address) (*common.Address, error)
 	IsIssuedTicket(ctx context.Context, contractAddress common.Address) (bool, error)
 	HasTicket(ctx context.Context, contractAddress, owner common.Address) (bool, error)
@@ -28,30 +31,78 @@ type Service interface {
 	CreateTBA(ctx context.Context, to common.Address, tokenURI string) (*heroticket.HeroticketTBACreated, error)
 	IssueTicket(ctx context.Context, params IssueTicketParams) (*heroticket.HeroticketTicketIssued, error)
 	BuyTicketByToken(ctx context.Context, contractAddress, buyerAddress common.Address) (*heroticket.HeroticketTicketSold, error)
+	PrepareBuyTicketByEther(ctx context.Context, contractAddress, buyerAddress common.Address) (*EthPurchaseTx, error)
+	ConfirmBuyTicketByEther(ctx context.Context, txHash common.Hash, contractAddress, buyerAddress common.Address) (*heroticket.HeroticketTicketSold, error)
 
 	CreateTicketCollection(ctx context.Context, params CreateTicketCollectionParams) (*TicketCollection, error)
+	UpdateProofPolicy(ctx context.Context, contractAddress string, policy *ProofPolicy) error
+	SyncTicketCollection(ctx context.Context, contractAddress common.Address) (*OnchainTicketInfo, error)
+	ReconcileTicketCollections(ctx context.Context) (int, error)
 	GetOwnedNFT(ctx context.Context, owner common.Address) (OwnedNFT, error)
 	FindTicketCollectionByContractAddress(ctx context.Context, contractAddress string) (*TicketCollection, error)
-	FindTicketCollections(ctx context.Context, filter TicketCollectionFilter) ([]*TicketCollection, error)
+	FindTicketsOfCollection(ctx context.Context, contractAddress, owner common.Address) ([]*Ticket, error)
+	FindTicketCollections(ctx context.Context, filter TicketCollectionFilter) (*TicketCollections, error)
+	SearchTicketCollections(ctx context.Context, search TicketCollectionSearch) (*TicketCollectionSearchResults, error)
+}
+
+type TicketServiceConfig struct {
+	ChainID         int64
+	Client          *ethclient.Client
+	Hero            *heroticket.Heroticket
+	ContractAddress common.Address
+	TxManager       *web3.TxManager
+	Repo            Repository
+	// OwnedNFTs lists the NFTs held by tbas, NewLocalProvider over Repo when nil
+	OwnedNFTs OwnedNFTProvider
+
+	// GasLimitMultiplier scales estimated gas limits, DefaultGasLimitMultiplier when below 1
+	GasLimitMultiplier float64
+	// MaxFeePerGas is the highest fee per gas in wei server transactions may pay; nil means no ceiling
+	MaxFeePerGas *big.Int
 }
 
 type TicketService struct {
-	client *ethclient.Client
-	hero   *heroticket.Heroticket
-	pvk    *ecdsa.PrivateKey
-	repo   Repository
+	chainID         int64
+	client          *ethclient.Client
+	hero            *heroticket.Heroticket
+	contractAddress common.Address
+	txm             *web3.TxManager
+	repo            Repository
 
-	moralisApiKey string
+	ownedNFTs OwnedNFTProvider
+
+	gasLimitMultiplier float64
+	maxFeePerGas       *big.Int
 }
 
-func New(client *ethclient.Client, hero *heroticket.Heroticket, pvk *ecdsa.PrivateKey, repo Repository, moralisApiKey string) Service {
-	return &TicketService{
-		client:        client,
-		hero:          hero,
-		pvk:           pvk,
-		repo:          repo,
-		moralisApiKey: moralisApiKey,
+func New(cfg TicketServiceConfig) Service {
+	svc := &TicketService{
+		chainID:         cfg.ChainID,
+		client:          cfg.Client,
+		hero:            cfg.Hero,
+		contractAddress: cfg.ContractAddress,
+		txm:             cfg.TxManager,
+		repo:            cfg.Repo,
+		ownedNFTs:       cfg.OwnedNFTs,
+
+		gasLimitMultiplier: DefaultGasLimitMultiplier,
+		maxFeePerGas:       cfg.MaxFeePerGas,
+	}
+
+	if svc.ownedNFTs == nil {
+		svc.ownedNFTs = NewLocalProvider(cfg.Repo, cfg.Hero)
+	}
+
+	if cfg.GasLimitMultiplier >= 1 {
+		svc.gasLimitMultiplier = cfg.GasLimitMultiplier
 	}
+
+	return svc
+}
+
+// ChainID returns the chain id of the network the service reads and writes.
+func (s *TicketService) ChainID() int64 {
+	return s.chainID
 }
 
 func (s *TicketService) TbaByAddress(ctx context.Context, owner common.Address) (*common.Address, error) {
@@ -109,95 +160,137 @@ func (s *TicketService) TokenBalanceOf(ctx context.Context, owner common.Address
 }
 
 func (s *TicketService) UpdateWhitelist(ctx context.Context, contractAddress, to common.Address) error {
-	auth, err := s.txOpts(ctx)
-	if err != nil {
-		return err
-	}
-
-	tx, err := s.hero.UpdateWhiteList(auth, contractAddress, to)
-	if err != nil {
-		return err
-	}
-
-	_, err = bind.WaitMined(ctx, s.client, tx)
+	_, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.hero.UpdateWhiteList(auth, contractAddress, to)
+	})
 	return err
 }
 
 func (s *TicketService) CreateTBA(ctx context.Context, to common.Address, tokenURI string) (*heroticket.HeroticketTBACreated, error) {
-	auth, err := s.txOpts(ctx)
+	receipt, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.hero.CreateTBA(auth, to, tokenURI)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.hero.CreateTBA(auth, to, tokenURI)
-	if err != nil {
-		return nil, err
+	for _, log := range receipt.Logs {
+		if log.Address != s.contractAddress {
+			continue
+		}
+
+		tbaCreated, err := s.hero.ParseTBACreated(*log)
+		if err == nil {
+			return tbaCreated, nil
+		}
 	}
 
-	receipt, err := bind.WaitMined(ctx, s.client, tx)
+	return nil, ErrTBACreatedNotFound
+}
+
+func (s *TicketService) IssueTicket(ctx context.Context, params IssueTicketParams) (*heroticket.HeroticketTicketIssued, error) {
+	receipt, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.hero.IssueTicket(auth, params.TicketName, params.TicketSymbol, params.TicketUri, params.Issuer,
+			params.TicketAmount, params.TicketEthPrice, params.TicketTokenPrice, params.SaleDuration)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// 이 방법 안되면 filter 사용이나 tbaAddress를 직접 받아서 사용
-	var tbaCreated *heroticket.HeroticketTBACreated
-
 	for _, log := range receipt.Logs {
+		if log.Address != s.contractAddress {
+			continue
+		}
 
-		tbaCreated, err = s.hero.ParseTBACreated(*log)
+		ticketIssued, err := s.hero.ParseTicketIssued(*log)
 		if err == nil {
-			break
+			return ticketIssued, nil
 		}
 	}
 
-	if tbaCreated == nil {
+	return nil, ErrTicketIssuedNotFound
+}
+
+func (s *TicketService) BuyTicketByToken(ctx context.Context, contractAddress, buyerAddress common.Address) (*heroticket.HeroticketTicketSold, error) {
+	receipt, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.hero.BuyTicketByToken(auth, contractAddress, buyerAddress)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return tbaCreated, nil
+	for _, log := range receipt.Logs {
+		if log.Address != s.contractAddress {
+			continue
+		}
+
+		ticketSold, err := s.hero.ParseTicketSold(*log)
+		if err == nil && ticketSold.TicketAddress == contractAddress {
+			return ticketSold, nil
+		}
+	}
+
+	return nil, ErrTicketSoldNotFound
 }
 
-func (s *TicketService) IssueTicket(ctx context.Context, params IssueTicketParams) (*heroticket.HeroticketTicketIssued, error) {
-	auth, err := s.txOpts(ctx)
+// PrepareBuyTicketByEther builds an unsigned buyTicketByEther transaction paying the on-chain eth price.
+// The buyer signs and sends it from their own wallet, then confirms it with ConfirmBuyTicketByEther.
+func (s *TicketService) PrepareBuyTicketByEther(ctx context.Context, contractAddress, buyerAddress common.Address) (*EthPurchaseTx, error) {
+	info, err := s.OnChainTicketInfo(ctx, contractAddress)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.hero.IssueTicket(auth, params.TicketName, params.TicketSymbol, params.TicketUri, params.Issuer,
-		params.TicketAmount, params.TicketEthPrice, params.TicketTokenPrice, params.SaleDuration)
+	now := time.Now().Unix()
+
+	if info.Remaining.Sign() == 0 || info.SaleStartAt.Int64() > now || info.SaleEndAt.Int64() < now {
+		return nil, ErrTicketNotOnSale
+	}
+
+	contractAbi, err := heroticket.HeroticketMetaData.GetAbi()
 	if err != nil {
 		return nil, err
 	}
 
-	receipt, err := bind.WaitMined(ctx, s.client, tx)
+	data, err := contractAbi.Pack("buyTicketByEther", contractAddress)
 	if err != nil {
 		return nil, err
 	}
 
-	var ticketIssued *heroticket.HeroticketTicketIssued
-
-	for _, log := range receipt.Logs {
-		ticketIssued, err = s.hero.ParseTicketIssued(*log)
-		if err == nil {
-			break
-		}
+	gas, err := s.client.EstimateGas(ctx, ethereum.CallMsg{
+		From:  buyerAddress,
+		To:    &s.contractAddress,
+		Value: info.EthPrice,
+		Data:  data,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if ticketIssued == nil {
+	chainID, err := s.client.ChainID(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	return ticketIssued, nil
+	return &EthPurchaseTx{
+		From:    buyerAddress.Hex(),
+		To:      s.contractAddress.Hex(),
+		Value:   info.EthPrice.String(),
+		Data:    hexutil.Encode(data),
+		Gas:     s.gasLimit(gas),
+		ChainID: chainID.String(),
+	}, nil
 }
 
-func (s *TicketService) BuyTicketByToken(ctx context.Context, contractAddress, buyerAddress common.Address) (*heroticket.HeroticketTicketSold, error) {
-	auth, err := s.txOpts(ctx)
+// ConfirmBuyTicketByEther checks that txHash is a buyTicketByEther call for contractAddress sent by buyerAddress,
+// waits for it to be mined and returns its TicketSold event.
+func (s *TicketService) ConfirmBuyTicketByEther(ctx context.Context, txHash common.Hash, contractAddress, buyerAddress common.Address) (*heroticket.HeroticketTicketSold, error) {
+	tx, _, err := s.client.TransactionByHash(ctx, txHash)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.hero.BuyTicketByToken(auth, contractAddress, buyerAddress)
-	if err != nil {
+	if err := s.checkEthPurchaseTx(tx, contractAddress, buyerAddress); err != nil {
 		return nil, err
 	}
 
@@ -206,90 +299,176 @@ func (s *TicketService) BuyTicketByToken(ctx context.Context, contractAddress, b
 		return nil, err
 	}
 
-	var ticketSold *heroticket.HeroticketTicketSold
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, ErrTxFailed
+	}
 
 	for _, log := range receipt.Logs {
-		ticketSold, err = s.hero.ParseTicketSold(*log)
-		if err == nil {
-			break
+		if log.Address != s.contractAddress {
+			continue
+		}
+
+		ticketSold, err := s.hero.ParseTicketSold(*log)
+		if err == nil && ticketSold.TicketAddress == contractAddress {
+			return ticketSold, nil
 		}
 	}
 
-	if ticketSold == nil {
-		return nil, err
+	return nil, ErrTicketSoldNotFound
+}
+
+func (s *TicketService) checkEthPurchaseTx(tx *types.Transaction, contractAddress, buyerAddress common.Address) error {
+	if tx.To() == nil || *tx.To() != s.contractAddress {
+		return ErrInvalidPurchaseTx
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil || sender != buyerAddress {
+		return ErrInvalidPurchaseTx
+	}
+
+	contractAbi, err := heroticket.HeroticketMetaData.GetAbi()
+	if err != nil {
+		return err
 	}
 
-	return ticketSold, nil
+	method, err := contractAbi.MethodById(tx.Data())
+	if err != nil || method.Name != "buyTicketByEther" {
+		return ErrInvalidPurchaseTx
+	}
+
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil || len(args) != 1 {
+		return ErrInvalidPurchaseTx
+	}
+
+	if ticketAddress, ok := args[0].(common.Address); !ok || ticketAddress != contractAddress {
+		return ErrInvalidPurchaseTx
+	}
+
+	return nil
 }
 
+// GetOwnedNFT returns the NFTs held by the tba of owner.
 func (s *TicketService) GetOwnedNFT(ctx context.Context, owner common.Address) (OwnedNFT, error) {
 	tbaAddress, err := s.hero.TbaAddress(&bind.CallOpts{Context: ctx}, owner)
 	if err != nil {
 		return OwnedNFT{}, err
 	}
 
-	url := fmt.Sprintf("https://deep-index.moralis.io/api/v2.2/%s/nft?chain=mumbai&format=decimal&media_items=false", tbaAddress.String())
+	return s.ownedNFTs.OwnedNFT(ctx, tbaAddress)
+}
 
-	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+// transact sends the transaction built by fn through the tx manager, which assigns the nonce,
+// and waits for it to be mined. The binding estimates the gas limit of each call, which is then
+// scaled by the gas limit multiplier. Errors after the transaction was sent wrap ErrTxNotConfirmed,
+// since the transaction may still be mined later, and a reverted transaction returns ErrTxFailed.
+func (s *TicketService) transact(ctx context.Context, fn func(auth *bind.TransactOpts) (*types.Transaction, error)) (*types.Receipt, error) {
+	tx, err := s.txm.Transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		if err := s.setFees(ctx, auth); err != nil {
+			return nil, err
+		}
 
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-API-Key", s.moralisApiKey)
+		tx, err := fn(auth)
+		if err != nil {
+			return nil, err
+		}
 
-	res, err := http.DefaultClient.Do(req)
+		return s.scaleGasLimit(auth, tx)
+	})
 	if err != nil {
-		return OwnedNFT{}, err
+		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return OwnedNFT{}, fmt.Errorf("failed to get owned nft, status code: %d", res.StatusCode)
+	receipt, err := s.txm.WaitMined(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTxNotConfirmed, err)
 	}
-	defer res.Body.Close()
 
-	var result OwnedNFT
-	dec := json.NewDecoder(res.Body)
-	if err := dec.Decode(&result); err != nil {
-		return OwnedNFT{}, err
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, ErrTxFailed
+	}
+
+	return receipt, nil
+}
+
+func (s *TicketService) CreateTicketCollection(ctx context.Context, params CreateTicketCollectionParams) (*TicketCollection, error) {
+	params.ChainID = s.chainID
+
+	if params.ProofPolicy != nil {
+		if err := params.ProofPolicy.Validate(); err != nil {
+			return nil, err
+		}
 	}
 
-	return result, nil
+	return s.repo.CreateTicketCollection(ctx, params)
 }
 
-func (s *TicketService) txOpts(ctx context.Context) (*bind.TransactOpts, error) {
-	address := crypto.PubkeyToAddress(s.pvk.PublicKey)
+// UpdateProofPolicy replaces the proof policy of a ticket collection, clearing it when policy is nil.
+func (s *TicketService) UpdateProofPolicy(ctx context.Context, contractAddress string, policy *ProofPolicy) error {
+	if policy != nil {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.UpdateProofPolicy(ctx, strings.ToLower(contractAddress), policy)
+}
 
-	nonce, err := s.client.PendingNonceAt(ctx, address)
+// SyncTicketCollection refreshes the stored remaining supply, prices and sale period
+// of a ticket collection from the contract.
+func (s *TicketService) SyncTicketCollection(ctx context.Context, contractAddress common.Address) (*OnchainTicketInfo, error) {
+	info, err := s.OnChainTicketInfo(ctx, contractAddress)
 	if err != nil {
 		return nil, err
 	}
 
-	gasPrice, err := s.client.SuggestGasPrice(ctx)
+	err = s.repo.UpdateTicketCollection(ctx, UpdateTicketCollectionParamsFromOnchain(info))
 	if err != nil {
 		return nil, err
 	}
 
-	chainID, err := s.client.ChainID(ctx)
+	return info, nil
+}
+
+// ReconcileTicketCollections re-reads the on-chain ticket info of every stored collection
+// and fixes drifted values. It returns the number of collections that were updated.
+func (s *TicketService) ReconcileTicketCollections(ctx context.Context) (int, error) {
+	collections, err := s.repo.FindTicketCollections(ctx, TicketCollectionFilter{})
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+
+	return Reconcile(ctx, s.repo, collections.Items, s.OnChainTicketInfo)
+}
+
+func (s *TicketService) FindTicketCollections(ctx context.Context, filter TicketCollectionFilter) (*TicketCollections, error) {
+	return s.repo.FindTicketCollections(ctx, filter)
+}
+
+// SearchTicketCollections runs a full-text search over ticket collections ranked by relevance
+// and marks which fields of each result matched the query.
+func (s *TicketService) SearchTicketCollections(ctx context.Context, search TicketCollectionSearch) (*TicketCollectionSearchResults, error) {
+	search.Query = strings.TrimSpace(search.Query)
+	if search.Query == "" {
+		return nil, ErrEmptySearchQuery
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(s.pvk, chainID)
+	results, err := s.repo.SearchTicketCollections(ctx, search)
 	if err != nil {
 		return nil, err
 	}
 
-	auth.GasPrice = gasPrice
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.GasLimit = 3000000
+	for _, result := range results.Items {
+		result.Matches = MatchedFields(&result.TicketCollection, search.Query)
+	}
 
-	return auth, nil
+	return results, nil
 }
 
-func (s *TicketService) CreateTicketCollection(ctx context.Context, params CreateTicketCollectionParams) (*TicketCollection, error) {
-	return s.repo.CreateTicketCollection(ctx, params)
-}
-
-func (s *TicketService) FindTicketCollections(ctx context.Context, filter TicketCollectionFilter) ([]*TicketCollection, error) {
-	return s.repo.FindTicketCollections(ctx, filter)
+// FindTicketsOfCollection returns the indexed tickets of a collection held by owner, by token id.
+func (s *TicketService) FindTicketsOfCollection(ctx context.Context, contractAddress, owner common.Address) ([]*Ticket, error) {
+	return s.repo.FindTicketsOfCollection(ctx, strings.ToLower(contractAddress.Hex()), strings.ToLower(owner.Hex()))
 }
 
 func (s *TicketService) FindTicketCollectionByContractAddress(ctx context.Context, contractAddress string) (*TicketCollection, error) {
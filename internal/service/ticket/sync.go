@@ -0,0 +1,69 @@
+package ticket
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// OnchainTicketInfoFunc reads the on-chain ticket info of a ticket collection.
+type OnchainTicketInfoFunc func(ctx context.Context, contractAddress common.Address) (*OnchainTicketInfo, error)
+
+// UpdateTicketCollectionParamsFromOnchain returns update params that overwrite the stored
+// remaining supply, prices and sale period with on-chain values.
+func UpdateTicketCollectionParamsFromOnchain(info *OnchainTicketInfo) UpdateTicketCollectionParams {
+	return UpdateTicketCollectionParams{
+		ContractAddress: strings.ToLower(info.ContractAddress.Hex()),
+		EthPrice:        info.EthPrice.String(),
+		TokenPrice:      info.TokenPrice.String(),
+		Remaining:       info.Remaining.String(),
+		SaleStartAt:     info.SaleStartAt.Int64(),
+		SaleEndAt:       info.SaleEndAt.Int64(),
+	}
+}
+
+// Reconcile compares each collection with its on-chain ticket info and updates the ones that drifted.
+// A failure on one collection does not stop the others; the first error is returned along with
+// the number of collections that were updated.
+func Reconcile(ctx context.Context, cmd Command, collections []*TicketCollection, infoFn OnchainTicketInfoFunc) (int, error) {
+	var (
+		updated  int
+		firstErr error
+	)
+
+	for _, tc := range collections {
+		info, err := infoFn(ctx, common.HexToAddress(tc.ContractAddress))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		params := UpdateTicketCollectionParamsFromOnchain(info)
+
+		if !drifted(tc, params) {
+			continue
+		}
+
+		if err := cmd.UpdateTicketCollection(ctx, params); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		updated++
+	}
+
+	return updated, firstErr
+}
+
+func drifted(tc *TicketCollection, params UpdateTicketCollectionParams) bool {
+	return tc.Remaining != params.Remaining ||
+		tc.EthPrice != params.EthPrice ||
+		tc.TokenPrice != params.TokenPrice ||
+		tc.SaleStartAt != params.SaleStartAt ||
+		tc.SaleEndAt != params.SaleEndAt
+}
@@ -5,15 +5,28 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/heroticket/internal/pagination"
 )
 
 var (
+	ErrNothingToUpdate          = errors.New("nothing to update")
 	ErrTicketNotFound           = errors.New("ticket not found")
+	ErrTicketAlreadyExists      = errors.New("ticket already exists")
 	ErrTicketCollectionNotFound = errors.New("ticket collection not found")
+	ErrTBANotFound              = errors.New("tba not found")
+	ErrEmptySearchQuery         = errors.New("empty search query")
+	ErrTicketNotOnSale          = errors.New("ticket is not on sale")
+	ErrInvalidPurchaseTx        = errors.New("transaction is not a purchase of this ticket")
+	ErrTxFailed                 = errors.New("transaction failed")
+	ErrTxNotConfirmed           = errors.New("transaction sent but not confirmed")
+	ErrTicketSoldNotFound       = errors.New("ticket sold event not found")
+	ErrTBACreatedNotFound       = errors.New("tba created event not found")
+	ErrTicketIssuedNotFound     = errors.New("ticket issued event not found")
 )
 
 type TicketCollection struct {
 	ID              string `json:"id" bson:"_id,omitempty"`
+	ChainID         int64  `json:"chainId" bson:"chainId"`
 	ContractAddress string `json:"contractAddress" bson:"contractAddress"`
 	IssuerAddress   string `json:"issuerAddress" bson:"issuerAddress"`
 	Name            string `json:"name" bson:"name"`
@@ -30,8 +43,10 @@ type TicketCollection struct {
 	Remaining       string `json:"remaining" bson:"remaining"`
 	SaleStartAt     int64  `json:"saleStartAt" bson:"saleStartAt"`
 	SaleEndAt       int64  `json:"saleEndAt" bson:"saleEndAt"`
-	CreatedAt       int64  `json:"createdAt" bson:"createdAt"`
-	UpdatedAt       int64  `json:"updatedAt" bson:"updatedAt"`
+	// ProofPolicy is the extra proofs holders present, nil when the collection asks for none
+	ProofPolicy *ProofPolicy `json:"proofPolicy,omitempty" bson:"proofPolicy,omitempty"`
+	CreatedAt   int64        `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   int64        `json:"updatedAt" bson:"updatedAt"`
 }
 
 type TicketCollectionDetail struct {
@@ -50,6 +65,14 @@ type Ticket struct {
 	PurchasedAt  int64  `json:"purchasedAt" bson:"purchasedAt"`
 }
 
+type TBA struct {
+	ID           string `json:"id" bson:"_id"`
+	OwnerAddress string `json:"ownerAddress" bson:"ownerAddress"`
+	TokenID      uint64 `json:"tokenId" bson:"tokenId"`
+	BlockNumber  uint64 `json:"blockNumber" bson:"blockNumber"`
+	CreatedAt    int64  `json:"createdAt" bson:"createdAt"`
+}
+
 type OnchainTicketInfo struct {
 	ContractAddress common.Address
 	Issuer          common.Address
@@ -61,6 +84,7 @@ type OnchainTicketInfo struct {
 }
 
 type CreateTicketCollectionParams struct {
+	ChainID         int64
 	ContractAddress string
 	IssuerAddress   string
 	Name            string
@@ -77,6 +101,16 @@ type CreateTicketCollectionParams struct {
 	Remaining       string
 	SaleStartAt     int64
 	SaleEndAt       int64
+	ProofPolicy     *ProofPolicy
+}
+
+type UpdateTicketCollectionParams struct {
+	ContractAddress string
+	EthPrice        string
+	TokenPrice      string
+	Remaining       string
+	SaleStartAt     int64
+	SaleEndAt       int64
 }
 
 type SaveTicketParams struct {
@@ -87,6 +121,13 @@ type SaveTicketParams struct {
 	PurchasedAt  int64
 }
 
+type SaveTBAParams struct {
+	TbaAddress   string
+	OwnerAddress string
+	TokenID      uint64
+	BlockNumber  uint64
+}
+
 type IssueTicketParams struct {
 	TicketName       string
 	TicketSymbol     string
@@ -98,9 +139,97 @@ type IssueTicketParams struct {
 	SaleDuration     *big.Int
 }
 
+// EthPurchaseTx is an unsigned buyTicketByEther transaction for the buyer's wallet to sign and send.
+type EthPurchaseTx struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Value   string `json:"value"`
+	Data    string `json:"data"`
+	Gas     uint64 `json:"gas"`
+	ChainID string `json:"chainId"`
+}
+
+type SaleStatus string
+
+const (
+	SaleStatusOnSale   SaleStatus = "onSale"
+	SaleStatusSoldOut  SaleStatus = "soldOut"
+	SaleStatusUpcoming SaleStatus = "upcoming"
+)
+
+func (s SaleStatus) Valid() bool {
+	return s == SaleStatusOnSale || s == SaleStatusSoldOut || s == SaleStatusUpcoming
+}
+
+type SortField string
+
+const (
+	SortBySaleEndAt  SortField = "saleEndAt"
+	SortByCreatedAt  SortField = "createdAt"
+	SortByEthPrice   SortField = "ethPrice"
+	SortByTokenPrice SortField = "tokenPrice"
+)
+
+func (f SortField) Valid() bool {
+	return f == SortBySaleEndAt || f == SortByCreatedAt || f == SortByEthPrice || f == SortByTokenPrice
+}
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+func (o SortOrder) Valid() bool {
+	return o == SortAsc || o == SortDesc
+}
+
 type TicketCollectionFilter struct {
-	// TODO: add filter options
 	IssuerAddress string
+	Organizer     string
+	Location      string
+	Status        SaleStatus
+
+	// price bounds are inclusive, in wei for eth and in token units for token
+	MinEthPrice   string
+	MaxEthPrice   string
+	MinTokenPrice string
+	MaxTokenPrice string
+
+	// SaleFrom and SaleTo (unix seconds) match collections whose sale period overlaps the range
+	SaleFrom int64
+	SaleTo   int64
+
+	SortBy    SortField
+	SortOrder SortOrder
+
+	// Limit 0 returns every matching collection without pagination
+	Page  int64
+	Limit int64
+}
+
+type TicketCollections struct {
+	Items      []*TicketCollection    `json:"items"`
+	Pagination *pagination.Pagination `json:"pagination,omitempty"`
+}
+
+type TicketCollectionSearch struct {
+	Query string
+
+	Page  int64
+	Limit int64
+}
+
+type TicketCollectionSearchResult struct {
+	TicketCollection `bson:",inline"`
+	Score            float64  `json:"score" bson:"score"`
+	Matches          []string `json:"matches" bson:"-"`
+}
+
+type TicketCollectionSearchResults struct {
+	Items      []*TicketCollectionSearchResult `json:"items"`
+	Pagination *pagination.Pagination          `json:"pagination"`
 }
 
 type OwnedNFT struct {
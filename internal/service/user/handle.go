@@ -0,0 +1,67 @@
+package user
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidHandle  = errors.New("handle must be 3 to 20 letters, digits or underscores, starting with a letter")
+	ErrHandleBlocked  = errors.New("handle is not allowed")
+	ErrHandleTaken    = errors.New("handle is already taken")
+	ErrHandleCooldown = errors.New("handle was changed too recently")
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{2,19}$`)
+
+// reservedHandles are names of the service and its routes, blocked when a handle reads as one
+var reservedHandles = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "support": true, "help": true,
+	"security": true, "team": true, "staff": true, "mod": true, "moderator": true, "official": true,
+	"heroticket": true, "api": true, "www": true, "me": true, "profile": true, "settings": true,
+	"login": true, "logout": true, "users": true, "notices": true, "tickets": true, "null": true, "undefined": true,
+}
+
+// blockedHandleWords are blocked anywhere in a handle, to stop impersonation such as "heroticket_admin"
+var blockedHandleWords = []string{"admin", "heroticket", "official", "moderator"}
+
+// handleLookalikes map characters used to dodge the blocklist to the letter they stand for
+var handleLookalikes = strings.NewReplacer("_", "", "0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t")
+
+// HandleKey returns the case-insensitive form handles are unique by.
+func HandleKey(handle string) string {
+	return strings.ToLower(handle)
+}
+
+// ValidateHandle checks the format of a handle and that it does not read as a reserved name or contain
+// a blocked word, including the extra blocked words.
+func ValidateHandle(handle string, blocked []string) error {
+	if !handlePattern.MatchString(handle) {
+		return ErrInvalidHandle
+	}
+
+	plain := handleLookalikes.Replace(HandleKey(handle))
+
+	if reservedHandles[plain] {
+		return ErrHandleBlocked
+	}
+
+	for _, words := range [][]string{blockedHandleWords, blocked} {
+		for _, word := range words {
+			if word != "" && strings.Contains(plain, handleLookalikes.Replace(strings.ToLower(word))) {
+				return ErrHandleBlocked
+			}
+		}
+	}
+
+	return nil
+}
+
+type SetHandleParams struct {
+	ID     string
+	Handle string
+	// PrevChangedAt is when the user last changed their handle, the change fails with ErrUserChanged
+	// when it changed since
+	PrevChangedAt int64
+}
@@ -0,0 +1,29 @@
+package user
+
+import "testing"
+
+func TestValidateHandle(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   error
+	}{
+		{"alice", nil},
+		{"Alice_99", nil},
+		{"al", ErrInvalidHandle},
+		{"9lives", ErrInvalidHandle},
+		{"alice.eth", ErrInvalidHandle},
+		{"this_handle_is_far_too_long", ErrInvalidHandle},
+		{"Admin", ErrHandleBlocked},
+		{"r00t", ErrHandleBlocked},
+		{"s_u_p_p_o_r_t", ErrHandleBlocked},
+		{"heroticket_help", ErrHandleBlocked},
+		{"real_4dm1n", ErrHandleBlocked},
+		{"badword_fan", ErrHandleBlocked},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateHandle(tt.handle, []string{"BadWord"}); err != tt.want {
+			t.Errorf("ValidateHandle(%q) = %v, want %v", tt.handle, err, tt.want)
+		}
+	}
+}
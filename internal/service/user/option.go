@@ -0,0 +1,27 @@
+package user
+
+import "time"
+
+var defaultHandleCooldown = time.Hour * 24 * 30
+
+type Option func(*userService)
+
+func WithDefaultOptions() Option {
+	return func(s *userService) {
+		s.handleCooldown = defaultHandleCooldown
+	}
+}
+
+// WithHandleCooldown sets how long a user waits between handle changes, setting the first one is immediate.
+func WithHandleCooldown(cooldown time.Duration) Option {
+	return func(s *userService) {
+		s.handleCooldown = cooldown
+	}
+}
+
+// WithBlockedHandles adds words no handle may contain, such as offensive ones.
+func WithBlockedHandles(words ...string) Option {
+	return func(s *userService) {
+		s.blockedHandles = append(s.blockedHandles, words...)
+	}
+}
@@ -5,15 +5,32 @@ import "context"
 type Query interface {
 	FindAdmin(ctx context.Context) (*User, error)
 	FindUsers(ctx context.Context) ([]*User, error)
+	SearchUsers(ctx context.Context, filter UserFilter) (*Users, error)
 	FindUserByID(ctx context.Context, id string) (*User, error)
 	FindUserByAccountAddress(ctx context.Context, accountAddress string) (*User, error)
+	FindUserByTbaAddress(ctx context.Context, tbaAddress string) (*User, error)
 	FindUserByName(ctx context.Context, name string) (*User, error)
+	// FindUserByHandle finds a user by HandleKey
+	FindUserByHandle(ctx context.Context, key string) (*User, error)
+	FindGrantEvents(ctx context.Context, filter GrantEventFilter) (*GrantEvents, error)
+	FindModerationEvents(ctx context.Context, filter ModerationEventFilter) (*ModerationEvents, error)
 }
 
 type Command interface {
 	CreateUser(ctx context.Context, params CreateUserParams) (*User, error)
 	UpdateUser(ctx context.Context, params UpdateUserParams) error
+	UpdateProfile(ctx context.Context, params UpdateProfileParams) error
+	SetHandle(ctx context.Context, params SetHandleParams) error
 	DeleteUser(ctx context.Context, id string) error
+	// RelinkUser replaces old with relinked, returning ErrUserChanged when old was updated meanwhile
+	RelinkUser(ctx context.Context, old, relinked *User) error
+	ReplaceWallets(ctx context.Context, params ReplaceWalletsParams) error
+	// AddGrant and RemoveGrant return whether the user changed
+	AddGrant(ctx context.Context, id string, kind GrantKind, value string) (bool, error)
+	RemoveGrant(ctx context.Context, id string, kind GrantKind, value string) (bool, error)
+	CreateGrantEvent(ctx context.Context, event *GrantEvent) error
+	SetStatus(ctx context.Context, params SetStatusParams) error
+	CreateModerationEvent(ctx context.Context, event *ModerationEvent) error
 }
 
 type Repository interface {
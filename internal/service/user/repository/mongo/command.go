@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/heroticket/internal/service/user"
@@ -40,6 +41,15 @@ func (c *MongoCommand) CreateUser(ctx context.Context, params user.CreateUserPar
 	u.CreatedAt = time.Now().Unix()
 	u.UpdatedAt = time.Now().Unix()
 
+	if u.AccountAddress != "" {
+		u.Wallets = []user.Wallet{{
+			AccountAddress: u.AccountAddress,
+			TbaAddress:     u.TbaAddress,
+			Primary:        true,
+			LinkedAt:       u.CreatedAt,
+		}}
+	}
+
 	_, err := coll.InsertOne(ctx, u)
 	if err != nil {
 		return nil, err
@@ -72,7 +82,8 @@ func (c *MongoCommand) UpdateUser(ctx context.Context, params user.UpdateUserPar
 
 	value := bson.D{}
 
-	if params.Name == "" && params.AccountAddress == "" && params.TBAAddress == "" {
+	if params.Name == "" && params.AccountAddress == "" && params.TBAAddress == "" &&
+		params.Bio == "" && params.Avatar == "" && params.Banner == "" && params.TBATokenBalance == "" {
 		return user.ErrNothingToUpdate
 	}
 
@@ -104,7 +115,7 @@ func (c *MongoCommand) UpdateUser(ctx context.Context, params user.UpdateUserPar
 		value = append(value, bson.E{Key: "tbaTokenBalance", Value: params.TBATokenBalance})
 	}
 
-	value = append(value, bson.E{Key: "updated_at", Value: time.Now().Unix()})
+	value = append(value, bson.E{Key: "updatedAt", Value: time.Now().Unix()})
 
 	update := bson.D{
 		{
@@ -125,6 +136,336 @@ func (c *MongoCommand) UpdateUser(ctx context.Context, params user.UpdateUserPar
 	return nil
 }
 
+func (c *MongoCommand) UpdateProfile(ctx context.Context, params user.UpdateProfileParams) error {
+	coll := c.collection()
+
+	set := bson.M{"updatedAt": time.Now().Unix()}
+
+	if params.Name != nil {
+		set["name"] = *params.Name
+	}
+
+	if params.Bio != nil {
+		set["bio"] = *params.Bio
+	}
+
+	res, err := coll.UpdateOne(ctx, bson.M{"_id": params.ID}, bson.M{"$set": set})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return user.ErrNameTaken
+		}
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return user.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetHandle sets the handle of a user, as long as it was last changed at params.PrevChangedAt.
+func (c *MongoCommand) SetHandle(ctx context.Context, params user.SetHandleParams) error {
+	coll := c.collection()
+
+	filter := bson.M{"_id": params.ID}
+
+	if params.PrevChangedAt > 0 {
+		filter["handleChangedAt"] = params.PrevChangedAt
+	} else {
+		filter["handleChangedAt"] = bson.M{"$exists": false}
+	}
+
+	now := time.Now().Unix()
+
+	set := bson.M{
+		"handle":          params.Handle,
+		"handleKey":       user.HandleKey(params.Handle),
+		"handleChangedAt": now,
+		"updatedAt":       now,
+	}
+
+	res, err := coll.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return user.ErrHandleTaken
+		}
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return user.ErrUserChanged
+	}
+
+	return nil
+}
+
+// ReplaceWallets sets the wallets of a user and mirrors the primary one in accountAddress and
+// tbaAddress, as long as the user still has params.Prev.
+func (c *MongoCommand) ReplaceWallets(ctx context.Context, params user.ReplaceWalletsParams) error {
+	coll := c.collection()
+
+	filter := bson.M{"_id": params.ID}
+
+	if len(params.Prev) > 0 {
+		filter["wallets"] = params.Prev
+	} else {
+		filter["wallets"] = bson.M{"$exists": false}
+	}
+
+	set := bson.M{
+		"wallets":   params.Next,
+		"updatedAt": time.Now().Unix(),
+	}
+
+	for _, w := range params.Next {
+		if w.Primary {
+			set["accountAddress"] = w.AccountAddress
+			set["tbaAddress"] = w.TbaAddress
+		}
+	}
+
+	res, err := coll.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return user.ErrWalletAlreadyLinked
+		}
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return user.ErrUserChanged
+	}
+
+	return nil
+}
+
+// relink journals a relink in progress. The server mongo runs standalone, so instead of a transaction
+// the old record is kept here until the new one is in place, and recoverRelinks finishes or undoes
+// the relinks a crash interrupted.
+type relink struct {
+	ID        string     `bson:"_id"`
+	ToID      string     `bson:"toId"`
+	User      *user.User `bson:"user"`
+	CreatedAt int64      `bson:"createdAt"`
+}
+
+// RelinkUser replaces old with relinked, then moves the grant and moderation events of old to relinked.
+func (c *MongoCommand) RelinkUser(ctx context.Context, old, relinked *user.User) error {
+	coll := c.collection()
+
+	// 1. journal the old record before it is deleted, one relink of a user at a time
+	_, err := c.relinks().InsertOne(ctx, relink{
+		ID:        old.ID,
+		ToID:      relinked.ID,
+		User:      old,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return user.ErrUserChanged
+		}
+		return err
+	}
+
+	// 2. delete the old record unless it changed since it was read
+	res, err := coll.DeleteOne(ctx, bson.M{"_id": old.ID, "updatedAt": old.UpdatedAt})
+	if err != nil || res.DeletedCount == 0 {
+		if _, jerr := c.relinks().DeleteOne(ctx, bson.M{"_id": old.ID}); jerr != nil {
+			return errors.Join(err, jerr)
+		}
+		if err != nil {
+			return err
+		}
+		return user.ErrUserChanged
+	}
+
+	// 3. insert the new record, putting the old one back when it can't be
+	if _, err := coll.InsertOne(ctx, relinked); err != nil {
+		if _, restoreErr := coll.InsertOne(ctx, old); restoreErr != nil {
+			// the journal keeps the old record for recoverRelinks
+			return errors.Join(err, restoreErr)
+		}
+		if _, jerr := c.relinks().DeleteOne(ctx, bson.M{"_id": old.ID}); jerr != nil {
+			return errors.Join(err, jerr)
+		}
+		return err
+	}
+
+	// 4. move the events and close the journal
+	return c.finishRelink(ctx, old.ID, relinked.ID)
+}
+
+func (c *MongoCommand) finishRelink(ctx context.Context, fromID, toID string) error {
+	for _, coll := range []*mongo.Collection{c.grantEvents(), c.moderationEvents()} {
+		if _, err := coll.UpdateMany(ctx, bson.M{"userId": fromID}, bson.M{"$set": bson.M{"userId": toID}}); err != nil {
+			return err
+		}
+	}
+
+	_, err := c.relinks().DeleteOne(ctx, bson.M{"_id": fromID})
+
+	return err
+}
+
+// recoverRelinks settles the journaled relinks: the ones whose new record was inserted are finished,
+// the old record is put back when neither record exists, and the journal is dropped otherwise.
+func (c *MongoCommand) recoverRelinks(ctx context.Context) error {
+	coll := c.collection()
+
+	cursor, err := c.relinks().Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+
+	var relinks []relink
+
+	if err := cursor.All(ctx, &relinks); err != nil {
+		return err
+	}
+
+	for _, r := range relinks {
+		moved, err := coll.CountDocuments(ctx, bson.M{"_id": r.ToID})
+		if err != nil {
+			return err
+		}
+
+		if moved > 0 {
+			if err := c.finishRelink(ctx, r.ID, r.ToID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		restored, err := coll.CountDocuments(ctx, bson.M{"_id": r.ID})
+		if err != nil {
+			return err
+		}
+
+		if restored == 0 {
+			if _, err := coll.InsertOne(ctx, r.User); err != nil {
+				return err
+			}
+		}
+
+		if _, err := c.relinks().DeleteOne(ctx, bson.M{"_id": r.ID}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *MongoCommand) AddGrant(ctx context.Context, id string, kind user.GrantKind, value string) (bool, error) {
+	return c.updateGrant(ctx, id, kind, "$addToSet", value)
+}
+
+func (c *MongoCommand) RemoveGrant(ctx context.Context, id string, kind user.GrantKind, value string) (bool, error) {
+	return c.updateGrant(ctx, id, kind, "$pull", value)
+}
+
+func (c *MongoCommand) updateGrant(ctx context.Context, id string, kind user.GrantKind, op, value string) (bool, error) {
+	coll := c.collection()
+
+	field, err := grantField(kind)
+	if err != nil {
+		return false, err
+	}
+
+	update := bson.M{
+		op:     bson.M{field: value},
+		"$set": bson.M{"updatedAt": time.Now().Unix()},
+	}
+
+	res, err := coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return false, err
+	}
+
+	if res.MatchedCount == 0 {
+		return false, user.ErrUserNotFound
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+func grantField(kind user.GrantKind) (string, error) {
+	switch kind {
+	case user.GrantKindRole:
+		return "roles", nil
+	case user.GrantKindPermission:
+		return "permissions", nil
+	default:
+		return "", user.ErrInvalidRole
+	}
+}
+
+func (c *MongoCommand) CreateGrantEvent(ctx context.Context, event *user.GrantEvent) error {
+	coll := c.grantEvents()
+
+	_, err := coll.InsertOne(ctx, event)
+
+	return err
+}
+
+func (c *MongoCommand) SetStatus(ctx context.Context, params user.SetStatusParams) error {
+	coll := c.collection()
+
+	var update bson.M
+
+	if params.Status == user.StatusActive {
+		update = bson.M{
+			"$set":   bson.M{"updatedAt": time.Now().Unix()},
+			"$unset": bson.M{"status": "", "suspendedUntil": ""},
+		}
+	} else {
+		set := bson.M{"status": params.Status, "updatedAt": time.Now().Unix()}
+		unset := bson.M{}
+
+		if params.Until > 0 {
+			set["suspendedUntil"] = params.Until
+		} else {
+			unset["suspendedUntil"] = ""
+		}
+
+		update = bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+	}
+
+	res, err := coll.UpdateOne(ctx, bson.M{"_id": params.UserID}, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return user.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (c *MongoCommand) CreateModerationEvent(ctx context.Context, event *user.ModerationEvent) error {
+	coll := c.moderationEvents()
+
+	_, err := coll.InsertOne(ctx, event)
+
+	return err
+}
+
+func (c *MongoCommand) relinks() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("user_relinks")
+}
+
+func (c *MongoCommand) moderationEvents() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("moderation_events")
+}
+
+func (c *MongoCommand) grantEvents() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("grant_events")
+}
+
 func (c *MongoCommand) collection() *mongo.Collection {
 	return c.client.Database(c.dbname).Collection("users")
 }
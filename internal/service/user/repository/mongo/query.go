@@ -2,10 +2,16 @@ package mongo
 
 import (
 	"context"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/heroticket/internal/pagination"
 	"github.com/heroticket/internal/service/user"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoQuery struct {
@@ -57,7 +63,30 @@ func (q *MongoQuery) FindUserByID(ctx context.Context, id string) (*user.User, e
 func (q *MongoQuery) FindUserByAccountAddress(ctx context.Context, accountAddress string) (*user.User, error) {
 	coll := q.collection()
 
-	filter := bson.M{"accountAddress": accountAddress}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"accountAddress": accountAddress},
+		bson.M{"wallets.accountAddress": accountAddress},
+	}}
+
+	var u user.User
+
+	if err := coll.FindOne(ctx, filter).Decode(&u); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, user.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (q *MongoQuery) FindUserByTbaAddress(ctx context.Context, tbaAddress string) (*user.User, error) {
+	coll := q.collection()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"tbaAddress": tbaAddress},
+		bson.M{"wallets.tbaAddress": tbaAddress},
+	}}
 
 	var u user.User
 
@@ -113,6 +142,179 @@ func (q *MongoQuery) FindUserByName(ctx context.Context, name string) (*user.Use
 	return &u, nil
 }
 
+func (q *MongoQuery) FindUserByHandle(ctx context.Context, key string) (*user.User, error) {
+	coll := q.collection()
+
+	filter := bson.M{"handleKey": key}
+
+	var u user.User
+
+	if err := coll.FindOne(ctx, filter).Decode(&u); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, user.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (q *MongoQuery) FindGrantEvents(ctx context.Context, filter user.GrantEventFilter) (*user.GrantEvents, error) {
+	coll := q.client.Database(q.dbname).Collection("grant_events")
+
+	f := bson.M{}
+
+	if filter.UserID != "" {
+		f["userId"] = filter.UserID
+	}
+
+	total, err := coll.CountDocuments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := pagination.New(total, filter.Page, filter.Limit)
+
+	opts := options.Find().
+		SetSort(bson.M{"createdAt": -1}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cursor, err := coll.Find(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]*user.GrantEvent, 0)
+
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return &user.GrantEvents{
+		Items:      items,
+		Pagination: p,
+	}, nil
+}
+
+func (q *MongoQuery) FindModerationEvents(ctx context.Context, filter user.ModerationEventFilter) (*user.ModerationEvents, error) {
+	coll := q.client.Database(q.dbname).Collection("moderation_events")
+
+	f := bson.M{}
+
+	if filter.UserID != "" {
+		f["userId"] = filter.UserID
+	}
+
+	total, err := coll.CountDocuments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := pagination.New(total, filter.Page, filter.Limit)
+
+	opts := options.Find().
+		SetSort(bson.M{"createdAt": -1}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cursor, err := coll.Find(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]*user.ModerationEvent, 0)
+
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return &user.ModerationEvents{
+		Items:      items,
+		Pagination: p,
+	}, nil
+}
+
+var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// SearchUsers matches the query against the DID, a full account or tba address of any linked wallet,
+// or the start of the name, handle or primary addresses.
+func (q *MongoQuery) SearchUsers(ctx context.Context, filter user.UserFilter) (*user.Users, error) {
+	coll := q.collection()
+
+	f := bson.M{}
+
+	switch query := filter.Query; {
+	case query == "":
+	case strings.HasPrefix(query, "did:"):
+		f["_id"] = query
+	case addressPattern.MatchString(query):
+		address := strings.ToLower(query)
+		f["$or"] = bson.A{
+			bson.M{"accountAddress": address},
+			bson.M{"tbaAddress": address},
+			bson.M{"wallets.accountAddress": address},
+			bson.M{"wallets.tbaAddress": address},
+		}
+	default:
+		prefix := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(query), Options: "i"}
+		lower := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(query))}
+		f["$or"] = bson.A{
+			bson.M{"name": prefix},
+			bson.M{"handleKey": lower},
+			bson.M{"accountAddress": lower},
+			bson.M{"tbaAddress": lower},
+		}
+	}
+
+	// a suspension that ended leaves the user active
+	now := time.Now().Unix()
+
+	switch filter.Status {
+	case user.StatusActive:
+		f["$nor"] = bson.A{
+			bson.M{"status": user.StatusBanned},
+			bson.M{"status": user.StatusSuspended, "suspendedUntil": bson.M{"$gt": now}},
+		}
+	case user.StatusSuspended:
+		f["status"] = user.StatusSuspended
+		f["suspendedUntil"] = bson.M{"$gt": now}
+	case user.StatusBanned:
+		f["status"] = user.StatusBanned
+	}
+
+	total, err := coll.CountDocuments(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := pagination.New(total, filter.Page, filter.Limit)
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cursor, err := coll.Find(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]*user.User, 0)
+
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return &user.Users{
+		Items:      items,
+		Pagination: p,
+	}, nil
+}
+
 func (q *MongoQuery) collection() *mongo.Collection {
 	return q.client.Database(q.dbname).Collection("users")
 }
@@ -37,8 +37,49 @@ func New(ctx context.Context, client *mongo.Client, dbname string) (user.Reposit
 				Keys:    bson.M{"name": 1},
 				Options: options.Index().SetUnique(true),
 			},
+			{
+				// a wallet belongs to one user, documents without wallets are not indexed
+				Keys: bson.M{"wallets.accountAddress": 1},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"wallets.accountAddress": bson.M{"$exists": true}}),
+			},
+			{
+				Keys: bson.M{"wallets.tbaAddress": 1},
+			},
+			{
+				// handles are unique whatever their case, users without one are not indexed
+				Keys: bson.M{"handleKey": 1},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"handleKey": bson.M{"$exists": true}}),
+			},
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = cmd.grantEvents().Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = cmd.moderationEvents().Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
 		},
 	)
 
-	return repo, err
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, cmd.recoverRelinks(ctx)
 }
@@ -0,0 +1,147 @@
+package user
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/heroticket/internal/pagination"
+)
+
+var (
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrIssuerAdmin       = errors.New("roles of the issuer admin can't be changed")
+	ErrSelfRevoke        = errors.New("can't revoke own admin role")
+)
+
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleOrganizer Role = "organizer"
+	RoleScanner   Role = "scanner"
+	// RoleUser is held by every user
+	RoleUser Role = "user"
+)
+
+type Permission string
+
+const (
+	PermissionCreateTicket  Permission = "tickets:create"
+	PermissionScanTicket    Permission = "tickets:scan"
+	PermissionManageNotices Permission = "notices:manage"
+	PermissionManageRoles   Permission = "roles:manage"
+	PermissionManageUsers   Permission = "users:manage"
+)
+
+// RolePermissions are the permissions each role grants.
+var RolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionCreateTicket,
+		PermissionScanTicket,
+		PermissionManageNotices,
+		PermissionManageRoles,
+		PermissionManageUsers,
+	},
+	RoleOrganizer: {PermissionCreateTicket, PermissionScanTicket},
+	RoleScanner:   {PermissionScanTicket},
+	RoleUser:      {},
+}
+
+func (r Role) Valid() bool {
+	_, ok := RolePermissions[r]
+	return ok
+}
+
+func (p Permission) Valid() bool {
+	switch p {
+	case PermissionCreateTicket, PermissionScanTicket, PermissionManageNotices, PermissionManageRoles, PermissionManageUsers:
+		return true
+	default:
+		return false
+	}
+}
+
+// EffectiveRoles returns the granted roles of the user with the ones every user or the issuer admin holds.
+func (u *User) EffectiveRoles() []Role {
+	roles := []Role{RoleUser}
+
+	if u.IsAdmin {
+		roles = append(roles, RoleAdmin)
+	}
+
+	for _, r := range u.Roles {
+		if !slices.Contains(roles, r) {
+			roles = append(roles, r)
+		}
+	}
+
+	return roles
+}
+
+// EffectivePermissions returns the permissions of the roles of the user and the ones granted directly.
+func (u *User) EffectivePermissions() []Permission {
+	var perms []Permission
+
+	add := func(p Permission) {
+		if !slices.Contains(perms, p) {
+			perms = append(perms, p)
+		}
+	}
+
+	for _, r := range u.EffectiveRoles() {
+		for _, p := range RolePermissions[r] {
+			add(p)
+		}
+	}
+
+	for _, p := range u.Permissions {
+		add(p)
+	}
+
+	return perms
+}
+
+type GrantKind string
+
+const (
+	GrantKindRole       GrantKind = "role"
+	GrantKindPermission GrantKind = "permission"
+)
+
+type GrantAction string
+
+const (
+	GrantActionGrant  GrantAction = "grant"
+	GrantActionRevoke GrantAction = "revoke"
+)
+
+// GrantEvent is the audit record of a role or permission granted to or revoked from a user.
+type GrantEvent struct {
+	ID     string      `json:"id" bson:"_id"`
+	UserID string      `json:"userId" bson:"userId"`
+	Kind   GrantKind   `json:"kind" bson:"kind"`
+	Value  string      `json:"value" bson:"value"`
+	Action GrantAction `json:"action" bson:"action"`
+	// By is the user who made the change
+	By        string `json:"by" bson:"by"`
+	CreatedAt int64  `json:"createdAt" bson:"createdAt"`
+}
+
+type GrantEvents struct {
+	Items      []*GrantEvent          `json:"items"`
+	Pagination *pagination.Pagination `json:"pagination"`
+}
+
+type GrantParams struct {
+	UserID string
+	Kind   GrantKind
+	Value  string
+	By     string
+}
+
+type GrantEventFilter struct {
+	UserID string
+	Page   int64
+	Limit  int64
+}
@@ -1,24 +1,56 @@
 package user
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/heroticket/internal/logger"
+)
 
 type Service interface {
 	CreateUser(ctx context.Context, params CreateUserParams) (*User, error)
 	UpdateUser(ctx context.Context, params UpdateUserParams) error
+	UpdateProfile(ctx context.Context, params UpdateProfileParams) (*User, error)
+	SetHandle(ctx context.Context, id, handle string) (*User, error)
+	HandleCooldownEnds(u *User) time.Time
 	DeleteUser(ctx context.Context, id string) error
+	RelinkUser(ctx context.Context, fromID, toID string) (*User, error)
+	LinkWallet(ctx context.Context, id string, wallet Wallet) (*User, error)
+	UnlinkWallet(ctx context.Context, id, accountAddress string) (*User, error)
+	SetPrimaryWallet(ctx context.Context, id, accountAddress string) (*User, error)
+	Grant(ctx context.Context, params GrantParams) (*User, error)
+	Revoke(ctx context.Context, params GrantParams) (*User, error)
+	FindGrantEvents(ctx context.Context, filter GrantEventFilter) (*GrantEvents, error)
+	SetStatus(ctx context.Context, params SetStatusParams) (*User, error)
+	FindModerationEvents(ctx context.Context, filter ModerationEventFilter) (*ModerationEvents, error)
+	SearchUsers(ctx context.Context, filter UserFilter) (*Users, error)
 	FindAdmin(ctx context.Context) (*User, error)
 	FindUsers(ctx context.Context) ([]*User, error)
 	FindUserByID(ctx context.Context, id string) (*User, error)
 	FindUserByAccountAddress(ctx context.Context, accountAddress string) (*User, error)
+	FindUserByTbaAddress(ctx context.Context, tbaAddress string) (*User, error)
 	FindUserByName(ctx context.Context, name string) (*User, error)
+	FindUserByHandle(ctx context.Context, handle string) (*User, error)
 }
 
 type userService struct {
-	repo Repository
+	repo           Repository
+	handleCooldown time.Duration
+	blockedHandles []string
 }
 
-func New(repo Repository) Service {
-	return &userService{repo}
+func New(repo Repository, opts ...Option) Service {
+	svc := &userService{repo: repo}
+
+	WithDefaultOptions()(svc)
+
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	return svc
 }
 
 func (s *userService) CreateUser(ctx context.Context, params CreateUserParams) (*User, error) {
@@ -29,10 +61,88 @@ func (s *userService) UpdateUser(ctx context.Context, params UpdateUserParams) e
 	return s.repo.UpdateUser(ctx, params)
 }
 
+// UpdateProfile changes the name and bio the user shows on their profile.
+func (s *userService) UpdateProfile(ctx context.Context, params UpdateProfileParams) (*User, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateProfile(ctx, params); err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindUserByID(ctx, params.ID)
+}
+
+// SetHandle sets the handle of the user. The first handle is set right away, changing it waits for the cooldown.
+func (s *userService) SetHandle(ctx context.Context, id, handle string) (*User, error) {
+	if err := ValidateHandle(handle, s.blockedHandles); err != nil {
+		return nil, err
+	}
+
+	u, err := s.repo.FindUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Handle == handle {
+		return u, nil
+	}
+
+	if u.HandleChangedAt > 0 && time.Since(time.Unix(u.HandleChangedAt, 0)) < s.handleCooldown {
+		return nil, ErrHandleCooldown
+	}
+
+	if err := s.repo.SetHandle(ctx, SetHandleParams{ID: id, Handle: handle, PrevChangedAt: u.HandleChangedAt}); err != nil {
+		return nil, err
+	}
+
+	logger.Info("handle changed", "id", id, "from", u.Handle, "to", handle)
+
+	return s.repo.FindUserByID(ctx, id)
+}
+
+// HandleCooldownEnds returns when the user may change their handle again, zero when they may now.
+func (s *userService) HandleCooldownEnds(u *User) time.Time {
+	if u.HandleChangedAt == 0 {
+		return time.Time{}
+	}
+
+	ends := time.Unix(u.HandleChangedAt, 0).Add(s.handleCooldown)
+	if time.Now().After(ends) {
+		return time.Time{}
+	}
+
+	return ends
+}
+
 func (s *userService) DeleteUser(ctx context.Context, id string) error {
 	return s.repo.DeleteUser(ctx, id)
 }
 
+// RelinkUser moves the wallets of the user fromID, with their tba and the profile, to the identity toID.
+// Data the services key by the user id is not moved here.
+func (s *userService) RelinkUser(ctx context.Context, fromID, toID string) (*User, error) {
+	old, err := s.repo.FindUserByID(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+
+	if err := old.CanRelink(now); err != nil {
+		return nil, err
+	}
+
+	u := old.Relinked(toID, now)
+
+	if err := s.repo.RelinkUser(ctx, old, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
 func (s *userService) FindAdmin(ctx context.Context) (*User, error) {
 	return s.repo.FindAdmin(ctx)
 }
@@ -49,6 +159,261 @@ func (s *userService) FindUserByAccountAddress(ctx context.Context, accountAddre
 	return s.repo.FindUserByAccountAddress(ctx, accountAddress)
 }
 
+func (s *userService) FindUserByTbaAddress(ctx context.Context, tbaAddress string) (*User, error) {
+	return s.repo.FindUserByTbaAddress(ctx, tbaAddress)
+}
+
 func (s *userService) FindUserByName(ctx context.Context, name string) (*User, error) {
 	return s.repo.FindUserByName(ctx, name)
 }
+
+// FindUserByHandle finds a user by handle whatever its case, with or without the leading @.
+func (s *userService) FindUserByHandle(ctx context.Context, handle string) (*User, error) {
+	return s.repo.FindUserByHandle(ctx, HandleKey(strings.TrimPrefix(handle, "@")))
+}
+
+// LinkWallet adds a verified wallet to the user, as a secondary one.
+func (s *userService) LinkWallet(ctx context.Context, id string, wallet Wallet) (*User, error) {
+	wallet.AccountAddress = strings.ToLower(wallet.AccountAddress)
+	wallet.TbaAddress = strings.ToLower(wallet.TbaAddress)
+
+	linked, err := s.repo.FindUserByAccountAddress(ctx, wallet.AccountAddress)
+	if err == nil {
+		if linked.ID == id {
+			return linked, nil
+		}
+		return nil, ErrWalletAlreadyLinked
+	}
+
+	if err != ErrUserNotFound {
+		return nil, err
+	}
+
+	return s.updateWallets(ctx, id, func(wallets []Wallet) ([]Wallet, error) {
+		wallet.Primary = false
+		wallet.LinkedAt = time.Now().Unix()
+
+		return append(wallets, wallet), nil
+	})
+}
+
+// UnlinkWallet removes a secondary wallet from the user.
+func (s *userService) UnlinkWallet(ctx context.Context, id, accountAddress string) (*User, error) {
+	accountAddress = strings.ToLower(accountAddress)
+
+	return s.updateWallets(ctx, id, func(wallets []Wallet) ([]Wallet, error) {
+		next := make([]Wallet, 0, len(wallets))
+
+		for _, w := range wallets {
+			if w.AccountAddress != accountAddress {
+				next = append(next, w)
+				continue
+			}
+
+			if w.Primary {
+				return nil, ErrPrimaryWallet
+			}
+		}
+
+		if len(next) == len(wallets) {
+			return nil, ErrWalletNotLinked
+		}
+
+		return next, nil
+	})
+}
+
+// SetPrimaryWallet makes a linked wallet the one purchases and tickets go to.
+func (s *userService) SetPrimaryWallet(ctx context.Context, id, accountAddress string) (*User, error) {
+	accountAddress = strings.ToLower(accountAddress)
+
+	return s.updateWallets(ctx, id, func(wallets []Wallet) ([]Wallet, error) {
+		next := make([]Wallet, len(wallets))
+		found := false
+
+		for i, w := range wallets {
+			w.Primary = w.AccountAddress == accountAddress
+			found = found || w.Primary
+			next[i] = w
+		}
+
+		if !found {
+			return nil, ErrWalletNotLinked
+		}
+
+		return next, nil
+	})
+}
+
+func (s *userService) updateWallets(ctx context.Context, id string, fn func(wallets []Wallet) ([]Wallet, error)) (*User, error) {
+	u, err := s.repo.FindUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := fn(u.LinkedWallets())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.ReplaceWallets(ctx, ReplaceWalletsParams{
+		ID:   id,
+		Prev: u.Wallets,
+		Next: next,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindUserByID(ctx, id)
+}
+
+// Grant grants a role or permission to a user and records the change.
+func (s *userService) Grant(ctx context.Context, params GrantParams) (*User, error) {
+	return s.updateGrant(ctx, params, GrantActionGrant)
+}
+
+// Revoke revokes a role or permission of a user and records the change.
+func (s *userService) Revoke(ctx context.Context, params GrantParams) (*User, error) {
+	return s.updateGrant(ctx, params, GrantActionRevoke)
+}
+
+func (s *userService) updateGrant(ctx context.Context, params GrantParams, action GrantAction) (*User, error) {
+	switch params.Kind {
+	case GrantKindRole:
+		if !Role(params.Value).Valid() || Role(params.Value) == RoleUser {
+			return nil, ErrInvalidRole
+		}
+	case GrantKindPermission:
+		if !Permission(params.Value).Valid() {
+			return nil, ErrInvalidPermission
+		}
+	default:
+		return nil, ErrInvalidRole
+	}
+
+	u, err := s.repo.FindUserByID(ctx, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if action == GrantActionRevoke && params.Kind == GrantKindRole && Role(params.Value) == RoleAdmin {
+		if u.IsAdmin {
+			return nil, ErrIssuerAdmin
+		}
+
+		if params.UserID == params.By {
+			return nil, ErrSelfRevoke
+		}
+	}
+
+	var changed bool
+
+	if action == GrantActionGrant {
+		changed, err = s.repo.AddGrant(ctx, params.UserID, params.Kind, params.Value)
+	} else {
+		changed, err = s.repo.RemoveGrant(ctx, params.UserID, params.Kind, params.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		event := &GrantEvent{
+			ID:        uuid.NewString(),
+			UserID:    params.UserID,
+			Kind:      params.Kind,
+			Value:     params.Value,
+			Action:    action,
+			By:        params.By,
+			CreatedAt: time.Now().Unix(),
+		}
+
+		logger.Info("user grant changed", "userId", event.UserID, "kind", event.Kind, "value", event.Value, "action", event.Action, "by", event.By)
+
+		// the change is applied, the log line above is its record should this fail
+		if err := s.repo.CreateGrantEvent(ctx, event); err != nil {
+			logger.Error("failed to record grant event", "id", event.ID, "error", err)
+		}
+	}
+
+	return s.repo.FindUserByID(ctx, params.UserID)
+}
+
+func (s *userService) FindGrantEvents(ctx context.Context, filter GrantEventFilter) (*GrantEvents, error) {
+	return s.repo.FindGrantEvents(ctx, filter)
+}
+
+// SetStatus suspends, bans or reinstates a user and records the change.
+func (s *userService) SetStatus(ctx context.Context, params SetStatusParams) (*User, error) {
+	if !params.Status.Valid() {
+		return nil, ErrInvalidStatus
+	}
+
+	now := time.Now().Unix()
+
+	if params.Status == StatusSuspended {
+		if params.Until <= now {
+			return nil, ErrInvalidSuspension
+		}
+	} else {
+		params.Until = 0
+	}
+
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Status != StatusActive && params.Reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	if params.UserID == params.By {
+		return nil, ErrSelfModeration
+	}
+
+	u, err := s.repo.FindUserByID(ctx, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the issuer admin signs every credential, it can't be locked out
+	if u.IsAdmin {
+		return nil, ErrModerateAdmin
+	}
+
+	if err := s.repo.SetStatus(ctx, params); err != nil {
+		return nil, err
+	}
+
+	event := &ModerationEvent{
+		ID:        uuid.NewString(),
+		UserID:    params.UserID,
+		Status:    params.Status,
+		Reason:    params.Reason,
+		Until:     params.Until,
+		By:        params.By,
+		CreatedAt: now,
+	}
+
+	logger.Info("user status changed", "userId", event.UserID, "status", event.Status, "until", event.Until, "reason", event.Reason, "by", event.By)
+
+	// the new status is in force and undoing it would reverse the moderator's decision, so a missing
+	// history entry is only logged; the line above keeps the reason an appeal is reviewed against
+	if err := s.repo.CreateModerationEvent(ctx, event); err != nil {
+		logger.Error("failed to record moderation event", "id", event.ID, "error", err)
+	}
+
+	return s.repo.FindUserByID(ctx, params.UserID)
+}
+
+func (s *userService) FindModerationEvents(ctx context.Context, filter ModerationEventFilter) (*ModerationEvents, error) {
+	return s.repo.FindModerationEvents(ctx, filter)
+}
+
+func (s *userService) SearchUsers(ctx context.Context, filter UserFilter) (*Users, error) {
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, ErrInvalidStatus
+	}
+
+	filter.Query = strings.TrimSpace(filter.Query)
+
+	return s.repo.SearchUsers(ctx, filter)
+}
@@ -0,0 +1,96 @@
+package user
+
+import (
+	"errors"
+
+	"github.com/heroticket/internal/pagination"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("status must be active, suspended or banned")
+	ErrInvalidSuspension = errors.New("a suspension must end in the future")
+	ErrReasonRequired    = errors.New("a reason is required")
+	ErrSelfModeration    = errors.New("admins cannot change their own status")
+	ErrModerateAdmin     = errors.New("the issuer admin can't be suspended or banned")
+)
+
+type Status string
+
+const (
+	StatusActive    Status = "active"
+	StatusSuspended Status = "suspended"
+	StatusBanned    Status = "banned"
+)
+
+func (s Status) Valid() bool {
+	switch s {
+	case StatusActive, StatusSuspended, StatusBanned:
+		return true
+	default:
+		return false
+	}
+}
+
+// CurrentStatus returns the status of the user at the unix time now, a suspension that ended is active.
+func (u *User) CurrentStatus(now int64) Status {
+	switch u.Status {
+	case StatusBanned:
+		return StatusBanned
+	case StatusSuspended:
+		if now < u.SuspendedUntil {
+			return StatusSuspended
+		}
+	}
+
+	return StatusActive
+}
+
+// Suspended reports whether the user is suspended or banned at the unix time now.
+func (u *User) Suspended(now int64) bool {
+	return u.CurrentStatus(now) != StatusActive
+}
+
+type SetStatusParams struct {
+	UserID string
+	Status Status
+	Reason string
+	// Until is the unix time a suspension ends at
+	Until int64
+	By    string
+}
+
+// ModerationEvent is the audit record of a user suspended, banned or reinstated by an admin.
+type ModerationEvent struct {
+	ID     string `json:"id" bson:"_id"`
+	UserID string `json:"userId" bson:"userId"`
+	Status Status `json:"status" bson:"status"`
+	Reason string `json:"reason,omitempty" bson:"reason,omitempty"`
+	Until  int64  `json:"until,omitempty" bson:"until,omitempty"`
+	// By is the admin who made the change
+	By        string `json:"by" bson:"by"`
+	CreatedAt int64  `json:"createdAt" bson:"createdAt"`
+}
+
+type ModerationEvents struct {
+	Items      []*ModerationEvent     `json:"items"`
+	Pagination *pagination.Pagination `json:"pagination"`
+}
+
+type ModerationEventFilter struct {
+	UserID string
+	Page   int64
+	Limit  int64
+}
+
+type UserFilter struct {
+	// Query matches a DID, a full account or tba address, or the start of a name, handle or address
+	Query  string
+	Status Status
+	Page   int64
+	Limit  int64
+}
+
+type Users struct {
+	Items      []*User                `json:"items"`
+	Pagination *pagination.Pagination `json:"pagination"`
+}
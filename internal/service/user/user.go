@@ -2,6 +2,9 @@ package user
 
 import (
 	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -9,20 +12,143 @@ var (
 	ErrNothingToUpdate  = errors.New("nothing to update")
 	ErrUserNotFound     = errors.New("user not found")
 	ErrTBAAlreadyExists = errors.New("tba address already exists")
+
+	ErrWalletAlreadyLinked = errors.New("wallet is already linked")
+	ErrWalletNotLinked     = errors.New("wallet is not linked")
+	ErrPrimaryWallet       = errors.New("primary wallet cannot be unlinked")
+	ErrUserChanged         = errors.New("user changed concurrently")
+	ErrRelinkRefused       = errors.New("admins, staff and moderated users can't be relinked to another identity")
+
+	ErrInvalidName = errors.New("name must be 1 to 32 characters without control characters or surrounding spaces")
+	ErrInvalidBio  = errors.New("bio must be up to 300 characters")
+	ErrNameTaken   = errors.New("name is already taken")
+)
+
+const (
+	MaxNameLength = 32
+	MaxBioLength  = 300
 )
 
 type User struct {
-	ID              string `json:"id" bson:"_id"`
-	AccountAddress  string `json:"accountAddress" bson:"accountAddress"`
-	TbaAddress      string `json:"tbaAddress" bson:"tbaAddress"`
-	Name            string `json:"name" bson:"name"`
+	ID             string `json:"id" bson:"_id"`
+	AccountAddress string `json:"accountAddress" bson:"accountAddress"`
+	TbaAddress     string `json:"tbaAddress" bson:"tbaAddress"`
+	Name           string `json:"name" bson:"name"`
+	// Handle is the unique @name of the user, HandleKey its case-insensitive form
+	Handle          string `json:"handle,omitempty" bson:"handle,omitempty"`
+	HandleKey       string `json:"-" bson:"handleKey,omitempty"`
+	HandleChangedAt int64  `json:"handleChangedAt,omitempty" bson:"handleChangedAt,omitempty"`
 	Bio             string `json:"bio" bson:"bio"`
 	Avatar          string `json:"avatar" bson:"avatar"`
 	Banner          string `json:"banner" bson:"banner"`
 	TbaTokenBalance string `json:"tbaTokenBalance"`
 	IsAdmin         bool   `json:"isAdmin" bson:"isAdmin"`
-	CreatedAt       int64  `json:"createdAt" bson:"createdAt"`
-	UpdatedAt       int64  `json:"updatedAt" bson:"updatedAt"`
+	// Status is empty for users never moderated, see CurrentStatus
+	Status         Status `json:"status,omitempty" bson:"status,omitempty"`
+	SuspendedUntil int64  `json:"suspendedUntil,omitempty" bson:"suspendedUntil,omitempty"`
+	// Roles and Permissions are the ones granted, see EffectiveRoles and EffectivePermissions
+	Roles       []Role       `json:"roles" bson:"roles,omitempty"`
+	Permissions []Permission `json:"permissions" bson:"permissions,omitempty"`
+	// Wallets are the verified wallets of the user, the primary one mirrored in AccountAddress and TbaAddress
+	Wallets   []Wallet `json:"wallets" bson:"wallets,omitempty"`
+	CreatedAt int64    `json:"createdAt" bson:"createdAt"`
+	UpdatedAt int64    `json:"updatedAt" bson:"updatedAt"`
+}
+
+type Wallet struct {
+	AccountAddress string `json:"accountAddress" bson:"accountAddress"`
+	TbaAddress     string `json:"tbaAddress" bson:"tbaAddress"`
+	Primary        bool   `json:"primary" bson:"primary"`
+	LinkedAt       int64  `json:"linkedAt" bson:"linkedAt"`
+}
+
+// LinkedWallets returns the wallets of the user. Users registered before wallets were listed have
+// only their primary wallet.
+func (u *User) LinkedWallets() []Wallet {
+	if len(u.Wallets) > 0 {
+		return u.Wallets
+	}
+
+	if u.AccountAddress == "" {
+		return nil
+	}
+
+	return []Wallet{{
+		AccountAddress: u.AccountAddress,
+		TbaAddress:     u.TbaAddress,
+		Primary:        true,
+		LinkedAt:       u.CreatedAt,
+	}}
+}
+
+// HasWallet reports whether accountAddress is one of the linked wallets of the user, whatever its case.
+func (u *User) HasWallet(accountAddress string) bool {
+	for _, w := range u.LinkedWallets() {
+		if strings.EqualFold(w.AccountAddress, accountAddress) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AccountAddresses returns the account address of every linked wallet.
+func (u *User) AccountAddresses() []string {
+	wallets := u.LinkedWallets()
+	addresses := make([]string, 0, len(wallets))
+
+	for _, w := range wallets {
+		addresses = append(addresses, w.AccountAddress)
+	}
+
+	return addresses
+}
+
+// CanRelink returns ErrRelinkRefused when the record of u may not move to another identity at the
+// unix time now. Admin rights, grants and moderation were given to the identity, not to its wallets.
+func (u *User) CanRelink(now int64) error {
+	if u.IsAdmin || len(u.Roles) > 0 || len(u.Permissions) > 0 || u.CurrentStatus(now) != StatusActive {
+		return ErrRelinkRefused
+	}
+
+	return nil
+}
+
+// Relinked returns the record of u moved to the identity id at the unix time now. Only the wallets and
+// the profile are carried over, see CanRelink.
+func (u *User) Relinked(id string, now int64) *User {
+	return &User{
+		ID:              id,
+		AccountAddress:  u.AccountAddress,
+		TbaAddress:      u.TbaAddress,
+		Name:            u.Name,
+		Handle:          u.Handle,
+		HandleKey:       u.HandleKey,
+		HandleChangedAt: u.HandleChangedAt,
+		Bio:             u.Bio,
+		Avatar:          u.Avatar,
+		Banner:          u.Banner,
+		TbaTokenBalance: u.TbaTokenBalance,
+		Wallets:         u.Wallets,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       now,
+	}
+}
+
+// TbaAddresses returns the tba of every linked wallet, the primary one first.
+func (u *User) TbaAddresses() []string {
+	wallets := u.LinkedWallets()
+	addresses := make([]string, 0, len(wallets))
+
+	for _, w := range wallets {
+		if w.Primary {
+			addresses = append([]string{w.TbaAddress}, addresses...)
+		} else {
+			addresses = append(addresses, w.TbaAddress)
+		}
+	}
+
+	return addresses
 }
 
 type CreateUserParams struct {
@@ -35,6 +161,13 @@ type CreateUserParams struct {
 	IsAdmin         bool
 }
 
+type ReplaceWalletsParams struct {
+	ID string
+	// Prev is the wallets the user is expected to have, the replace fails with ErrUserChanged otherwise
+	Prev []Wallet
+	Next []Wallet
+}
+
 type UpdateUserParams struct {
 	ID              string
 	AccountAddress  string
@@ -45,3 +178,30 @@ type UpdateUserParams struct {
 	TBATokenBalance string
 	Banner          string
 }
+
+// UpdateProfileParams changes the profile fields which are not nil.
+type UpdateProfileParams struct {
+	ID   string
+	Name *string
+	Bio  *string
+}
+
+func (p UpdateProfileParams) Validate() error {
+	if p.Name == nil && p.Bio == nil {
+		return ErrNothingToUpdate
+	}
+
+	if p.Name != nil {
+		name := *p.Name
+		if n := utf8.RuneCountInString(name); n == 0 || n > MaxNameLength || strings.TrimSpace(name) != name ||
+			strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return ErrInvalidName
+		}
+	}
+
+	if p.Bio != nil && utf8.RuneCountInString(*p.Bio) > MaxBioLength {
+		return ErrInvalidBio
+	}
+
+	return nil
+}
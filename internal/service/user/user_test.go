@@ -0,0 +1,81 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanRelink(t *testing.T) {
+	const now = 1000
+
+	tests := []struct {
+		name string
+		u    User
+		want error
+	}{
+		{"plain user", User{}, nil},
+		{"suspension ended", User{Status: StatusSuspended, SuspendedUntil: now}, nil},
+		{"active after moderation", User{Status: StatusActive}, nil},
+		{"admin", User{IsAdmin: true}, ErrRelinkRefused},
+		{"role", User{Roles: []Role{RoleOrganizer}}, ErrRelinkRefused},
+		{"permission", User{Permissions: []Permission{PermissionScanTicket}}, ErrRelinkRefused},
+		{"suspended", User{Status: StatusSuspended, SuspendedUntil: now + 1}, ErrRelinkRefused},
+		{"banned", User{Status: StatusBanned}, ErrRelinkRefused},
+	}
+
+	for _, tt := range tests {
+		if err := tt.u.CanRelink(now); err != tt.want {
+			t.Errorf("%s: CanRelink() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRelinked(t *testing.T) {
+	wallets := []Wallet{
+		{AccountAddress: "0xa", TbaAddress: "0xta", Primary: true, LinkedAt: 1},
+		{AccountAddress: "0xb", TbaAddress: "0xtb", LinkedAt: 2},
+	}
+
+	old := User{
+		ID:              "did:old",
+		AccountAddress:  "0xa",
+		TbaAddress:      "0xta",
+		Name:            "alice",
+		Handle:          "Alice",
+		HandleKey:       "alice",
+		HandleChangedAt: 5,
+		Bio:             "bio",
+		Avatar:          "avatar",
+		Banner:          "banner",
+		TbaTokenBalance: "3",
+		IsAdmin:         true,
+		Status:          StatusSuspended,
+		SuspendedUntil:  9,
+		Roles:           []Role{RoleOrganizer},
+		Permissions:     []Permission{PermissionScanTicket},
+		Wallets:         wallets,
+		CreatedAt:       1,
+		UpdatedAt:       2,
+	}
+
+	want := &User{
+		ID:              "did:new",
+		AccountAddress:  "0xa",
+		TbaAddress:      "0xta",
+		Name:            "alice",
+		Handle:          "Alice",
+		HandleKey:       "alice",
+		HandleChangedAt: 5,
+		Bio:             "bio",
+		Avatar:          "avatar",
+		Banner:          "banner",
+		TbaTokenBalance: "3",
+		Wallets:         wallets,
+		CreatedAt:       1,
+		UpdatedAt:       10,
+	}
+
+	if got := old.Relinked("did:new", 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("Relinked() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,16 @@
+package subscriber
+
+import "context"
+
+type Query interface {
+	FindCheckpoint(ctx context.Context, id string) (*Checkpoint, error)
+}
+
+type Command interface {
+	SaveCheckpoint(ctx context.Context, id string, blockNumber uint64) error
+}
+
+type Repository interface {
+	Query
+	Command
+}
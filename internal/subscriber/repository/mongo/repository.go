@@ -0,0 +1,96 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroticket/internal/subscriber"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRepository struct {
+	subscriber.Query
+	subscriber.Command
+	client *mongo.Client
+	dbname string
+}
+
+func New(client *mongo.Client, dbname string) subscriber.Repository {
+	return &mongoRepository{
+		Query:   NewMongoQuery(client, dbname),
+		Command: NewMongoCommand(client, dbname),
+		client:  client,
+		dbname:  dbname,
+	}
+}
+
+type mongoQuery struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoQuery(client *mongo.Client, dbname string) subscriber.Query {
+	return &mongoQuery{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (q *mongoQuery) FindCheckpoint(ctx context.Context, id string) (*subscriber.Checkpoint, error) {
+	coll := q.collection()
+
+	filter := bson.M{"_id": id}
+
+	var cp subscriber.Checkpoint
+
+	if err := coll.FindOne(ctx, filter).Decode(&cp); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, subscriber.ErrCheckpointNotFound
+		}
+		return nil, err
+	}
+
+	return &cp, nil
+}
+
+func (q *mongoQuery) collection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("checkpoints")
+}
+
+type mongoCommand struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoCommand(client *mongo.Client, dbname string) subscriber.Command {
+	return &mongoCommand{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (c *mongoCommand) SaveCheckpoint(ctx context.Context, id string, blockNumber uint64) error {
+	coll := c.collection()
+
+	filter := bson.M{"_id": id}
+
+	update := bson.D{
+		{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "blockNumber", Value: blockNumber},
+				{Key: "updatedAt", Value: time.Now().Unix()},
+			},
+		},
+	}
+
+	_, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+
+	return err
+}
+
+func (c *mongoCommand) collection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("checkpoints")
+}
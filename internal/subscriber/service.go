@@ -0,0 +1,722 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/heroticket/internal/logger"
+	"github.com/heroticket/internal/service/ticket"
+	"github.com/heroticket/internal/service/user"
+	"github.com/heroticket/pkg/contracts/heroticket"
+)
+
+// TransferEventSig is the topic of the ERC721 Transfer event emitted by ticket collections.
+var TransferEventSig = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// errResubscribe restarts the subscription without waiting, e.g. to watch a newly issued collection.
+var errResubscribe = errors.New("resubscribe")
+
+type SubscriberConfig struct {
+	ChainID         int64
+	Client          *ethclient.Client
+	Hero            *heroticket.Heroticket
+	ContractAddress common.Address
+	Repo            Repository
+	TicketRepo      ticket.Repository
+	UserRepo        user.Repository
+	StartBlock      uint64
+	BatchSize       uint64
+	// RewindBlocks is how far before the checkpoint a restart backfills from, DefaultRewindBlocks when 0
+	RewindBlocks uint64
+
+	ReconcileInterval time.Duration
+}
+
+// Subscriber indexes Heroticket contract events, and the Transfer events of the ticket
+// collections it issued, into the ticket and user repositories.
+//
+// On start it backfills every event since the persisted checkpoint with the Filter* iterators
+// and then live-tails new events with the Watch* subscriptions. All handlers are idempotent,
+// so the checkpoint only needs to be "at least once". Live events of different types arrive on
+// separate channels, so the checkpoint may run ahead of events of lower blocks still buffered when
+// the subscription stops; a restart backfills from rewindBlocks before the checkpoint to catch them.
+type Subscriber struct {
+	chainID         int64
+	client          *ethclient.Client
+	hero            *heroticket.Heroticket
+	contractAddress common.Address
+	repo            Repository
+	ticketRepo      ticket.Repository
+	userRepo        user.Repository
+	startBlock      uint64
+	batchSize       uint64
+	rewindBlocks    uint64
+
+	reconcileInterval time.Duration
+
+	checkpoint uint64
+}
+
+func New(cfg SubscriberConfig) *Subscriber {
+	s := &Subscriber{
+		chainID:         cfg.ChainID,
+		client:          cfg.Client,
+		hero:            cfg.Hero,
+		contractAddress: cfg.ContractAddress,
+		repo:            cfg.Repo,
+		ticketRepo:      cfg.TicketRepo,
+		userRepo:        cfg.UserRepo,
+		startBlock:      cfg.StartBlock,
+		batchSize:       DefaultBatchSize,
+		rewindBlocks:    DefaultRewindBlocks,
+
+		reconcileInterval: DefaultReconcileInterval,
+	}
+
+	if cfg.BatchSize > 0 {
+		s.batchSize = cfg.BatchSize
+	}
+
+	if cfg.RewindBlocks > 0 {
+		s.rewindBlocks = cfg.RewindBlocks
+	}
+
+	if cfg.ReconcileInterval > 0 {
+		s.reconcileInterval = cfg.ReconcileInterval
+	}
+
+	return s
+}
+
+// Run keeps the subscriber running until ctx is canceled, restarting from the
+// last checkpoint whenever the subscription or a handler fails.
+// The reconcile job runs alongside on its own interval.
+func (s *Subscriber) Run(ctx context.Context) error {
+	go s.reconcileLoop(ctx)
+
+	for {
+		err := s.run(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if errors.Is(err, errResubscribe) {
+			continue
+		}
+
+		logger.Error("subscriber stopped, restarting", "error", err, "wait", DefaultRetryWait)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(DefaultRetryWait):
+		}
+	}
+}
+
+func (s *Subscriber) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 1. subscribe first so that nothing emitted while backfilling is missed
+	ticketIssued := make(chan *heroticket.HeroticketTicketIssued, 128)
+	ticketSold := make(chan *heroticket.HeroticketTicketSold, 128)
+	tbaCreated := make(chan *heroticket.HeroticketTBACreated, 128)
+	tokenReward := make(chan *heroticket.HeroticketTokenReward, 128)
+
+	watchOpts := &bind.WatchOpts{Context: ctx}
+
+	issuedSub, err := s.hero.WatchTicketIssued(watchOpts, ticketIssued, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer issuedSub.Unsubscribe()
+
+	soldSub, err := s.hero.WatchTicketSold(watchOpts, ticketSold, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer soldSub.Unsubscribe()
+
+	tbaSub, err := s.hero.WatchTBACreated(watchOpts, tbaCreated, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer tbaSub.Unsubscribe()
+
+	rewardSub, err := s.hero.WatchTokenReward(watchOpts, tokenReward)
+	if err != nil {
+		return err
+	}
+	defer rewardSub.Unsubscribe()
+
+	// ticket transfers can only be watched on collections known at subscription time,
+	// a newly issued collection restarts the subscription
+	ticketAddresses, err := s.ticketAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	watched := make(map[common.Address]bool, len(ticketAddresses))
+	for _, address := range ticketAddresses {
+		watched[address] = true
+	}
+
+	transfers := make(chan types.Log, 128)
+
+	var transferErr <-chan error
+
+	if len(ticketAddresses) > 0 {
+		transferSub, err := s.client.SubscribeFilterLogs(ctx, s.transferQuery(ticketAddresses), transfers)
+		if err != nil {
+			return err
+		}
+		defer transferSub.Unsubscribe()
+
+		transferErr = transferSub.Err()
+	}
+
+	// 2. backfill from checkpoint to current head
+	from, err := s.loadCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	head, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.backfill(ctx, from, head); err != nil {
+		return err
+	}
+
+	logger.Info("backfill done, live-tailing events", "head", head)
+
+	// 3. live-tail
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-issuedSub.Err():
+			return fmt.Errorf("ticket issued subscription: %w", err)
+		case err := <-soldSub.Err():
+			return fmt.Errorf("ticket sold subscription: %w", err)
+		case err := <-tbaSub.Err():
+			return fmt.Errorf("tba created subscription: %w", err)
+		case err := <-rewardSub.Err():
+			return fmt.Errorf("token reward subscription: %w", err)
+		case err := <-transferErr:
+			return fmt.Errorf("ticket transfer subscription: %w", err)
+		case ev := <-ticketIssued:
+			if ev.Raw.Removed {
+				continue
+			}
+			if err := s.handleTicketIssued(ctx, ev); err != nil {
+				return err
+			}
+			if err := s.saveCheckpoint(ctx, ev.Raw.BlockNumber); err != nil {
+				return err
+			}
+			if !watched[ev.TicketAddress] {
+				return errResubscribe
+			}
+		case ev := <-ticketSold:
+			if ev.Raw.Removed {
+				continue
+			}
+			if err := s.handleTicketSold(ctx, ev); err != nil {
+				return err
+			}
+			if err := s.saveCheckpoint(ctx, ev.Raw.BlockNumber); err != nil {
+				return err
+			}
+		case ev := <-tbaCreated:
+			if ev.Raw.Removed {
+				continue
+			}
+			if err := s.handleTBACreated(ctx, ev); err != nil {
+				return err
+			}
+			if err := s.saveCheckpoint(ctx, ev.Raw.BlockNumber); err != nil {
+				return err
+			}
+		case ev := <-tokenReward:
+			if ev.Raw.Removed {
+				continue
+			}
+			if err := s.handleTokenReward(ctx, ev); err != nil {
+				return err
+			}
+			if err := s.saveCheckpoint(ctx, ev.Raw.BlockNumber); err != nil {
+				return err
+			}
+		case ev := <-transfers:
+			if ev.Removed {
+				continue
+			}
+			if err := s.handleTransfer(ctx, ev); err != nil {
+				return err
+			}
+			if err := s.saveCheckpoint(ctx, ev.BlockNumber); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// backfill processes every event in [from, to] in batches of batchSize blocks,
+// saving the checkpoint after each batch.
+func (s *Subscriber) backfill(ctx context.Context, from, to uint64) error {
+	for start := from; start <= to; start += s.batchSize {
+		end := min(start+s.batchSize-1, to)
+
+		opts := &bind.FilterOpts{Start: start, End: &end, Context: ctx}
+
+		logger.Info("backfilling events", "from", start, "to", end)
+
+		// ticket issued events go first so that sold tickets can resolve their collection
+		issued, err := s.hero.FilterTicketIssued(opts, nil, nil)
+		if err != nil {
+			return err
+		}
+		for issued.Next() {
+			if err := s.handleTicketIssued(ctx, issued.Event); err != nil {
+				issued.Close()
+				return err
+			}
+		}
+		issued.Close()
+		if err := issued.Error(); err != nil {
+			return err
+		}
+
+		tbas, err := s.hero.FilterTBACreated(opts, nil, nil)
+		if err != nil {
+			return err
+		}
+		for tbas.Next() {
+			if err := s.handleTBACreated(ctx, tbas.Event); err != nil {
+				tbas.Close()
+				return err
+			}
+		}
+		tbas.Close()
+		if err := tbas.Error(); err != nil {
+			return err
+		}
+
+		sold, err := s.hero.FilterTicketSold(opts, nil, nil)
+		if err != nil {
+			return err
+		}
+		for sold.Next() {
+			if err := s.handleTicketSold(ctx, sold.Event); err != nil {
+				sold.Close()
+				return err
+			}
+		}
+		sold.Close()
+		if err := sold.Error(); err != nil {
+			return err
+		}
+
+		rewards, err := s.hero.FilterTokenReward(opts)
+		if err != nil {
+			return err
+		}
+		for rewards.Next() {
+			if err := s.handleTokenReward(ctx, rewards.Event); err != nil {
+				rewards.Close()
+				return err
+			}
+		}
+		rewards.Close()
+		if err := rewards.Error(); err != nil {
+			return err
+		}
+
+		// transfers go after sold tickets so that mints move the ticket to its final owner
+		ticketAddresses, err := s.ticketAddresses(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(ticketAddresses) > 0 {
+			query := s.transferQuery(ticketAddresses)
+			query.FromBlock = new(big.Int).SetUint64(start)
+			query.ToBlock = new(big.Int).SetUint64(end)
+
+			logs, err := s.client.FilterLogs(ctx, query)
+			if err != nil {
+				return err
+			}
+
+			for _, l := range logs {
+				if err := s.handleTransfer(ctx, l); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := s.saveCheckpoint(ctx, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Subscriber) handleTicketIssued(ctx context.Context, ev *heroticket.HeroticketTicketIssued) error {
+	contractAddress := strings.ToLower(ev.TicketAddress.Hex())
+
+	onchainTicket, err := s.onChainTicketInfo(ctx, ev.TicketAddress)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ticketRepo.CreateTicketCollection(ctx, ticket.CreateTicketCollectionParams{
+		ChainID:         s.chainID,
+		ContractAddress: contractAddress,
+		IssuerAddress:   strings.ToLower(onchainTicket.Issuer.Hex()),
+		Name:            ev.TicketName,
+		Symbol:          ev.TicketSymbol,
+		TicketUrl:       ev.TicketUri,
+		EthPrice:        ev.TicketEthPrice.String(),
+		TokenPrice:      ev.TicketTokenPrice.String(),
+		TotalSupply:     ev.TicketAmount.String(),
+		Remaining:       onchainTicket.Remaining.String(),
+		SaleStartAt:     onchainTicket.SaleStartAt.Int64(),
+		SaleEndAt:       onchainTicket.SaleEndAt.Int64(),
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("indexed ticket issued", "contractAddress", contractAddress, "block", ev.Raw.BlockNumber)
+
+	return nil
+}
+
+func (s *Subscriber) handleTicketSold(ctx context.Context, ev *heroticket.HeroticketTicketSold) error {
+	contractAddress := strings.ToLower(ev.TicketAddress.Hex())
+
+	collection, err := s.ticketCollection(ctx, ev.TicketAddress, ev.Raw.BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	purchasedAt, err := s.blockTime(ctx, ev.Raw.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ticketRepo.CreateTicket(ctx, ticket.Ticket{
+		ID:           fmt.Sprintf("%s-%s", contractAddress, ev.TicketId.String()),
+		Address:      contractAddress,
+		OwnerAddress: strings.ToLower(ev.Buyer.Hex()),
+		TokenID:      ev.TicketId.Uint64(),
+		Name:         collection.Name,
+		Symbol:       collection.Symbol,
+		Image:        collection.TicketUrl,
+		PurchasedAt:  purchasedAt,
+	})
+	if err != nil && err != ticket.ErrTicketAlreadyExists {
+		return err
+	}
+
+	// refresh remaining supply from the contract rather than decrementing,
+	// so that replayed events cannot drift the count
+	info, err := s.onChainTicketInfo(ctx, ev.TicketAddress)
+	if err != nil {
+		return err
+	}
+
+	err = s.ticketRepo.UpdateTicketCollection(ctx, ticket.UpdateTicketCollectionParamsFromOnchain(info))
+	if err != nil {
+		return err
+	}
+
+	logger.Info("indexed ticket sold", "contractAddress", contractAddress, "tokenId", ev.TicketId, "block", ev.Raw.BlockNumber)
+
+	return nil
+}
+
+func (s *Subscriber) handleTBACreated(ctx context.Context, ev *heroticket.HeroticketTBACreated) error {
+	_, err := s.ticketRepo.SaveTBA(ctx, ticket.SaveTBAParams{
+		TbaAddress:   strings.ToLower(ev.Account.Hex()),
+		OwnerAddress: strings.ToLower(ev.Owner.Hex()),
+		TokenID:      ev.TokenId.Uint64(),
+		BlockNumber:  ev.Raw.BlockNumber,
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("indexed tba created", "tbaAddress", ev.Account.Hex(), "block", ev.Raw.BlockNumber)
+
+	return nil
+}
+
+func (s *Subscriber) handleTokenReward(ctx context.Context, ev *heroticket.HeroticketTokenReward) error {
+	to := strings.ToLower(ev.To.Hex())
+
+	// rewards are paid to the tba, but fall back to the account address just in case
+	u, err := s.userRepo.FindUserByTbaAddress(ctx, to)
+	if err == user.ErrUserNotFound {
+		u, err = s.userRepo.FindUserByAccountAddress(ctx, to)
+	}
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			// reward for an address that has not registered yet; the balance is read on registration
+			return nil
+		}
+		return err
+	}
+
+	balance, err := s.hero.TokenBalanceOf(&bind.CallOpts{Context: ctx}, common.HexToAddress(u.TbaAddress))
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepo.UpdateUser(ctx, user.UpdateUserParams{
+		ID:              u.ID,
+		TBATokenBalance: balance.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("indexed token reward", "to", to, "amount", ev.Amount, "block", ev.Raw.BlockNumber)
+
+	return nil
+}
+
+// handleTransfer moves a ticket to its new owner, deleting it when burned. A ticket whose
+// TicketSold event is not indexed yet is created from its collection.
+func (s *Subscriber) handleTransfer(ctx context.Context, l types.Log) error {
+	// erc20 transfers share the signature but do not index the third argument
+	if len(l.Topics) != 4 {
+		return nil
+	}
+
+	contractAddress := strings.ToLower(l.Address.Hex())
+	to := common.BytesToAddress(l.Topics[2].Bytes())
+	tokenID := l.Topics[3].Big()
+
+	id := fmt.Sprintf("%s-%s", contractAddress, tokenID.String())
+
+	if to == (common.Address{}) {
+		if err := s.ticketRepo.DeleteTicket(ctx, id); err != nil {
+			return err
+		}
+
+		logger.Info("indexed ticket burned", "contractAddress", contractAddress, "tokenId", tokenID, "block", l.BlockNumber)
+
+		return nil
+	}
+
+	ownerAddress := strings.ToLower(to.Hex())
+
+	err := s.ticketRepo.UpdateTicketOwner(ctx, id, ownerAddress)
+	if err == ticket.ErrTicketNotFound {
+		err = s.createTransferredTicket(ctx, l, id, ownerAddress, tokenID)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("indexed ticket transfer", "contractAddress", contractAddress, "tokenId", tokenID, "to", ownerAddress, "block", l.BlockNumber)
+
+	return nil
+}
+
+func (s *Subscriber) createTransferredTicket(ctx context.Context, l types.Log, id, ownerAddress string, tokenID *big.Int) error {
+	collection, err := s.ticketCollection(ctx, l.Address, l.BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	purchasedAt, err := s.blockTime(ctx, l.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ticketRepo.CreateTicket(ctx, ticket.Ticket{
+		ID:           id,
+		Address:      strings.ToLower(l.Address.Hex()),
+		OwnerAddress: ownerAddress,
+		TokenID:      tokenID.Uint64(),
+		Name:         collection.Name,
+		Symbol:       collection.Symbol,
+		Image:        collection.TicketUrl,
+		PurchasedAt:  purchasedAt,
+	})
+	if err == ticket.ErrTicketAlreadyExists {
+		// created concurrently by the TicketSold handler
+		return s.ticketRepo.UpdateTicketOwner(ctx, id, ownerAddress)
+	}
+
+	return err
+}
+
+func (s *Subscriber) reconcileLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reconcile(ctx); err != nil {
+				logger.Error("failed to reconcile ticket collections", "error", err)
+			}
+		}
+	}
+}
+
+// Reconcile re-reads the on-chain ticket info of every indexed collection and fixes drift.
+func (s *Subscriber) Reconcile(ctx context.Context) error {
+	collections, err := s.ticketRepo.FindTicketCollections(ctx, ticket.TicketCollectionFilter{})
+	if err != nil {
+		return err
+	}
+
+	updated, err := ticket.Reconcile(ctx, s.ticketRepo, collections.Items, s.onChainTicketInfo)
+
+	logger.Info("reconciled ticket collections", "total", len(collections.Items), "updated", updated)
+
+	return err
+}
+
+// ticketCollection returns the indexed ticket collection, indexing its TicketIssued event first
+// if it has not been seen yet (live subscriptions do not guarantee ordering across event types).
+func (s *Subscriber) ticketCollection(ctx context.Context, contractAddress common.Address, blockNumber uint64) (*ticket.TicketCollection, error) {
+	collection, err := s.ticketRepo.FindTicketCollectionByContractAddress(ctx, strings.ToLower(contractAddress.Hex()))
+	if err == nil {
+		return collection, nil
+	}
+
+	if err != ticket.ErrTicketCollectionNotFound {
+		return nil, err
+	}
+
+	issued, err := s.hero.FilterTicketIssued(&bind.FilterOpts{Start: s.startBlock, End: &blockNumber, Context: ctx}, []common.Address{contractAddress}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer issued.Close()
+
+	for issued.Next() {
+		if err := s.handleTicketIssued(ctx, issued.Event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := issued.Error(); err != nil {
+		return nil, err
+	}
+
+	return s.ticketRepo.FindTicketCollectionByContractAddress(ctx, strings.ToLower(contractAddress.Hex()))
+}
+
+// ticketAddresses returns the addresses of every indexed ticket collection.
+func (s *Subscriber) ticketAddresses(ctx context.Context) ([]common.Address, error) {
+	collections, err := s.ticketRepo.FindTicketCollections(ctx, ticket.TicketCollectionFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]common.Address, 0, len(collections.Items))
+
+	for _, tc := range collections.Items {
+		addresses = append(addresses, common.HexToAddress(tc.ContractAddress))
+	}
+
+	return addresses, nil
+}
+
+func (s *Subscriber) transferQuery(ticketAddresses []common.Address) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: ticketAddresses,
+		Topics:    [][]common.Hash{{TransferEventSig}},
+	}
+}
+
+func (s *Subscriber) onChainTicketInfo(ctx context.Context, contractAddress common.Address) (*ticket.OnchainTicketInfo, error) {
+	issuer, remain, ethPrice, tokenPrice, saleStartAt, saleEndAt, err := s.hero.TicketInfo(&bind.CallOpts{Context: ctx}, contractAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ticket.OnchainTicketInfo{
+		ContractAddress: contractAddress,
+		Issuer:          issuer,
+		Remaining:       remain,
+		EthPrice:        ethPrice,
+		TokenPrice:      tokenPrice,
+		SaleStartAt:     saleStartAt,
+		SaleEndAt:       saleEndAt,
+	}, nil
+}
+
+func (s *Subscriber) blockTime(ctx context.Context, hash common.Hash) (int64, error) {
+	header, err := s.client.HeaderByHash(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(header.Time), nil
+}
+
+func (s *Subscriber) loadCheckpoint(ctx context.Context) (uint64, error) {
+	cp, err := s.repo.FindCheckpoint(ctx, s.checkpointID())
+	if err != nil {
+		if err == ErrCheckpointNotFound {
+			s.checkpoint = s.startBlock
+			return s.startBlock, nil
+		}
+		return 0, err
+	}
+
+	s.checkpoint = max(cp.BlockNumber, s.startBlock)
+
+	// the checkpoint block may have been partially processed and live events of lower blocks may
+	// have been dropped with the subscription, so the blocks before it are processed again
+	from := s.startBlock
+	if s.checkpoint > from+s.rewindBlocks {
+		from = s.checkpoint - s.rewindBlocks
+	}
+
+	return from, nil
+}
+
+func (s *Subscriber) saveCheckpoint(ctx context.Context, blockNumber uint64) error {
+	// live events of different types can arrive out of order, never move the checkpoint backwards
+	if blockNumber <= s.checkpoint {
+		return nil
+	}
+
+	if err := s.repo.SaveCheckpoint(ctx, s.checkpointID(), blockNumber); err != nil {
+		return err
+	}
+
+	s.checkpoint = blockNumber
+
+	return nil
+}
+
+func (s *Subscriber) checkpointID() string {
+	return strings.ToLower(s.contractAddress.Hex())
+}
@@ -0,0 +1,21 @@
+package subscriber
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+var (
+	DefaultBatchSize         uint64 = 2000
+	DefaultRewindBlocks      uint64 = 128
+	DefaultRetryWait                = 10 * time.Second
+	DefaultReconcileInterval        = 10 * time.Minute
+)
+
+type Checkpoint struct {
+	ID          string `json:"id" bson:"_id"`
+	BlockNumber uint64 `json:"blockNumber" bson:"blockNumber"`
+	UpdatedAt   int64  `json:"updatedAt" bson:"updatedAt"`
+}
@@ -3,19 +3,63 @@ package web3
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
 	"regexp"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
 )
 
-var AddressRegex = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+var (
+	ErrChainIDMismatch = errors.New("rpc node is on another chain")
+	ErrInvalidEther    = errors.New("invalid ether amount")
+)
+
+var (
+	AddressRegex = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+	TxHashRegex  = regexp.MustCompile("^0x[0-9a-fA-F]{64}$")
+	EtherRegex   = regexp.MustCompile(`^([0-9]+)(?:\.([0-9]{1,18}))?$`)
+)
 
 func NewClient(ctx context.Context, rpcUrl string) (*ethclient.Client, error) {
 	return ethclient.DialContext(ctx, rpcUrl)
 }
 
+// NewNetworkClient connects to the first of rpcUrls that answers with chainID.
+func NewNetworkClient(ctx context.Context, chainID int64, rpcUrls ...string) (*ethclient.Client, error) {
+	var errs []error
+
+	for _, rpcUrl := range rpcUrls {
+		client, err := ethclient.DialContext(ctx, rpcUrl)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		id, err := client.ChainID(ctx)
+		if err != nil {
+			client.Close()
+			errs = append(errs, err)
+			continue
+		}
+
+		if id.Int64() != chainID {
+			client.Close()
+			errs = append(errs, fmt.Errorf("%w: expected %d, got %s", ErrChainIDMismatch, chainID, id))
+			continue
+		}
+
+		return client, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
+
 func ParsePrivateKey(rawPrivateKey string) (*ecdsa.PrivateKey, error) {
 	return crypto.HexToECDSA(rawPrivateKey)
 }
@@ -27,3 +71,32 @@ func IsAddressValid(address string) bool {
 func HexToAddress(s string) common.Address {
 	return common.HexToAddress(s)
 }
+
+func IsTxHashValid(hash string) bool {
+	return TxHashRegex.MatchString(hash)
+}
+
+func HexToHash(s string) common.Hash {
+	return common.HexToHash(s)
+}
+
+// ParseEther converts a decimal amount of ether, such as "0.05", to wei without rounding. Amounts
+// finer than 1 wei are rejected.
+func ParseEther(s string) (*big.Int, error) {
+	m := EtherRegex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, ErrInvalidEther
+	}
+
+	fraction := m[2] + strings.Repeat("0", 18-len(m[2]))
+
+	wei, _ := new(big.Int).SetString(m[1]+fraction, 10)
+
+	return wei, nil
+}
+
+// GweiToWei converts an amount in gwei to wei.
+func GweiToWei(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return wei
+}
@@ -0,0 +1,36 @@
+package web3
+
+import "testing"
+
+func TestParseEther(t *testing.T) {
+	tests := []struct {
+		ether string
+		want  string
+	}{
+		{"0", "0"},
+		{"1", "1000000000000000000"},
+		{"0.05", "50000000000000000"},
+		{"12.5", "12500000000000000000"},
+		{"0.000000000000000001", "1"},
+		{"0.0000000000000000001", ""},
+		{"", ""},
+		{"-1", ""},
+		{".5", ""},
+		{"1.", ""},
+		{"1e18", ""},
+	}
+
+	for _, tt := range tests {
+		wei, err := ParseEther(tt.ether)
+		if tt.want == "" {
+			if err != ErrInvalidEther {
+				t.Errorf("ParseEther(%q) = %v, %v, want %v", tt.ether, wei, err, ErrInvalidEther)
+			}
+			continue
+		}
+
+		if err != nil || wei.String() != tt.want {
+			t.Errorf("ParseEther(%q) = %v, %v, want %s", tt.ether, wei, err, tt.want)
+		}
+	}
+}
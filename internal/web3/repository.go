@@ -0,0 +1,17 @@
+package web3
+
+import "context"
+
+type TxQuery interface {
+	FindPendingTxs(ctx context.Context, from string) ([]*PendingTx, error)
+}
+
+type TxCommand interface {
+	SavePendingTx(ctx context.Context, tx *PendingTx) error
+	DeletePendingTx(ctx context.Context, id string) error
+}
+
+type TxRepository interface {
+	TxQuery
+	TxCommand
+}
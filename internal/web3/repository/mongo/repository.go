@@ -0,0 +1,114 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/heroticket/internal/web3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRepository struct {
+	web3.TxQuery
+	web3.TxCommand
+	client *mongo.Client
+	dbname string
+}
+
+func New(ctx context.Context, client *mongo.Client, dbname string) (web3.TxRepository, error) {
+	cmd := NewMongoCommand(client, dbname)
+	repo := &mongoRepository{
+		TxQuery:   NewMongoQuery(client, dbname),
+		TxCommand: cmd,
+		client:    client,
+		dbname:    dbname,
+	}
+
+	_, err := cmd.collection().Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "from", Value: 1}, {Key: "nonce", Value: 1}},
+		},
+	)
+
+	return repo, err
+}
+
+type mongoQuery struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoQuery(client *mongo.Client, dbname string) web3.TxQuery {
+	return &mongoQuery{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (q *mongoQuery) FindPendingTxs(ctx context.Context, from string) ([]*web3.PendingTx, error) {
+	coll := q.collection()
+
+	filter := bson.M{"from": from}
+
+	cur, err := coll.Find(ctx, filter, options.Find().SetSort(bson.M{"nonce": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	txs := []*web3.PendingTx{}
+
+	for cur.Next(ctx) {
+		var tx web3.PendingTx
+
+		if err := cur.Decode(&tx); err != nil {
+			return nil, err
+		}
+
+		txs = append(txs, &tx)
+	}
+
+	return txs, nil
+}
+
+func (q *mongoQuery) collection() *mongo.Collection {
+	return q.client.Database(q.dbname).Collection("pending_txs")
+}
+
+type mongoCommand struct {
+	client *mongo.Client
+	dbname string
+}
+
+func NewMongoCommand(client *mongo.Client, dbname string) *mongoCommand {
+	return &mongoCommand{
+		client: client,
+		dbname: dbname,
+	}
+}
+
+func (c *mongoCommand) SavePendingTx(ctx context.Context, tx *web3.PendingTx) error {
+	coll := c.collection()
+
+	filter := bson.M{"_id": tx.ID}
+
+	_, err := coll.ReplaceOne(ctx, filter, tx, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+func (c *mongoCommand) DeletePendingTx(ctx context.Context, id string) error {
+	coll := c.collection()
+
+	filter := bson.M{"_id": id}
+
+	_, err := coll.DeleteOne(ctx, filter)
+
+	return err
+}
+
+func (c *mongoCommand) collection() *mongo.Collection {
+	return c.client.Database(c.dbname).Collection("pending_txs")
+}
@@ -0,0 +1,613 @@
+package web3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/heroticket/internal/logger"
+)
+
+var (
+	ErrTxReplaced  = errors.New("transaction replaced by another transaction with the same nonce")
+	ErrUnknownTx   = errors.New("transaction is not managed by this tx manager")
+	ErrNoChainID   = errors.New("chain id is required")
+	ErrNilTxResult = errors.New("transact function returned no transaction")
+	// ErrMaxFeeExceeded is returned when a transaction would have to pay more per gas than the configured ceiling
+	ErrMaxFeeExceeded = errors.New("max fee per gas exceeded")
+)
+
+var (
+	DefaultResubmitTimeout       = 2 * time.Minute
+	DefaultPollInterval          = 2 * time.Second
+	DefaultGasBumpPercent  int64 = 10
+)
+
+// TxBackend is the part of an ethereum client the tx manager needs.
+// Both ethclient.Client and the simulated backend satisfy it.
+type TxBackend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+}
+
+type chainIDReader interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// PendingTx is a sent transaction that has not been mined yet.
+// Hashes holds every version sent for the nonce, the latest last.
+type PendingTx struct {
+	ID          string   `json:"id" bson:"_id"`
+	ChainID     int64    `json:"chainId" bson:"chainId"`
+	From        string   `json:"from" bson:"from"`
+	Nonce       uint64   `json:"nonce" bson:"nonce"`
+	Hashes      []string `json:"hashes" bson:"hashes"`
+	RawTx       string   `json:"rawTx" bson:"rawTx"`
+	SubmittedAt int64    `json:"submittedAt" bson:"submittedAt"`
+	CreatedAt   int64    `json:"createdAt" bson:"createdAt"`
+}
+
+func (p *PendingTx) tx() (*types.Transaction, error) {
+	raw, err := hexutil.Decode(p.RawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := new(types.Transaction)
+
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+type TxManagerConfig struct {
+	Backend    TxBackend
+	PrivateKey *ecdsa.PrivateKey
+	// ChainID is read from the backend when nil
+	ChainID *big.Int
+	// Repo persists pending transactions across restarts; nil keeps them in memory only
+	Repo TxRepository
+
+	ResubmitTimeout time.Duration
+	PollInterval    time.Duration
+	GasBumpPercent  int64
+	// MaxFeePerGas caps the fee of resubmitted transactions; nil means no ceiling
+	MaxFeePerGas *big.Int
+}
+
+// TxManager serializes the transactions sent from a single key.
+//
+// Nonces are handed out locally, so concurrent callers never race on PendingNonceAt.
+// Every sent transaction is tracked until it is mined: a transaction that disappears from the pool
+// is rebroadcast, one that stays pending longer than the resubmit timeout is resent with bumped gas,
+// and one whose nonce was consumed by a transaction we did not send is reported as ErrTxReplaced.
+type TxManager struct {
+	backend TxBackend
+	pvk     *ecdsa.PrivateKey
+	from    common.Address
+	chainID *big.Int
+	signer  types.Signer
+	repo    TxRepository
+
+	resubmitTimeout time.Duration
+	pollInterval    time.Duration
+	gasBumpPercent  int64
+	maxFeePerGas    *big.Int
+
+	// sendMu serializes nonce assignment and broadcasting
+	sendMu sync.Mutex
+	nonce  *uint64
+
+	// checkMu serializes checks so a pending transaction is never resubmitted twice at once
+	checkMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint64]*PendingTx
+	// waiters counts WaitMined calls per nonce; Run leaves those nonces to their waiters
+	waiters map[uint64]int
+}
+
+// NewTxManager returns a tx manager for the key in cfg, restoring its pending transactions from the repository.
+func NewTxManager(ctx context.Context, cfg TxManagerConfig) (*TxManager, error) {
+	chainID := cfg.ChainID
+
+	if chainID == nil {
+		reader, ok := cfg.Backend.(chainIDReader)
+		if !ok {
+			return nil, ErrNoChainID
+		}
+
+		id, err := reader.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		chainID = id
+	}
+
+	m := &TxManager{
+		backend: cfg.Backend,
+		pvk:     cfg.PrivateKey,
+		from:    crypto.PubkeyToAddress(cfg.PrivateKey.PublicKey),
+		chainID: chainID,
+		signer:  types.LatestSignerForChainID(chainID),
+		repo:    cfg.Repo,
+
+		resubmitTimeout: DefaultResubmitTimeout,
+		pollInterval:    DefaultPollInterval,
+		gasBumpPercent:  DefaultGasBumpPercent,
+		maxFeePerGas:    cfg.MaxFeePerGas,
+
+		pending: make(map[uint64]*PendingTx),
+		waiters: make(map[uint64]int),
+	}
+
+	if cfg.ResubmitTimeout > 0 {
+		m.resubmitTimeout = cfg.ResubmitTimeout
+	}
+
+	if cfg.PollInterval > 0 {
+		m.pollInterval = cfg.PollInterval
+	}
+
+	if cfg.GasBumpPercent > 0 {
+		m.gasBumpPercent = cfg.GasBumpPercent
+	}
+
+	if m.repo != nil {
+		txs, err := m.repo.FindPendingTxs(ctx, m.fromKey())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range txs {
+			m.pending[p.Nonce] = p
+		}
+	}
+
+	return m, nil
+}
+
+// From returns the address transactions are sent from.
+func (m *TxManager) From() common.Address {
+	return m.from
+}
+
+// ChainID returns the chain id transactions are signed for.
+func (m *TxManager) ChainID() *big.Int {
+	return new(big.Int).Set(m.chainID)
+}
+
+// Transact calls fn with transact options carrying the next local nonce and broadcasts the transaction it builds.
+// fn is expected to build and sign the transaction with the given options, as the abigen bindings do;
+// the options have NoSend set so the manager can track the transaction before it is sent.
+func (m *TxManager) Transact(ctx context.Context, fn func(opts *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+
+	nonce, err := m.nextNonce(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := bind.NewKeyedTransactorWithChainID(m.pvk, m.chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Context = ctx
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+	opts.NoSend = true
+
+	tx, err := fn(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx == nil {
+		return nil, ErrNilTxResult
+	}
+
+	if tx.Nonce() != nonce {
+		return nil, fmt.Errorf("transaction nonce %d does not match assigned nonce %d", tx.Nonce(), nonce)
+	}
+
+	if err := m.backend.SendTransaction(ctx, tx); err != nil {
+		if isNonceTooLow(err) {
+			// someone else used the key, resync on the next call
+			m.nonce = nil
+		}
+		return nil, err
+	}
+
+	next := nonce + 1
+	m.nonce = &next
+
+	now := time.Now().Unix()
+
+	p := &PendingTx{
+		ID:          pendingTxID(m.chainID.Int64(), m.fromKey(), nonce),
+		ChainID:     m.chainID.Int64(),
+		From:        m.fromKey(),
+		Nonce:       nonce,
+		SubmittedAt: now,
+		CreatedAt:   now,
+	}
+
+	if err := m.track(ctx, p, tx); err != nil {
+		logger.Error("failed to save pending tx", "nonce", nonce, "error", err)
+	}
+
+	return tx, nil
+}
+
+// WaitMined waits until the transaction sent for tx's nonce is mined and returns its receipt.
+// The receipt may belong to a resubmitted version of tx with a different hash.
+func (m *TxManager) WaitMined(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	m.mu.Lock()
+	m.waiters[tx.Nonce()]++
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		if m.waiters[tx.Nonce()]--; m.waiters[tx.Nonce()] <= 0 {
+			delete(m.waiters, tx.Nonce())
+		}
+		m.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := m.checkNonce(ctx, tx)
+		if err != nil || receipt != nil {
+			return receipt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run checks every pending transaction on each poll interval until ctx is canceled,
+// so transactions nobody waits on, e.g. ones restored after a restart, still get mined.
+func (m *TxManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll(ctx)
+		}
+	}
+}
+
+func (m *TxManager) checkAll(ctx context.Context) {
+	for _, p := range m.pendingTxs() {
+		m.mu.Lock()
+		waited := m.waiters[p.Nonce] > 0
+		m.mu.Unlock()
+
+		if waited {
+			continue
+		}
+
+		if _, err := m.check(ctx, p); err != nil {
+			logger.Error("failed to check pending tx", "nonce", p.Nonce, "error", err)
+		}
+	}
+}
+
+// checkNonce checks the pending transaction for tx's nonce, falling back to tx itself once it is no longer tracked.
+func (m *TxManager) checkNonce(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	m.mu.Lock()
+	p, ok := m.pending[tx.Nonce()]
+	m.mu.Unlock()
+
+	if ok {
+		return m.check(ctx, p)
+	}
+
+	hashes := []string{tx.Hash().Hex()}
+
+	receipt, err := m.findReceipt(ctx, hashes)
+	if receipt != nil || err != nil {
+		return receipt, err
+	}
+
+	confirmed, err := m.backend.NonceAt(ctx, m.from, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if confirmed > tx.Nonce() {
+		// tx may have been mined after the lookup above, look again before calling it replaced
+		receipt, err := m.findReceipt(ctx, hashes)
+		if receipt != nil || err != nil {
+			return receipt, err
+		}
+
+		return nil, ErrTxReplaced
+	}
+
+	return nil, ErrUnknownTx
+}
+
+// findReceipt returns the receipt of the newest of hashes that was mined, nil when none was.
+func (m *TxManager) findReceipt(ctx context.Context, hashes []string) (*types.Receipt, error) {
+	for i := len(hashes) - 1; i >= 0; i-- {
+		receipt, err := m.backend.TransactionReceipt(ctx, common.HexToHash(hashes[i]))
+		if err == nil {
+			return receipt, nil
+		}
+
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// check returns the receipt of p once any of its versions is mined.
+// While it is pending, p is rebroadcast if it was dropped and resent with bumped gas once the resubmit timeout passes.
+func (m *TxManager) check(ctx context.Context, p *PendingTx) (*types.Receipt, error) {
+	m.checkMu.Lock()
+	defer m.checkMu.Unlock()
+
+	receipt, err := m.findReceipt(ctx, p.Hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt != nil {
+		m.untrack(ctx, p)
+		return receipt, nil
+	}
+
+	confirmed, err := m.backend.NonceAt(ctx, m.from, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if confirmed > p.Nonce {
+		// one of the versions may have been mined after the lookup above, or its receipt indexed since,
+		// so look again before calling p replaced
+		receipt, err := m.findReceipt(ctx, p.Hashes)
+		if err != nil {
+			return nil, err
+		}
+
+		m.untrack(ctx, p)
+
+		if receipt != nil {
+			return receipt, nil
+		}
+
+		return nil, ErrTxReplaced
+	}
+
+	tx, err := p.tx()
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(time.Unix(p.SubmittedAt, 0)) >= m.resubmitTimeout {
+		return nil, m.resubmit(ctx, p, tx)
+	}
+
+	_, _, err = m.backend.TransactionByHash(ctx, tx.Hash())
+	if errors.Is(err, ethereum.NotFound) {
+		logger.Info("rebroadcasting dropped tx", "nonce", p.Nonce, "hash", tx.Hash().Hex())
+		return nil, m.broadcast(ctx, tx)
+	}
+
+	return nil, err
+}
+
+// resubmit resends p with its gas price bumped by the configured percentage.
+func (m *TxManager) resubmit(ctx context.Context, p *PendingTx, tx *types.Transaction) error {
+	bumped, err := m.bumpGas(tx)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("resubmitting tx with bumped gas", "nonce", p.Nonce, "from", tx.Hash().Hex(), "to", bumped.Hash().Hex())
+
+	if err := m.broadcast(ctx, bumped); err != nil {
+		return err
+	}
+
+	p.SubmittedAt = time.Now().Unix()
+
+	return m.track(ctx, p, bumped)
+}
+
+func (m *TxManager) broadcast(ctx context.Context, tx *types.Transaction) error {
+	err := m.backend.SendTransaction(ctx, tx)
+	if err != nil && !isAlreadyKnown(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (m *TxManager) bumpGas(tx *types.Transaction) (*types.Transaction, error) {
+	var data types.TxData
+
+	switch tx.Type() {
+	case types.LegacyTxType:
+		gasPrice, err := m.bumpFee(tx.GasPrice())
+		if err != nil {
+			return nil, err
+		}
+
+		data = &types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		}
+	case types.DynamicFeeTxType:
+		gasFeeCap, err := m.bumpFee(tx.GasFeeCap())
+		if err != nil {
+			return nil, err
+		}
+
+		data = &types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  m.bump(tx.GasTipCap()),
+			GasFeeCap:  gasFeeCap,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", tx.Type())
+	}
+
+	return types.SignNewTx(m.pvk, m.signer, data)
+}
+
+// bumpFee bumps a per-gas fee, failing instead of resubmitting a transaction that pays more than the ceiling.
+func (m *TxManager) bumpFee(fee *big.Int) (*big.Int, error) {
+	bumped := m.bump(fee)
+
+	if m.maxFeePerGas != nil && bumped.Cmp(m.maxFeePerGas) > 0 {
+		return nil, fmt.Errorf("%w: bumped fee %s is above ceiling %s", ErrMaxFeeExceeded, bumped, m.maxFeePerGas)
+	}
+
+	return bumped, nil
+}
+
+// bump raises v by the gas bump percentage, and by at least 1 wei.
+func (m *TxManager) bump(v *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(v, big.NewInt(100+m.gasBumpPercent))
+	bumped.Div(bumped, big.NewInt(100))
+
+	if bumped.Cmp(v) <= 0 {
+		bumped.Add(v, big.NewInt(1))
+	}
+
+	return bumped
+}
+
+// nextNonce returns the local nonce, syncing it with the chain and the tracked pending transactions when unset.
+func (m *TxManager) nextNonce(ctx context.Context) (uint64, error) {
+	if m.nonce != nil {
+		return *m.nonce, nil
+	}
+
+	nonce, err := m.backend.PendingNonceAt(ctx, m.from)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range m.pendingTxs() {
+		if p.Nonce >= nonce {
+			nonce = p.Nonce + 1
+		}
+	}
+
+	m.nonce = &nonce
+
+	return nonce, nil
+}
+
+// track records tx as the latest version of p.
+func (m *TxManager) track(ctx context.Context, p *PendingTx, tx *types.Transaction) error {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	p.RawTx = hexutil.Encode(raw)
+	p.Hashes = append(p.Hashes, tx.Hash().Hex())
+	m.pending[p.Nonce] = p
+	m.mu.Unlock()
+
+	if m.repo == nil {
+		return nil
+	}
+
+	return m.repo.SavePendingTx(ctx, p)
+}
+
+func (m *TxManager) untrack(ctx context.Context, p *PendingTx) {
+	m.mu.Lock()
+	delete(m.pending, p.Nonce)
+	m.mu.Unlock()
+
+	if m.repo == nil {
+		return
+	}
+
+	if err := m.repo.DeletePendingTx(ctx, p.ID); err != nil {
+		logger.Error("failed to delete pending tx", "nonce", p.Nonce, "error", err)
+	}
+}
+
+// pendingTxs returns the tracked pending transactions ordered by nonce.
+func (m *TxManager) pendingTxs() []*PendingTx {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	txs := make([]*PendingTx, 0, len(m.pending))
+
+	for _, p := range m.pending {
+		txs = append(txs, p)
+	}
+
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Nonce < txs[j].Nonce
+	})
+
+	return txs
+}
+
+func (m *TxManager) fromKey() string {
+	return strings.ToLower(m.from.Hex())
+}
+
+// pendingTxID names a nonce of the sender on a chain, the same key signs on every network.
+func pendingTxID(chainID int64, from string, nonce uint64) string {
+	return fmt.Sprintf("%d-%s-%d", chainID, from, nonce)
+}
+
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+func isAlreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
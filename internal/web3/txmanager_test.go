@@ -0,0 +1,413 @@
+package web3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var simulatedChainID = big.NewInt(1337)
+
+type memoryTxRepository struct {
+	mu  sync.Mutex
+	txs map[string]PendingTx
+}
+
+func (r *memoryTxRepository) FindPendingTxs(ctx context.Context, from string) ([]*PendingTx, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var txs []*PendingTx
+
+	for _, tx := range r.txs {
+		if tx.From == from {
+			tx := tx
+			txs = append(txs, &tx)
+		}
+	}
+
+	return txs, nil
+}
+
+func (r *memoryTxRepository) SavePendingTx(ctx context.Context, tx *PendingTx) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := *tx
+	saved.Hashes = append([]string(nil), tx.Hashes...)
+	r.txs[tx.ID] = saved
+
+	return nil
+}
+
+func (r *memoryTxRepository) DeletePendingTx(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.txs, id)
+
+	return nil
+}
+
+func newSimulated(t *testing.T) (*backends.SimulatedBackend, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	pvk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance := new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(pvk.PublicKey): {Balance: balance},
+	}, 30_000_000)
+
+	t.Cleanup(func() { backend.Close() })
+
+	return backend, pvk
+}
+
+func newTestTxManager(t *testing.T, backend *backends.SimulatedBackend, pvk *ecdsa.PrivateKey, repo TxRepository) *TxManager {
+	t.Helper()
+
+	m, err := NewTxManager(context.Background(), TxManagerConfig{
+		Backend:         backend,
+		PrivateKey:      pvk,
+		ChainID:         simulatedChainID,
+		Repo:            repo,
+		ResubmitTimeout: time.Hour,
+		PollInterval:    10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// transfer returns a transact function sending 1 wei to a fixed address.
+func transfer(backend *backends.SimulatedBackend) func(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		gasPrice, err := backend.SuggestGasPrice(opts.Context)
+		if err != nil {
+			return nil, err
+		}
+
+		to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+		tx := types.NewTx(&types.LegacyTx{
+			Nonce:    opts.Nonce.Uint64(),
+			GasPrice: gasPrice,
+			Gas:      21000,
+			To:       &to,
+			Value:    big.NewInt(1),
+		})
+
+		return opts.Signer(opts.From, tx)
+	}
+}
+
+func TestTxManagerConcurrentNonces(t *testing.T) {
+	backend, pvk := newSimulated(t)
+	m := newTestTxManager(t, backend, pvk, nil)
+
+	ctx := context.Background()
+
+	const n = 10
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		txs []*types.Transaction
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			tx, err := m.Transact(ctx, transfer(backend))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			mu.Lock()
+			txs = append(txs, tx)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if len(txs) != n {
+		t.Fatalf("expected %d transactions, got %d", n, len(txs))
+	}
+
+	nonces := make(map[uint64]bool)
+
+	for _, tx := range txs {
+		if nonces[tx.Nonce()] {
+			t.Fatalf("nonce %d assigned twice", tx.Nonce())
+		}
+		nonces[tx.Nonce()] = true
+	}
+
+	backend.Commit()
+
+	for _, tx := range txs {
+		receipt, err := m.WaitMined(ctx, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			t.Errorf("transaction %d failed", tx.Nonce())
+		}
+	}
+
+	if len(m.pendingTxs()) != 0 {
+		t.Errorf("expected no pending transactions, got %d", len(m.pendingTxs()))
+	}
+}
+
+func TestTxManagerRebroadcastsDroppedTx(t *testing.T) {
+	backend, pvk := newSimulated(t)
+	m := newTestTxManager(t, backend, pvk, nil)
+
+	ctx := context.Background()
+
+	tx, err := m.Transact(ctx, transfer(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the pending block, and the transaction with it
+	backend.Rollback()
+
+	if _, err := m.checkNonce(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.Commit()
+
+	receipt, err := m.WaitMined(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receipt.TxHash != tx.Hash() {
+		t.Errorf("expected receipt for %s, got %s", tx.Hash().Hex(), receipt.TxHash.Hex())
+	}
+}
+
+func TestTxManagerResubmitsWithBumpedGas(t *testing.T) {
+	backend, pvk := newSimulated(t)
+	m := newTestTxManager(t, backend, pvk, nil)
+	m.resubmitTimeout = time.Nanosecond
+
+	ctx := context.Background()
+
+	tx, err := m.Transact(ctx, transfer(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the simulated backend only accepts a replacement once the original left the pending block
+	backend.Rollback()
+
+	if _, err := m.checkNonce(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.Commit()
+
+	receipt, err := m.WaitMined(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receipt.TxHash == tx.Hash() {
+		t.Fatal("expected receipt of the resubmitted transaction")
+	}
+
+	mined, _, err := backend.TransactionByHash(ctx, receipt.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mined.Nonce() != tx.Nonce() {
+		t.Errorf("expected nonce %d, got %d", tx.Nonce(), mined.Nonce())
+	}
+
+	if mined.GasPrice().Cmp(tx.GasPrice()) <= 0 {
+		t.Errorf("expected gas price above %s, got %s", tx.GasPrice(), mined.GasPrice())
+	}
+}
+
+func TestTxManagerDetectsReplacedTx(t *testing.T) {
+	backend, pvk := newSimulated(t)
+	m := newTestTxManager(t, backend, pvk, nil)
+
+	ctx := context.Background()
+
+	tx, err := m.Transact(ctx, transfer(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.Rollback()
+
+	// another sender uses the same key and nonce
+	other, err := types.SignNewTx(pvk, types.LatestSignerForChainID(simulatedChainID), &types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice(),
+		Gas:      tx.Gas(),
+		To:       tx.To(),
+		Value:    big.NewInt(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.SendTransaction(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.Commit()
+
+	if _, err := m.WaitMined(ctx, tx); err != ErrTxReplaced {
+		t.Fatalf("expected ErrTxReplaced, got %v", err)
+	}
+}
+
+// lateReceiptBackend misses the first receipt lookup, as if the tx was mined right after it.
+type lateReceiptBackend struct {
+	*backends.SimulatedBackend
+	missed bool
+}
+
+func (b *lateReceiptBackend) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if !b.missed {
+		b.missed = true
+		return nil, ethereum.NotFound
+	}
+
+	return b.SimulatedBackend.TransactionReceipt(ctx, hash)
+}
+
+func TestTxManagerMinedDuringCheckIsNotReplaced(t *testing.T) {
+	backend, pvk := newSimulated(t)
+
+	m, err := NewTxManager(context.Background(), TxManagerConfig{
+		Backend:         &lateReceiptBackend{SimulatedBackend: backend},
+		PrivateKey:      pvk,
+		ChainID:         simulatedChainID,
+		ResubmitTimeout: time.Hour,
+		PollInterval:    10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	tx, err := m.Transact(ctx, transfer(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.Commit()
+
+	receipt, err := m.WaitMined(ctx, tx)
+	if err != nil {
+		t.Fatalf("expected receipt, got %v", err)
+	}
+
+	if receipt.TxHash != tx.Hash() {
+		t.Fatalf("expected receipt of %s, got %s", tx.Hash().Hex(), receipt.TxHash.Hex())
+	}
+}
+
+func TestTxManagerRestoresPendingTxs(t *testing.T) {
+	backend, pvk := newSimulated(t)
+	repo := &memoryTxRepository{txs: make(map[string]PendingTx)}
+
+	ctx := context.Background()
+
+	tx, err := newTestTxManager(t, backend, pvk, repo).Transact(ctx, transfer(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the node forgot the transaction while the server restarted
+	backend.Rollback()
+
+	m := newTestTxManager(t, backend, pvk, repo)
+
+	nonce, err := m.nextNonce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nonce != tx.Nonce()+1 {
+		t.Fatalf("expected next nonce %d, got %d", tx.Nonce()+1, nonce)
+	}
+
+	m.checkAll(ctx)
+	backend.Commit()
+
+	receipt, err := m.WaitMined(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receipt.TxHash != tx.Hash() {
+		t.Errorf("expected receipt for %s, got %s", tx.Hash().Hex(), receipt.TxHash.Hex())
+	}
+
+	if len(repo.txs) != 0 {
+		t.Errorf("expected repository to be empty, got %d", len(repo.txs))
+	}
+}
+
+func TestTxManagerBumpRespectsMaxFee(t *testing.T) {
+	backend, pvk := newSimulated(t)
+	m := newTestTxManager(t, backend, pvk, nil)
+
+	tx, err := m.Transact(context.Background(), transfer(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.maxFeePerGas = new(big.Int).Set(tx.GasPrice())
+
+	if _, err := m.bumpGas(tx); !errors.Is(err, ErrMaxFeeExceeded) {
+		t.Fatalf("expected ErrMaxFeeExceeded, got %v", err)
+	}
+
+	m.maxFeePerGas = new(big.Int).Mul(tx.GasPrice(), big.NewInt(2))
+
+	bumped, err := m.bumpGas(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bumped.GasPrice().Cmp(tx.GasPrice()) <= 0 || bumped.GasPrice().Cmp(m.maxFeePerGas) > 0 {
+		t.Errorf("expected gas price in (%s, %s], got %s", tx.GasPrice(), m.maxFeePerGas, bumped.GasPrice())
+	}
+}